@@ -0,0 +1,224 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+Cache is a key-value store used by a CachingEntity, such as an
+adapter for Redis or an in-memory LRU. Documents are stored in the
+form returned by Entity.ToBSONMap. Implementations must be safe for
+concurrent use if the CachingEntity is.
+*/
+type Cache interface {
+	// Get returns the document stored under key, if any.
+	Get(key string) (bson.M, bool)
+	// Set stores the document under key.
+	Set(key string, doc bson.M)
+	// Delete removes the document stored under key, if any.
+	Delete(key string)
+}
+
+/*
+CachingEntity wraps an Entity with a read-through Cache for reads
+by ID or axis value. Documents read using Read are cached under
+keys derived from their ID and each of their axis values, and are
+invalidated by Edit and Delete.
+
+Changes made other than through the CachingEntity (for example,
+using the wrapped Entity's operations directly) do not invalidate
+the Cache.
+*/
+type CachingEntity struct {
+	*Entity
+	// Cache stores the documents read.
+	Cache Cache
+	/*
+		find, update and remove perform the database operations of
+		Read, Edit and Delete respectively, returning the matched
+		document (before the operation, for update and remove).
+	*/
+	find   func(filter bson.M) (bson.Raw, error)
+	update func(filter, update bson.M) (bson.Raw, error)
+	remove func(filter bson.M) (bson.Raw, error)
+}
+
+/*
+NewCachingEntity returns a CachingEntity which caches the reads of
+the given Entity in the given Cache.
+*/
+func NewCachingEntity(e *Entity, cache Cache) *CachingEntity {
+	return &CachingEntity{
+		Entity: e,
+		Cache:  cache,
+		find: func(filter bson.M) (bson.Raw, error) {
+			return e.PStorage.FindOne(context.TODO(), filter).DecodeBytes()
+		},
+		update: func(filter, update bson.M) (bson.Raw, error) {
+			return e.PStorage.FindOneAndUpdate(context.TODO(), filter, update,
+				options.FindOneAndUpdate().SetReturnDocument(options.Before)).DecodeBytes()
+		},
+		remove: func(filter bson.M) (bson.Raw, error) {
+			return e.PStorage.FindOneAndDelete(context.TODO(), filter).DecodeBytes()
+		},
+	}
+}
+
+/*
+Read returns the instance of the Entity matching the filter produced
+by the given entity (see Filter), from the Cache if possible. If no
+document matches, an entityErrors.NotFound error is returned.
+
+The values of encrypted fields are cached as stored, that is, as
+ciphertexts, and are decrypted on every Read.
+*/
+func (ce *CachingEntity) Read(entity interface{}) (interface{}, error) {
+	if !ce.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	filter := Filter(entity)
+	if filter == nil {
+		return nil, entityErrors.UndefinedAxis
+	}
+
+	if doc, ok := ce.Cache.Get(ce.cacheKey(filter)); ok {
+		cached, err := ce.FromBSONMap(doc)
+		if err != nil {
+			return nil, err
+		}
+		return ce.Decrypt(cached)
+	}
+
+	raw, err := ce.find(filter)
+	if err == mongo.ErrNoDocuments {
+		return nil, entityErrors.NotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	read, err := ce.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ce.ToBSONMap(read)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range ce.cacheKeys(read) {
+		ce.Cache.Set(key, doc)
+	}
+	return ce.Decrypt(read)
+}
+
+/*
+Edit applies the given update ESpec to the document matching the
+given entity, as Entity.Edit does, and invalidates the document's
+Cache entries.
+*/
+func (ce *CachingEntity) Edit(entity interface{}, spec spec.ESpec) error {
+	spec, err := ce.encryptSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	return ce.modify(entity, func(filter bson.M) (bson.Raw, error) {
+		return ce.update(filter, spec.ToUpdateSpec())
+	})
+}
+
+/*
+Delete deletes the document matching the given entity, as
+Entity.Delete does, and invalidates the document's Cache entries.
+*/
+func (ce *CachingEntity) Delete(entity interface{}) error {
+	return ce.modify(entity, ce.remove)
+}
+
+/*
+modify uses the given run function to modify the document matching
+the given entity, then removes the Cache entries of the document
+returned by run.
+*/
+func (ce *CachingEntity) modify(entity interface{}, run func(filter bson.M) (bson.Raw, error)) error {
+	if !ce.typeCheck(entity) {
+		return entityErrors.IncompatibleEntityType
+	}
+
+	filter := Filter(entity)
+	if filter == nil {
+		return entityErrors.UndefinedAxis
+	}
+	ce.Cache.Delete(ce.cacheKey(filter))
+
+	raw, err := run(filter)
+	if err != nil {
+		return err
+	}
+
+	modified, err := ce.decode(raw)
+	if err != nil {
+		return err
+	}
+	for _, key := range ce.cacheKeys(modified) {
+		ce.Cache.Delete(key)
+	}
+	return nil
+}
+
+/*
+decode decodes the given document into an instance of the Entity.
+*/
+func (ce *CachingEntity) decode(raw bson.Raw) (interface{}, error) {
+	entity := reflect.New(ce.SchemaDefinition)
+	if err := bson.Unmarshal(raw, entity.Interface()); err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	return entity.Elem().Interface(), nil
+}
+
+/*
+cacheKeys returns the keys of the Cache entries for the given entity:
+one for its ID and one for each of its axis values.
+*/
+func (ce *CachingEntity) cacheKeys(entity interface{}) []string {
+	t := reflect.TypeOf(entity)
+	v := reflect.ValueOf(entity)
+
+	keys := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+
+		if eField.StorageName(field) == "_id" && value != primitive.NilObjectID {
+			keys = append(keys, ce.cacheKey(bson.M{"_id": value}))
+		} else if eField.IsAxis(field) && !eField.IsZero(v.Field(i)) {
+			name := eField.NameByPriority(field, eField.PriorityBsonJson)
+			keys = append(keys, ce.cacheKey(bson.M{name: value}))
+		}
+	}
+	return keys
+}
+
+/*
+cacheKey returns the key of the Cache entry for the given filter,
+as produced by Filter. Keys are prefixed by the name of the Entity's
+SchemaDefinition, so that a Cache can be shared between Entities.
+*/
+func (ce *CachingEntity) cacheKey(filter bson.M) string {
+	for field, value := range filter {
+		return fmt.Sprintf("%s:%s=%v", ce.SchemaDefinition.Name(), field, value)
+	}
+	return ce.SchemaDefinition.Name()
+}
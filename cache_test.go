@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/navaz-alani/entity/spec"
+)
+
+// mapCache is an in-memory Cache
+type mapCache map[string]bson.M
+
+func (mc mapCache) Get(key string) (bson.M, bool) {
+	doc, ok := mc[key]
+	return doc, ok
+}
+
+func (mc mapCache) Set(key string, doc bson.M) { mc[key] = doc }
+
+func (mc mapCache) Delete(key string) { delete(mc, key) }
+
+type CachedAccount struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Email string             `bson:"email" _ax_:"true"`
+	Plan  string             `bson:"plan"`
+}
+
+func TestCachingEntity(t *testing.T) {
+	account := CachedAccount{ID: primitive.NewObjectID(), Email: "jane@example.com", Plan: "free"}
+	raw, _ := bson.Marshal(account)
+
+	cache := mapCache{}
+	ce := NewCachingEntity(&Entity{SchemaDefinition: TypeOf(CachedAccount{})}, cache)
+
+	finds := 0
+	ce.find = func(filter bson.M) (bson.Raw, error) {
+		finds++
+		return raw, nil
+	}
+	ce.update = func(filter, update bson.M) (bson.Raw, error) {
+		return raw, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		read, err := ce.Read(CachedAccount{Email: account.Email})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read != account {
+			t.Errorf("expected %v, got %v", account, read)
+		}
+	}
+	if finds != 1 {
+		t.Errorf("expected second read to hit the cache, got %d finds", finds)
+	}
+	if len(cache) != 2 {
+		t.Errorf("expected entries for ID and axis value, got %v", cache)
+	}
+
+	// an update by ID invalidates the entry read by axis value
+	if err := ce.Edit(CachedAccount{ID: account.ID}, spec.Set("plan", "pro")); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache) != 0 {
+		t.Errorf("expected update to invalidate entries, got %v", cache)
+	}
+
+	if _, err := ce.Read(CachedAccount{Email: account.Email}); err != nil {
+		t.Fatal(err)
+	}
+	if finds != 2 {
+		t.Errorf("expected read after update to query the database")
+	}
+}
+
+type OmittedIDAccount struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Email string             `bson:"email" _ax_:"true"`
+}
+
+func TestCachingEntityOmitEmptyID(t *testing.T) {
+	ce := NewCachingEntity(&Entity{SchemaDefinition: TypeOf(OmittedIDAccount{})}, mapCache{})
+	account := OmittedIDAccount{ID: primitive.NewObjectID(), Email: "jane@example.com"}
+
+	keys := ce.cacheKeys(account)
+	if len(keys) != 2 || keys[0] != ce.cacheKey(bson.M{"_id": account.ID}) {
+		t.Errorf("expected entries for ID and axis value, got %v", keys)
+	}
+}
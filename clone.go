@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"reflect"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+Clone returns a deep copy of the given entity, which is
+expected to be of the Entity e's SchemaDefinition type.
+
+Slices, maps and pointers within the entity are copied
+recursively so that the clone does not share any backing
+data with the original. Unexported fields are copied by
+value.
+*/
+func (e *Entity) Clone(entity interface{}) (interface{}, error) {
+	if !e.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	clone := reflect.New(e.SchemaDefinition).Elem()
+	deepCopy(clone, reflect.ValueOf(entity))
+	return clone.Interface(), nil
+}
+
+/*
+deepCopy recursively copies the value src into dst, which
+must be settable and of the same type.
+*/
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	default:
+		dst.Set(src)
+	case reflect.Struct:
+		// copy unexported fields by value, then replace exported ones
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(value, iter.Value())
+			dst.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		deepCopy(dst.Elem(), src.Elem())
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		value := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(value, src.Elem())
+		dst.Set(value)
+	}
+}
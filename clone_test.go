@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+type CloneTest struct {
+	Name   string
+	Tags   []string
+	Labels map[string]interface{}
+	Parent *CloneTest
+}
+
+var cloneEntity = &Entity{SchemaDefinition: TypeOf(CloneTest{})}
+
+func TestEntity_Clone(t *testing.T) {
+	original := CloneTest{
+		Name:   "original",
+		Tags:   []string{"a", "b"},
+		Labels: map[string]interface{}{"k": []interface{}{"v"}},
+		Parent: &CloneTest{Name: "parent"},
+	}
+
+	res, err := cloneEntity.Clone(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := res.(CloneTest)
+	if !reflect.DeepEqual(clone, original) {
+		t.Fatal("clone differs from original")
+	}
+
+	clone.Tags[0] = "changed"
+	clone.Labels["k"].([]interface{})[0] = "changed"
+	clone.Parent.Name = "changed"
+
+	if original.Tags[0] != "a" || original.Labels["k"].([]interface{})[0] != "v" ||
+		original.Parent.Name != "parent" {
+		t.Fail()
+	}
+}
+
+func TestEntity_CloneIncompatibleType(t *testing.T) {
+	if _, err := cloneEntity.Clone(Project{}); err == nil {
+		t.Fail()
+	}
+}
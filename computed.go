@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"reflect"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+ComputeFunc is a function which derives the value of a computed
+field from the rest of an entity instance.
+*/
+type ComputeFunc func(entity interface{}) (interface{}, error)
+
+/*
+computedField pairs the name of a computed field with the
+function which computes its value.
+*/
+type computedField struct {
+	name string
+	fn   ComputeFunc
+}
+
+/*
+AddComputedField registers the field with the given (struct field)
+name as a computed field whose value is derived using fn. Computed
+fields are populated by Add before an entity is inserted, and are not
+parsed from creation payloads by the multiplexer, so they cannot be
+set by clients.
+
+Computed fields are evaluated in the order in which they were added;
+each fn is given the entity with the values of all previously computed
+fields already populated.
+
+An entityErrors.UndefinedPath error is returned if the Entity's
+SchemaDefinition has no field with the given name.
+*/
+func (e *Entity) AddComputedField(fieldName string, fn ComputeFunc) error {
+	if _, ok := e.SchemaDefinition.FieldByName(fieldName); !ok {
+		return entityErrors.UndefinedPath(fieldName)
+	}
+
+	e.computed = append(e.computed, computedField{name: fieldName, fn: fn})
+	return nil
+}
+
+/*
+IsComputed returns whether the field with the given (struct field)
+name has been registered as a computed field of the Entity e.
+*/
+func (e *Entity) IsComputed(fieldName string) bool {
+	for _, cf := range e.computed {
+		if cf.name == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+applyComputed returns a copy of the given entity with the values
+of its computed fields populated.
+*/
+func (e *Entity) applyComputed(entity interface{}) (interface{}, error) {
+	if len(e.computed) == 0 {
+		return entity, nil
+	}
+
+	v := reflect.New(e.SchemaDefinition).Elem()
+	v.Set(reflect.ValueOf(entity))
+
+	for _, cf := range e.computed {
+		value, err := cf.fn(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		field := v.FieldByName(cf.name)
+		if err := eField.WriteToField(&field, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return v.Interface(), nil
+}
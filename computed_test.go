@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+type Article struct {
+	Title string `json:"title"`
+	Slug  string `json:"slug"`
+	Path  string `json:"path"`
+}
+
+func TestEntity_ApplyComputed(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(Article{})}
+
+	err := ety.AddComputedField("Slug", func(e interface{}) (interface{}, error) {
+		return strings.ToLower(strings.Replace(e.(Article).Title, " ", "-", -1)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sees the previously computed slug
+	err = ety.AddComputedField("Path", func(e interface{}) (interface{}, error) {
+		return "/articles/" + e.(Article).Slug, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ety.applyComputed(Article{Title: "Hello World"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article := res.(Article); article.Slug != "hello-world" || article.Path != "/articles/hello-world" {
+		t.Fail()
+	}
+	if !ety.IsComputed("Slug") || ety.IsComputed("Title") {
+		t.Fail()
+	}
+}
+
+func TestEntity_AddComputedFieldUndefined(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(Article{})}
+
+	if err := ety.AddComputedField("Summary", nil); err == nil {
+		t.Fail()
+	}
+}
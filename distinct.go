@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+Distinct returns the distinct values of the given field among the
+documents in the underlying database collection pointed at by e
+which match the given query ESpecs (see BuildFilter).
+
+The field is given as a dotted path of JSON/BSON/field names (in
+that priority), as in a request payload, and is mapped to the name
+it is stored under. If the field is not defined by the Entity's
+SchemaDefinition, an entityErrors.UndefinedPath error is returned.
+*/
+func (e *Entity) Distinct(ctx context.Context, field string, filter []spec.ESpec) ([]interface{}, error) {
+	return e.distinct(field, filter, func(column string, filter bson.M) ([]interface{}, error) {
+		return e.PStorage.Distinct(ctx, column, filter)
+	})
+}
+
+/*
+distinct resolves the storage name of the given field and builds
+the filter for the given specs, before using the given run function
+to retrieve the distinct values.
+*/
+func (e *Entity) distinct(field string, specs []spec.ESpec,
+	run func(column string, filter bson.M) ([]interface{}, error)) ([]interface{}, error) {
+	_, names, _, err := e.resolve(strings.Split(field, "."), eField.PriorityJsonBson)
+	if err != nil {
+		return nil, entityErrors.UndefinedPath(field)
+	}
+
+	filter, err := e.BuildFilter(specs)
+	if err != nil {
+		return nil, err
+	}
+	return run(strings.Join(names, "."), filter)
+}
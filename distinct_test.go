@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/spec"
+)
+
+type Team struct {
+	Name string `json:"name" bson:"team_name"`
+}
+
+type Employee struct {
+	Role string `json:"role" bson:"user_role"`
+	Team Team   `json:"team" bson:"team"`
+}
+
+var employeeEntity = &Entity{SchemaDefinition: TypeOf(Employee{})}
+
+func TestEntity_Distinct(t *testing.T) {
+	var column string
+	var filter bson.M
+	run := func(c string, f bson.M) ([]interface{}, error) {
+		column, filter = c, f
+		return []interface{}{"admin", "editor"}, nil
+	}
+
+	values, err := employeeEntity.distinct("team.name", []spec.ESpec{{Field: "user_role", Target: "admin"}}, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if column != "team.team_name" {
+		t.Errorf("unexpected column '%s'", column)
+	}
+	if !reflect.DeepEqual(filter, bson.M{"user_role": "admin"}) {
+		t.Errorf("unexpected filter: %v", filter)
+	}
+	if len(values) != 2 {
+		t.Errorf("unexpected values: %v", values)
+	}
+}
+
+func TestEntity_DistinctUndefinedField(t *testing.T) {
+	run := func(c string, f bson.M) ([]interface{}, error) {
+		t.Fatal("driver called for undefined field")
+		return nil, nil
+	}
+
+	if _, err := employeeEntity.distinct("department", nil, run); err == nil {
+		t.Fail()
+	}
+}
@@ -27,6 +27,19 @@ ability to specify whether a eField is an axis, using the "axis"
 tag, in the struct eField. This tag can be set to "true" to enforce
 it as an axis eField.
 
+Validation
+
+The "_va_" (eField.ValidateTag) tag can be used on string fields
+to constrain their values. The tag value is either a raw regular
+expression of the form "re/<expression>/" or one of the presets
+(email, alpha, alphanumeric, numeric, hex) of the form
+"rep/<preset>/". For example:
+
+	Email string `json:"email" _va_:"rep/email/"`
+
+Validation tags are compiled by NewEntity, which fails if a tag
+is malformed. Entity.Validate can then be used to check instances.
+
 Getting started
 
 To use the Entity abstraction, start by creating a struct
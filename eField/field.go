@@ -1,7 +1,14 @@
 package eField
 
 import (
+	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/navaz-alani/entity/entityErrors"
 )
@@ -12,54 +19,202 @@ its value to the given data. If the given data cannot
 successfully be assigned to the given field, a
 entityErrors.InvalidDataType error is returned.
 
-This function will NEVER write to a eField which stores
-a pointer kind.
+Data which is assignable to the eField's type is set
+directly. Otherwise, data convertible to the eField's
+type is converted, except that numbers are not converted
+to strings, and floats (such as JSON numbers) are only
+converted to integers when they have no fractional part.
+Numbers which would overflow the eField's type are rejected.
+Named types, such as `type Status string` or `type Level int`,
+are written from data of their underlying kind in the same way.
+Strings are parsed into time.Time fields using RFC 3339,
+and hex strings into primitive.ObjectID fields. Numbers of
+milliseconds since the Unix epoch are written to
+primitive.DateTime fields. Slices (such as the []interface{}
+of a decoded JSON array) are written to slice fields element
+by element, in the same way.
+
+For a eField which stores a pointer to a struct, data of
+the pointed-to struct type is written to a newly allocated
+value whose address is stored in the eField.
 */
 func WriteToField(field *reflect.Value, data interface{}) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = entityErrors.InvalidDataType
+			err = entityErrors.Wrap(entityErrors.InvalidDataType, fmt.Errorf("%v", r))
 		}
 	}()
 
-	/*
-		Do not need to support pointers because an Entity has database handles.
-		Pointers stored in databases would make no sense and therefore there is
-		no pointer case in this switch.
-	*/
-	switch field.Kind() {
+	dataValue := reflect.ValueOf(data)
+	if !dataValue.IsValid() {
+		return entityErrors.InvalidDataType
+	}
+	fieldType := field.Type()
+
+	switch {
+	case dataValue.Type().AssignableTo(fieldType):
+		field.Set(dataValue)
+	case fieldType.Kind() == reflect.Ptr && dataValue.Type().AssignableTo(fieldType.Elem()):
+		/*
+			Pointers are only supported for optional embedded structs,
+			which are stored as sub-documents (or null) in the database.
+		*/
+		ptr := reflect.New(fieldType.Elem())
+		ptr.Elem().Set(dataValue)
+		field.Set(ptr)
+	case fieldType == reflect.TypeOf(time.Time{}) && dataValue.Kind() == reflect.String:
+		t, err := time.Parse(time.RFC3339, dataValue.String())
+		if err != nil {
+			return entityErrors.Wrap(entityErrors.InvalidDataType, err)
+		}
+		field.Set(reflect.ValueOf(t))
+	case fieldType == reflect.TypeOf(primitive.ObjectID{}) && dataValue.Kind() == reflect.String:
+		id, err := primitive.ObjectIDFromHex(dataValue.String())
+		if err != nil {
+			return entityErrors.Wrap(entityErrors.InvalidDataType, err)
+		}
+		field.Set(reflect.ValueOf(id))
+	case fieldType == reflect.TypeOf(primitive.DateTime(0)):
+		millis, ok := epochMillis(dataValue)
+		if !ok {
+			return entityErrors.InvalidDataType
+		}
+		field.Set(reflect.ValueOf(primitive.DateTime(millis)))
+	case dataValue.Type().ConvertibleTo(fieldType) && convertible(dataValue, fieldType):
+		field.Set(dataValue.Convert(fieldType))
+	case fieldType.Kind() == reflect.Slice && dataValue.Kind() == reflect.Slice:
+		items := reflect.MakeSlice(fieldType, dataValue.Len(), dataValue.Len())
+		for i := 0; i < dataValue.Len(); i++ {
+			item := items.Index(i)
+			if err := WriteToField(&item, dataValue.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		field.Set(items)
 	default:
-		field.Set(reflect.ValueOf(data))
-	case reflect.String:
-		field.SetString(data.(string))
+		return entityErrors.InvalidDataType
+	}
+
+	return nil
+}
+
+/*
+epochMillis returns the given integral number as a count of
+milliseconds, and whether the value was such a number.
+*/
+func epochMillis(value reflect.Value) (int64, bool) {
+	switch value.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		field.SetInt(data.(int64))
+		return value.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), true
 	case reflect.Float32, reflect.Float64:
-		field.SetFloat(data.(float64))
-	case reflect.Bool:
-		field.SetBool(data.(bool))
+		f := value.Float()
+		return int64(f), f == math.Trunc(f)
 	}
+	return 0, false
+}
 
-	return nil
+/*
+convertible reports whether converting the given value to the
+given type preserves its meaning, rather than only being allowed
+by Go's conversion rules. Numbers must be representable by the
+type: fractional floats and values which would overflow (or
+wrap, such as negative values for unsigned types) are rejected.
+*/
+func convertible(value reflect.Value, t reflect.Type) bool {
+	target := reflect.Zero(t)
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := value.Int()
+		switch t.Kind() {
+		case reflect.String:
+			// integer to string conversions yield runes
+			return false
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return !target.OverflowInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return i >= 0 && !target.OverflowUint(uint64(i))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := value.Uint()
+		switch t.Kind() {
+		case reflect.String:
+			return false
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return u <= math.MaxInt64 && !target.OverflowInt(int64(u))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return !target.OverflowUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// float64(math.MaxInt64) rounds up to 2^63, which overflows
+			return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 &&
+				!target.OverflowInt(int64(f))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 &&
+				!target.OverflowUint(uint64(f))
+		case reflect.Float32, reflect.Float64:
+			return !target.OverflowFloat(f)
+		}
+	}
+	return true
+}
+
+/*
+IsZero reports whether the given field value is considered empty.
+
+Unlike reflect.Value.IsZero, it treats primitive.NilObjectID and
+any time.Time for which Time.IsZero holds (regardless of location)
+as zero, as well as slices and maps with no elements.
+*/
+func IsZero(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	} else if !v.CanInterface() {
+		return v.IsZero()
+	}
+
+	switch value := v.Interface().(type) {
+	case primitive.ObjectID:
+		return value == primitive.NilObjectID
+	case time.Time:
+		return value.IsZero()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
 }
 
 /*
 CheckCollectionEmbedding returns whether the given field's type
 is a collection type (array, slice, ...) as well as the
-type of an element in the collection.
+type of an element in the collection. For collections of
+pointers to structs, the pointed-to struct type is returned.
 */
 func CheckCollectionEmbedding(field reflect.StructField) (bool, reflect.Type) {
 	switch field.Type.Kind() {
 	default:
 		return false, nil
 	case reflect.Slice, reflect.Array:
-		return true, field.Type.Elem()
+		elem := field.Type.Elem()
+		if elem.Kind() == reflect.Ptr && elem.Elem().Kind() == reflect.Struct {
+			return true, elem.Elem()
+		}
+		return true, elem
 	}
 }
 
 /*
 CheckStructEmbedding returns whether the given field's type is
-of struct kind as well as the struct type stored in the field.
+of struct kind (or a pointer to a struct) as well as the struct
+type stored in the field.
 */
 func CheckStructEmbedding(field reflect.StructField) (bool, reflect.Type) {
 	switch field.Type.Kind() {
@@ -67,5 +222,160 @@ func CheckStructEmbedding(field reflect.StructField) (bool, reflect.Type) {
 		return false, nil
 	case reflect.Struct:
 		return true, field.Type
+	case reflect.Ptr:
+		if elem := field.Type.Elem(); elem.Kind() == reflect.Struct {
+			return true, elem
+		}
+		return false, nil
+	}
+}
+
+/*
+ParseValue parses the given string into a value of the given
+type. This is used for string-encoded data such as URL query
+parameters.
+
+Types of string, integer, float and bool kind (including named
+types) are supported, as well as primitive.ObjectID from its
+hex representation. If the string cannot be parsed into the
+type, an entityErrors.InvalidDataType error is returned.
+*/
+func ParseValue(raw string, t reflect.Type) (interface{}, error) {
+	if t == reflect.TypeOf(primitive.ObjectID{}) {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return nil, entityErrors.Wrap(entityErrors.InvalidDataType, err)
+		}
+		return id, nil
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch t.Kind() {
+	default:
+		return nil, entityErrors.InvalidDataType
+	case reflect.String:
+		parsed = raw
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err = strconv.ParseInt(raw, 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err = strconv.ParseUint(raw, 10, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		parsed, err = strconv.ParseFloat(raw, t.Bits())
+	case reflect.Bool:
+		parsed, err = strconv.ParseBool(raw)
+	}
+
+	if err != nil {
+		return nil, entityErrors.Wrap(entityErrors.InvalidDataType, err)
+	}
+	return reflect.ValueOf(parsed).Convert(t).Interface(), nil
+}
+
+/*
+parseAxisTag splits the AxisTag value of the given field into its
+kind (AxisUnique or AxisNonUnique) and its optional priority,
+given after AxisPrioritySeparator (e.g. "true:1"). The returned
+bool reports whether a priority was given.
+*/
+func parseAxisTag(field reflect.StructField) (kind string, priority int, prioritized bool, err error) {
+	kind = field.Tag.Get(AxisTag)
+	if i := strings.Index(kind, AxisPrioritySeparator); i >= 0 {
+		raw := kind[i+len(AxisPrioritySeparator):]
+		kind = kind[:i]
+		if priority, err = strconv.Atoi(raw); err != nil || priority < 0 {
+			return kind, 0, false, fmt.Errorf("invalid axis priority %q", raw)
+		}
+		prioritized = true
+	}
+	return kind, priority, prioritized, nil
+}
+
+/*
+HandleTokens returns the distinct tokens of the HandleTag of the
+given field, in the order in which they are given. Each rune of
+the tag is one token.
+*/
+func HandleTokens(field reflect.StructField) []rune {
+	tag := field.Tag.Get(HandleTag)
+	tokens := make([]rune, 0, len(tag))
+	for _, tok := range tag {
+		if !strings.ContainsRune(string(tokens), tok) {
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens
+}
+
+/*
+IsAxis returns whether the given field is an axis field, that is,
+whether its AxisTag is AxisUnique or AxisNonUnique, optionally
+followed by a valid priority.
+*/
+func IsAxis(field reflect.StructField) bool {
+	kind, _, _, err := parseAxisTag(field)
+	return err == nil && (kind == AxisUnique || kind == AxisNonUnique)
+}
+
+/*
+IsUniqueAxis returns whether the given field is an axis field
+whose values are unique (AxisUnique).
+*/
+func IsUniqueAxis(field reflect.StructField) bool {
+	kind, _, _, err := parseAxisTag(field)
+	return err == nil && kind == AxisUnique
+}
+
+/*
+AxisPriority returns the priority given in the AxisTag of the
+given field (e.g. 1 for "true:1") and whether one was given.
+Lower priorities take precedence when choosing a filter axis.
+*/
+func AxisPriority(field reflect.StructField) (int, bool) {
+	_, priority, prioritized, err := parseAxisTag(field)
+	if err != nil {
+		return 0, false
+	}
+	return priority, prioritized
+}
+
+/*
+CheckAxisTag verifies that the AxisTag of the given field, if set,
+has one of the accepted values (AxisUnique or AxisNonUnique),
+optionally followed by a non-negative integer priority. Any
+other value is reported as an error naming the field, since it
+would otherwise silently not be treated as an axis.
+*/
+func CheckAxisTag(field reflect.StructField) error {
+	tag := field.Tag.Get(AxisTag)
+	if tag == "" || IsAxis(field) {
+		return nil
 	}
+	return entityErrors.FieldTagUndefined(AxisTag, tag, field.Name)
+}
+
+/*
+IsEncrypted returns whether the given field is encrypted at rest,
+that is, whether its EncryptTag is "true".
+*/
+func IsEncrypted(field reflect.StructField) bool {
+	return field.Tag.Get(EncryptTag) == "true"
+}
+
+/*
+CheckEncryptTag verifies that the EncryptTag of the given field, if
+set, is "true" and that the field is of string kind or a []byte.
+*/
+func CheckEncryptTag(field reflect.StructField) error {
+	tag := field.Tag.Get(EncryptTag)
+	if tag == "" {
+		return nil
+	}
+
+	bytes := field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Uint8
+	if tag != "true" || (field.Type.Kind() != reflect.String && !bytes) {
+		return entityErrors.FieldTagUndefined(EncryptTag, tag, field.Name)
+	}
+	return nil
 }
@@ -7,6 +7,7 @@ package eField
 
 import (
 	"reflect"
+	"strings"
 )
 
 /*
@@ -15,6 +16,12 @@ These are tags which are related to the field's name.
 const (
 	JSONTag string = "json"
 	BSONTag string = "bson"
+	/*
+		RequestTag overrides the name of a field in request
+		payloads parsed by the multiplexer, without affecting
+		its JSON (de)serialization.
+	*/
+	RequestTag string = "_req_"
 )
 
 /*
@@ -33,6 +40,8 @@ var (
 	PriorityJsonBson = Priority{Tags: []string{JSONTag, BSONTag}}
 	// Choose first of JSON tag, BSON tag, Field name
 	PriorityBsonJson = Priority{Tags: []string{BSONTag, JSONTag}}
+	// Choose first of Request tag, JSON tag, BSON tag, Field name
+	PriorityRequest = Priority{Tags: []string{RequestTag, JSONTag, BSONTag}}
 )
 
 /*
@@ -50,3 +59,36 @@ func NameByPriority(field reflect.StructField, p Priority) string {
 	}
 	return field.Name
 }
+
+/*
+StorageName returns the name under which the mongo driver stores
+the given field: the name given in its BSON tag or, if there is
+none, the lowercased field name. An empty string is returned for
+fields which are not stored (BSON tag "-").
+*/
+func StorageName(field reflect.StructField) string {
+	tag := field.Tag.Get(BSONTag)
+	if tag == "-" {
+		return ""
+	}
+
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
+
+/*
+IsInline returns whether the BSON tag of the given field has the
+"inline" option, in which case the mongo driver stores the fields
+of the (struct) field in place of the field itself.
+*/
+func IsInline(field reflect.StructField) bool {
+	tagOptions := strings.Split(field.Tag.Get(BSONTag), ",")
+	for _, option := range tagOptions[1:] {
+		if option == "inline" {
+			return true
+		}
+	}
+	return false
+}
@@ -39,3 +39,20 @@ func TestByPriorityMissingTags(t *testing.T) {
 		t.Fail()
 	}
 }
+
+type InlineStruct struct {
+	Inline   TestStruct `bson:",inline"`
+	Named    TestStruct `bson:"named,inline"`
+	Embedded TestStruct `bson:"embedded"`
+}
+
+func TestIsInline(t *testing.T) {
+	st := reflect.TypeOf(InlineStruct{})
+
+	if !fName.IsInline(st.Field(0)) || !fName.IsInline(st.Field(1)) {
+		t.Errorf("expected inline fields to be reported")
+	}
+	if fName.IsInline(st.Field(2)) {
+		t.Errorf("expected field without inline option not to be reported")
+	}
+}
@@ -0,0 +1,325 @@
+package eField_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+type Level int
+
+type Status string
+
+type parseTest struct {
+	Raw      string
+	Type     reflect.Type
+	Expected interface{}
+}
+
+var parseTests = [...]parseTest{
+	{"text", reflect.TypeOf(""), "text"},
+	{"18", reflect.TypeOf(int64(0)), int64(18)},
+	{"3", reflect.TypeOf(Level(0)), Level(3)},
+	{"2.5", reflect.TypeOf(float64(0)), 2.5},
+	{"true", reflect.TypeOf(false), true},
+	{"5e8f1f7b4f1a4e6d9c3b2a10", reflect.TypeOf(primitive.ObjectID{}),
+		primitive.ObjectID{0x5e, 0x8f, 0x1f, 0x7b, 0x4f, 0x1a, 0x4e, 0x6d, 0x9c, 0x3b, 0x2a, 0x10}},
+}
+
+func TestParseValue(t *testing.T) {
+	for _, pt := range parseTests {
+		res, err := eField.ParseValue(pt.Raw, pt.Type)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(res, pt.Expected) {
+			t.Errorf("%s: expected %v, got %v", pt.Raw, pt.Expected, res)
+		}
+	}
+}
+
+func TestParseValueInvalid(t *testing.T) {
+	if _, err := eField.ParseValue("eighteen", reflect.TypeOf(int64(0))); err == nil {
+		t.Fail()
+	}
+	if _, err := eField.ParseValue("[]", reflect.TypeOf([]string{})); err == nil {
+		t.Fail()
+	}
+}
+
+type Details struct {
+	Date string
+}
+
+type OptionalDetails struct {
+	Details *Details
+	Count   *int
+}
+
+func TestCheckStructEmbeddingPointer(t *testing.T) {
+	defType := reflect.TypeOf(OptionalDetails{})
+
+	flag, embedded := eField.CheckStructEmbedding(defType.Field(0))
+	if !flag || embedded != reflect.TypeOf(Details{}) {
+		t.Errorf("pointer to struct not recognized as embedding")
+	}
+
+	if flag, _ := eField.CheckStructEmbedding(defType.Field(1)); flag {
+		t.Errorf("pointer to int recognized as embedding")
+	}
+}
+
+func TestWriteToFieldPointer(t *testing.T) {
+	var od OptionalDetails
+	field := reflect.ValueOf(&od).Elem().Field(0)
+
+	if err := eField.WriteToField(&field, Details{Date: "today"}); err != nil {
+		t.Fatal(err)
+	}
+	if od.Details == nil || od.Details.Date != "today" {
+		t.Errorf("pointer field not allocated and written")
+	}
+}
+
+type DetailsList struct {
+	Items []*Details
+}
+
+func TestCheckCollectionEmbeddingPointer(t *testing.T) {
+	field := reflect.TypeOf(DetailsList{}).Field(0)
+
+	flag, embedded := eField.CheckCollectionEmbedding(field)
+	if !flag || embedded != reflect.TypeOf(Details{}) {
+		t.Errorf("collection of struct pointers not recognized")
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	zeroTests := []struct {
+		Value interface{}
+		Zero  bool
+	}{
+		{primitive.NilObjectID, true},
+		{primitive.NewObjectID(), false},
+		{time.Time{}, true},
+		{time.Time{}.In(time.FixedZone("EST", -5*3600)), true},
+		{time.Now(), false},
+		{[]string{}, true},
+		{[]string{"a"}, false},
+		{map[string]int{}, true},
+		{"", true},
+		{"a", false},
+		{0, true},
+		{1, false},
+		{false, true},
+	}
+
+	for _, zt := range zeroTests {
+		if eField.IsZero(reflect.ValueOf(zt.Value)) != zt.Zero {
+			t.Errorf("%#v: expected zero to be %t", zt.Value, zt.Zero)
+		}
+	}
+}
+
+type StorageNames struct {
+	Tagged   string `bson:"tagged_name,omitempty"`
+	Untagged string `json:"untagged_json"`
+	Skipped  string `bson:"-"`
+}
+
+func TestStorageName(t *testing.T) {
+	defType := reflect.TypeOf(StorageNames{})
+	expected := []string{"tagged_name", "untagged", ""}
+
+	for i, name := range expected {
+		if stored := eField.StorageName(defType.Field(i)); stored != name {
+			t.Errorf("expected '%s', got '%s'", name, stored)
+		}
+	}
+}
+
+type WriteTarget struct {
+	Name    string
+	Age     int64
+	Level   Level
+	Score   float64
+	Created time.Time
+	Status  Status
+	ID      primitive.ObjectID
+	Stamp   primitive.DateTime
+	Small   int8
+	Count   uint
+}
+
+func TestWriteToField(t *testing.T) {
+	created := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	writeTests := []struct {
+		Field int
+		Data  interface{}
+		Valid bool
+	}{
+		// assignable
+		{0, "jane", true},
+		{1, int64(30), true},
+		{4, created, true},
+		// convertible
+		{1, float64(30), true},
+		{2, float64(3), true},
+		{3, 7, true},
+		{4, created.Format(time.RFC3339), true},
+		{5, "active", true},
+		{5, Status("active"), true},
+		{2, 3, true},
+		{2, int64(3), true},
+		{1, Level(2), true},
+		{6, "5e8f1f7b4f1a4e6d9c3b2a10", true},
+		{6, primitive.NewObjectID(), true},
+		{7, float64(1585742400000), true},
+		{7, int64(1585742400000), true},
+		// incompatible
+		{0, 65, false},
+		{1, 30.5, false},
+		{1, "30", false},
+		{3, true, false},
+		{4, "yesterday", false},
+		{5, 1, false},
+		{2, "3", false},
+		{2, 2.5, false},
+		{6, "5e8f1f7b", false},
+		{6, "not-an-object-id-at-all!", false},
+		{7, 1.5, false},
+		{7, "1585742400000", false},
+		{0, nil, false},
+	}
+
+	for _, wt := range writeTests {
+		field := reflect.ValueOf(&WriteTarget{}).Elem().Field(wt.Field)
+		err := eField.WriteToField(&field, wt.Data)
+		if (err == nil) != wt.Valid {
+			t.Errorf("field %d, data %#v: expected valid to be %t, got %v", wt.Field, wt.Data, wt.Valid, err)
+		}
+	}
+}
+
+func TestWriteToFieldConversion(t *testing.T) {
+	var target WriteTarget
+	v := reflect.ValueOf(&target).Elem()
+
+	age, level := v.Field(1), v.Field(2)
+	_ = eField.WriteToField(&age, float64(30))
+	_ = eField.WriteToField(&level, float64(3))
+
+	if target.Age != 30 || target.Level != 3 {
+		t.Errorf("numbers not converted: %v", target)
+	}
+}
+
+func TestWriteToFieldOverflow(t *testing.T) {
+	overflowTests := []struct {
+		Field int
+		Data  interface{}
+		Valid bool
+	}{
+		{8, float64(127), true},
+		{8, float64(-128), true},
+		{8, float64(300), false},
+		{8, float64(-129), false},
+		{8, 300, false},
+		{8, uint64(200), false},
+		{9, float64(42), true},
+		{9, float64(-1), false},
+		{9, -1, false},
+		{9, 1e30, false},
+		{1, float64(math.MaxInt64), false},
+		{1, uint64(math.MaxUint64), false},
+		{3, math.MaxFloat64, true},
+	}
+
+	for _, ot := range overflowTests {
+		var target WriteTarget
+		field := reflect.ValueOf(&target).Elem().Field(ot.Field)
+		err := eField.WriteToField(&field, ot.Data)
+		if (err == nil) != ot.Valid {
+			t.Errorf("field %d, data %#v: expected valid to be %t, got %v", ot.Field, ot.Data, ot.Valid, err)
+		} else if err != nil && !errors.Is(err, entityErrors.InvalidDataType) {
+			t.Errorf("expected InvalidDataType, got %v", err)
+		} else if err == nil && ot.Field == 8 && int64(target.Small) != int64(reflect.ValueOf(ot.Data).Float()) {
+			t.Errorf("data %#v written as %d", ot.Data, target.Small)
+		}
+	}
+}
+
+func TestWriteToFieldNamedTypes(t *testing.T) {
+	var target WriteTarget
+	v := reflect.ValueOf(&target).Elem()
+
+	level, status := v.Field(2), v.Field(5)
+	if err := eField.WriteToField(&level, 4); err != nil {
+		t.Errorf("int not written to named int type: %v", err)
+	}
+	if err := eField.WriteToField(&status, "archived"); err != nil {
+		t.Errorf("string not written to named string type: %v", err)
+	}
+
+	if target.Level != Level(4) || target.Status != Status("archived") {
+		t.Errorf("named types not written: %v", target)
+	}
+}
+
+func TestWriteToFieldObjectID(t *testing.T) {
+	var target WriteTarget
+	v := reflect.ValueOf(&target).Elem()
+
+	id, stamp := v.Field(6), v.Field(7)
+	if err := eField.WriteToField(&id, "5e8f1f7b4f1a4e6d9c3b2a10"); err != nil {
+		t.Fatalf("hex string not written to ObjectID: %v", err)
+	}
+	if target.ID.Hex() != "5e8f1f7b4f1a4e6d9c3b2a10" {
+		t.Errorf("unexpected ObjectID %s", target.ID.Hex())
+	}
+
+	if err := eField.WriteToField(&id, "zz8f1f7b4f1a4e6d9c3b2a10"); err == nil {
+		t.Errorf("expected malformed hex string to be rejected")
+	}
+
+	if err := eField.WriteToField(&stamp, float64(1585742400000)); err != nil {
+		t.Fatalf("epoch millis not written to DateTime: %v", err)
+	}
+	if target.Stamp != primitive.DateTime(1585742400000) {
+		t.Errorf("unexpected DateTime %d", target.Stamp)
+	}
+}
+
+func TestWriteToFieldErrorCause(t *testing.T) {
+	var target WriteTarget
+	created := reflect.ValueOf(&target).Elem().Field(4)
+
+	err := eField.WriteToField(&created, "yesterday")
+	if !errors.Is(err, entityErrors.InvalidDataType) {
+		t.Errorf("expected InvalidDataType, got %v", err)
+	}
+
+	var parseErr *time.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("parse error not wrapped: %v", err)
+	}
+}
+
+func TestHandleTokens(t *testing.T) {
+	field := reflect.StructField{Name: "F", Tag: `_hd_:"cacrx"`}
+
+	if tokens := eField.HandleTokens(field); string(tokens) != "carx" {
+		t.Errorf("unexpected tokens: %q", string(tokens))
+	}
+	if tokens := eField.HandleTokens(reflect.StructField{Name: "F"}); len(tokens) != 0 {
+		t.Errorf("expected no tokens, got %q", string(tokens))
+	}
+}
@@ -0,0 +1,79 @@
+package eField
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+normalizers maps the values accepted in a NormalizeTag to the
+functions which apply them.
+*/
+var normalizers = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+/*
+CheckNormalizeTag verifies that the NormalizeTag of the given field,
+if set, is a comma-separated list of the accepted normalizations
+("lower", "upper" and "trim") and that the field is of string kind.
+*/
+func CheckNormalizeTag(field reflect.StructField) error {
+	tag := field.Tag.Get(NormalizeTag)
+	if tag == "" {
+		return nil
+	}
+
+	if field.Type.Kind() != reflect.String {
+		return entityErrors.FieldTagUndefined(NormalizeTag, tag, field.Name)
+	}
+	for _, name := range strings.Split(tag, ",") {
+		if normalizers[name] == nil {
+			return entityErrors.FieldTagUndefined(NormalizeTag, tag, field.Name)
+		}
+	}
+	return nil
+}
+
+/*
+Normalize applies the normalizations in the NormalizeTag of the given
+field, in order, to the given value. Values which are not of string
+kind, and values for fields without a NormalizeTag, are returned as is.
+
+Normalize is used for both the values written to a field and the
+query targets compared against it, so that the two agree.
+*/
+func Normalize(field reflect.StructField, value interface{}) interface{} {
+	tag := field.Tag.Get(NormalizeTag)
+	v := reflect.ValueOf(value)
+	if tag == "" || v.Kind() != reflect.String {
+		return value
+	}
+
+	s := v.String()
+	for _, name := range strings.Split(tag, ",") {
+		if normalize := normalizers[name]; normalize != nil {
+			s = normalize(s)
+		}
+	}
+	return reflect.ValueOf(s).Convert(v.Type()).Interface()
+}
+
+/*
+NormalizeStruct normalizes, in place, the fields of the given
+(settable) struct value which have a NormalizeTag.
+*/
+func NormalizeStruct(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get(NormalizeTag) == "" || !v.Field(i).CanSet() {
+			continue
+		}
+		v.Field(i).Set(reflect.ValueOf(Normalize(field, v.Field(i).Interface())))
+	}
+}
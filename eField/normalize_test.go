@@ -0,0 +1,47 @@
+package eField_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/navaz-alani/entity/eField"
+)
+
+type NormalizeTarget struct {
+	Email  string `_norm_:"trim,lower"`
+	Code   Status `_norm_:"upper"`
+	Plain  string
+	Bogus  string `_norm_:"title"`
+	Amount int    `_norm_:"lower"`
+}
+
+func TestNormalize(t *testing.T) {
+	target := reflect.TypeOf(NormalizeTarget{})
+
+	if v := eField.Normalize(target.Field(0), "  Jane@Example.COM "); v != "jane@example.com" {
+		t.Errorf("unexpected normalized value %#v", v)
+	}
+	if v := eField.Normalize(target.Field(1), Status("ab")); v != Status("AB") {
+		t.Errorf("unexpected normalized value %#v", v)
+	}
+	if v := eField.Normalize(target.Field(0), 5); v != 5 {
+		t.Errorf("non-string value modified: %#v", v)
+	}
+
+	v := reflect.ValueOf(&NormalizeTarget{Email: " A@B.c", Code: "x", Plain: " Keep "}).Elem()
+	eField.NormalizeStruct(v)
+	expected := NormalizeTarget{Email: "a@b.c", Code: "X", Plain: " Keep "}
+	if !reflect.DeepEqual(v.Interface(), expected) {
+		t.Errorf("expected %v, got %v", expected, v.Interface())
+	}
+}
+
+func TestCheckNormalizeTag(t *testing.T) {
+	target := reflect.TypeOf(NormalizeTarget{})
+
+	for i, valid := range []bool{true, true, true, false, false} {
+		if err := eField.CheckNormalizeTag(target.Field(i)); (err == nil) != valid {
+			t.Errorf("unexpected result for '%s': %v", target.Field(i).Name, err)
+		}
+	}
+}
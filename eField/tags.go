@@ -30,4 +30,79 @@ const (
 		must be defined before a database entry.
 	*/
 	RequireTag string = "_rq_"
+	/*
+		RequireIfTag is used to tag struct fields which must
+		be defined when another field has a given value, as
+		in _rqif_:"needsShipping=true".
+	*/
+	RequireIfTag string = "_rqif_"
+	/*
+		ValidateTag is used to specify constraints which
+		a field's value must satisfy.
+	*/
+	ValidateTag string = "_va_"
+	/*
+		DefaultTag is used to provide a default value for
+		a field which is omitted from a creation payload.
+	*/
+	DefaultTag string = "_def_"
+	/*
+		CollationTag is used to specify the collation of
+		the index created for a field.
+	*/
+	CollationTag string = "_coll_"
+	/*
+		PartialIndexTag is used to specify the partial filter
+		expression of the index created for a field.
+	*/
+	PartialIndexTag string = "_ixp_"
+	/*
+		ServerTag is used to tag fields whose values are
+		assigned by the server and must never be read from
+		a request payload.
+	*/
+	ServerTag string = "_srv_"
+	/*
+		RefTag is used to tag primitive.ObjectID fields which
+		store a reference to an instance of another Entity,
+		given by its EntityID.
+	*/
+	RefTag string = "_ref_"
+	/*
+		ExtraTag is used to tag the map[string]interface{}
+		field which collects the creation payload keys that
+		do not match any other field.
+	*/
+	ExtraTag string = "_ext_"
+	/*
+		NormalizeTag is used to specify normalizations, such
+		as "lower", applied to a string field's value before
+		it is written or queried.
+	*/
+	NormalizeTag string = "_norm_"
+	/*
+		EncryptTag is used to tag string and []byte fields
+		whose values are encrypted before they are stored.
+	*/
+	EncryptTag string = "_enc_"
+)
+
+/*
+These are the values accepted for the AxisTag.
+*/
+const (
+	// AxisUnique marks an axis field whose values are unique.
+	AxisUnique = "true"
+	/*
+		AxisNonUnique marks an axis field which can be used to
+		find Entities, but whose values may be shared (and are
+		therefore not checked for duplicates).
+	*/
+	AxisNonUnique = "nonunique"
+	/*
+		AxisPrioritySeparator separates an AxisTag value from
+		the axis' priority, as in "true:1". Axes with lower
+		priorities are preferred when building a Filter.
+	*/
+	AxisPrioritySeparator = ":"
 )
@@ -0,0 +1,187 @@
+package entity
+
+import (
+	"encoding/base64"
+	"reflect"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+Encryptor encrypts and decrypts the values of the fields of an
+Entity which are tagged with eField.EncryptTag. Keys are managed
+by the Encryptor; for example, an implementation could use AES-GCM
+with a key fetched from a key management service.
+*/
+type Encryptor interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+/*
+encryptedFields returns the indices of the fields of the Entity e's
+SchemaDefinition which are encrypted at rest.
+*/
+func (e *Entity) encryptedFields() []int {
+	indices := make([]int, 0)
+	for i := 0; i < e.SchemaDefinition.NumField(); i++ {
+		if eField.IsEncrypted(e.SchemaDefinition.Field(i)) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+/*
+encrypt returns a copy of the given entity with the (non-empty)
+values of its encrypted fields replaced by their ciphertexts. The
+ciphertexts of string fields are base64 encoded.
+*/
+func (e *Entity) encrypt(entity interface{}) (interface{}, error) {
+	fields := e.encryptedFields()
+	if len(fields) == 0 {
+		return entity, nil
+	} else if e.Encryptor == nil {
+		return nil, entityErrors.EncryptorUndefined
+	}
+
+	v := reflect.New(e.SchemaDefinition).Elem()
+	v.Set(reflect.ValueOf(entity))
+	for _, i := range fields {
+		if err := encryptValue(e.Encryptor, v.Field(i)); err != nil {
+			return nil, err
+		}
+	}
+	return v.Interface(), nil
+}
+
+/*
+Decrypt returns a copy of the given entity, as read from the Entity
+e's collection, with the values of its encrypted fields decrypted.
+
+Entities read through the Entity's methods (such as
+FindOneAndUpdate, DecodeChange and CachingEntity.Read) are decrypted
+already; Decrypt is for documents decoded otherwise, for example by
+a custom query.
+*/
+func (e *Entity) Decrypt(entity interface{}) (interface{}, error) {
+	if !e.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	v := reflect.New(e.SchemaDefinition).Elem()
+	v.Set(reflect.ValueOf(entity))
+	if err := e.decrypt(v); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+/*
+decrypt decrypts the values of the encrypted fields of the given
+(settable) instance of the Entity e's SchemaDefinition in place.
+*/
+func (e *Entity) decrypt(v reflect.Value) error {
+	fields := e.encryptedFields()
+	if len(fields) == 0 {
+		return nil
+	} else if e.Encryptor == nil {
+		return entityErrors.EncryptorUndefined
+	}
+
+	for _, i := range fields {
+		if err := decryptValue(e.Encryptor, v.Field(i)); err != nil {
+			return entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+		}
+	}
+	return nil
+}
+
+/*
+encryptSpec returns the given ESpec with its Target encrypted if it
+is the value of an encrypted field, such as the value set by an
+update. Other ESpecs are returned as is.
+*/
+func (e *Entity) encryptSpec(s spec.ESpec) (spec.ESpec, error) {
+	for _, i := range e.encryptedFields() {
+		if eField.NameByPriority(e.SchemaDefinition.Field(i), eField.PriorityBsonJson) != s.Field {
+			continue
+		}
+
+		switch s.Target.(type) {
+		case string, []byte:
+		default:
+			return s, nil
+		}
+		if e.Encryptor == nil {
+			return s, entityErrors.EncryptorUndefined
+		}
+
+		target := reflect.New(reflect.TypeOf(s.Target)).Elem()
+		target.Set(reflect.ValueOf(s.Target))
+		if err := encryptValue(e.Encryptor, target); err != nil {
+			return s, err
+		}
+		s.Target = target.Interface()
+		return s, nil
+	}
+	return s, nil
+}
+
+/*
+encryptValue replaces the given (settable) string or []byte value
+by its ciphertext. Empty values are left as is.
+*/
+func encryptValue(enc Encryptor, v reflect.Value) error {
+	if v.Len() == 0 {
+		return nil
+	}
+
+	if v.Kind() == reflect.String {
+		ciphertext, err := enc.Encrypt([]byte(v.String()))
+		if err != nil {
+			return err
+		}
+		v.SetString(base64.StdEncoding.EncodeToString(ciphertext))
+		return nil
+	}
+
+	ciphertext, err := enc.Encrypt(v.Bytes())
+	if err != nil {
+		return err
+	}
+	v.SetBytes(ciphertext)
+	return nil
+}
+
+/*
+decryptValue replaces the given (settable) string or []byte value,
+produced by encryptValue, by its plaintext.
+*/
+func decryptValue(enc Encryptor, v reflect.Value) error {
+	if v.Len() == 0 {
+		return nil
+	}
+
+	if v.Kind() == reflect.String {
+		ciphertext, err := base64.StdEncoding.DecodeString(v.String())
+		if err != nil {
+			return err
+		}
+		plaintext, err := enc.Decrypt(ciphertext)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(plaintext))
+		return nil
+	}
+
+	plaintext, err := enc.Decrypt(v.Bytes())
+	if err != nil {
+		return err
+	}
+	v.SetBytes(plaintext)
+	return nil
+}
@@ -0,0 +1,120 @@
+package entity
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+// xorEncryptor is a toy Encryptor which XORs data with a key
+type xorEncryptor byte
+
+func (x xorEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
+	ciphertext := make([]byte, len(plaintext))
+	for i := range plaintext {
+		ciphertext[i] = plaintext[i] ^ byte(x)
+	}
+	return ciphertext, nil
+}
+
+func (x xorEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	return x.Encrypt(ciphertext)
+}
+
+type Patient struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Email     string             `bson:"email" _ax_:"true"`
+	Diagnosis string             `bson:"diagnosis" _enc_:"true"`
+	Scan      []byte             `bson:"scan" _enc_:"true"`
+}
+
+func TestEntity_EncryptionRoundTrip(t *testing.T) {
+	ety, err := NewEntity(TypeOf(Patient{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ety.Encryptor = xorEncryptor(0x5a)
+
+	patient := Patient{
+		ID:        primitive.NewObjectID(),
+		Email:     "jane@example.com",
+		Diagnosis: "healthy",
+		Scan:      []byte{1, 2, 3},
+	}
+	encrypted, err := ety.encrypt(patient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := encrypted.(Patient)
+	if stored.Diagnosis == patient.Diagnosis || bytes.Equal(stored.Scan, patient.Scan) {
+		t.Errorf("stored values not encrypted: %v", stored)
+	}
+	if stored.Email != patient.Email || patient.Diagnosis != "healthy" {
+		t.Errorf("unexpected modification: %v, %v", stored, patient)
+	}
+
+	raw, _ := bson.Marshal(stored)
+	ce := NewCachingEntity(ety, mapCache{})
+	ce.find = func(filter bson.M) (bson.Raw, error) { return raw, nil }
+	for i := 0; i < 2; i++ {
+		read, err := ce.Read(Patient{Email: patient.Email})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read := read.(Patient); read.Diagnosis != patient.Diagnosis || !bytes.Equal(read.Scan, patient.Scan) {
+			t.Errorf("expected %v, got %v", patient, read)
+		}
+	}
+	for _, doc := range ce.Cache.(mapCache) {
+		if doc["diagnosis"] != stored.Diagnosis {
+			t.Errorf("plaintext cached: %v", doc)
+		}
+	}
+}
+
+func TestEntity_EncryptUpdate(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(Patient{}), Encryptor: xorEncryptor(0x5a)}
+
+	var updated bson.M
+	read, err := ety.findOneAndUpdate(
+		[]spec.ESpec{{Field: "email", Target: "jane@example.com"}},
+		[]spec.ESpec{spec.Set("diagnosis", "recovered")}, true,
+		func(filter, update bson.M, opts *options.FindOneAndUpdateOptions) (bson.Raw, error) {
+			updated = update["$set"].(bson.M)
+			return bson.Marshal(bson.M{"email": "jane@example.com", "diagnosis": updated["diagnosis"]})
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated["diagnosis"] == "recovered" {
+		t.Errorf("update value not encrypted")
+	}
+	if read.(Patient).Diagnosis != "recovered" {
+		t.Errorf("updated value not decrypted: %v", read)
+	}
+}
+
+type EEncryptedInt struct {
+	Balance int `_enc_:"true"`
+}
+
+func TestEntity_EncryptorUndefined(t *testing.T) {
+	if _, err := NewEntity(TypeOf(EEncryptedInt{}), nil); err == nil {
+		t.Errorf("encrypted int field accepted")
+	}
+
+	ety := &Entity{SchemaDefinition: TypeOf(Patient{})}
+	if _, err := ety.Add(Patient{Email: "jane@example.com", Diagnosis: "healthy"}); err != entityErrors.EncryptorUndefined {
+		t.Errorf("expected EncryptorUndefined, got %v", err)
+	}
+	if _, err := ety.Decrypt(Patient{Diagnosis: "aGVsbG8="}); !errors.Is(err, entityErrors.EncryptorUndefined) {
+		t.Errorf("expected EncryptorUndefined, got %v", err)
+	}
+}
@@ -3,6 +3,9 @@ package entity
 import (
 	"context"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -19,7 +22,12 @@ import (
 TypeOf returns an EntityDefinition which can be used with
 an Entity to define a schema.
 It performs a check to ensure that the entity is of kind
-struct.
+struct; for other kinds, nil is returned, which NewEntity
+rejects with an entityErrors.IncompatibleEntityType error.
+
+TypeOf should be used wherever an Entity's SchemaDefinition
+is derived from an instance, so that definitions are checked
+consistently.
 */
 func TypeOf(entity interface{}) reflect.Type {
 	entityType := reflect.TypeOf(entity)
@@ -34,30 +42,61 @@ Filter uses the axis tags in a struct eField to
 create a BSON map which can be used to filter out
 an entity from a collection.
 
-The filter eField is chosen with the following priority:
-BSON tag "_id", Axis tag "true" (then BSON, JSON tags)
+The filter eField is chosen with the following precedence:
+the primary axis, that is the eField with BSON tag "_id",
+is used whenever it is set. Otherwise, the first set axis
+eField (AxisTag "true" or "nonunique") is used, where axes
+are ordered by their priority (e.g. 1 for "true:1", see
+eField.AxisPrioritySeparator), lowest first, then axes
+without a priority; ties are broken by declaration order.
+The filter eField's name is chosen by BSON, then JSON tag
 and lastly the eField name.
 
-Note that the eField with the BSON tag "_id" must be of
-type primitive.ObjectID so that comparison succeeds.
+Fields holding their zero value (see eField.IsZero), such
+as primitive.NilObjectID, 0, false or a zero time.Time,
+are considered unset and are never used in the filter.
+
+If the axis eField is indexed with a collation (see
+eField.CollationTag), queries using the filter must specify
+the same collation in order to use the index and to match
+values accordingly (e.g. case-insensitively). Alternatively,
+the eField.NormalizeTag can be used to case-fold an axis
+eField; the filter value is then normalized likewise.
 */
 func Filter(entity interface{}) bson.M {
 	t := reflect.TypeOf(entity)
 	v := reflect.ValueOf(entity)
 
+	var axes []int
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		filterValue := v.Field(i).Interface()
+		if eField.IsZero(v.Field(i)) {
+			continue
+		}
 
-		if tag := field.Tag.Get(eField.BSONTag); tag == "_id" && filterValue != primitive.NilObjectID {
-			return bson.M{"_id": filterValue}
-		} else if tag := field.Tag.Get(eField.AxisTag); tag == "true" && filterValue != "" {
-			var filterFieldName = eField.NameByPriority(field, eField.PriorityBsonJson)
-			return bson.M{filterFieldName: filterValue}
+		if eField.StorageName(field) == "_id" {
+			return bson.M{"_id": v.Field(i).Interface()}
+		} else if eField.IsAxis(field) {
+			axes = append(axes, i)
 		}
 	}
 
-	return nil
+	if len(axes) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(axes, func(a, b int) bool {
+		pa, okA := eField.AxisPriority(t.Field(axes[a]))
+		pb, okB := eField.AxisPriority(t.Field(axes[b]))
+		if okA != okB {
+			return okA
+		}
+		return pa < pb
+	})
+
+	field := t.Field(axes[0])
+	var filterFieldName = eField.NameByPriority(field, eField.PriorityBsonJson)
+	return bson.M{filterFieldName: eField.Normalize(field, v.Field(axes[0]).Interface())}
 }
 
 /*
@@ -76,7 +115,7 @@ func ToBSON(entity interface{}) bson.M {
 
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if tag := field.Tag.Get(eField.BSONTag); tag == "_id" {
+		if eField.StorageName(field) == "_id" {
 			continue
 		}
 
@@ -109,6 +148,167 @@ type Entity struct {
 		should be maintained.
 	*/
 	PStorage *mongo.Collection
+	/*
+		Validators maps the index of a field in the
+		SchemaDefinition to the Validator compiled from
+		its eField.ValidateTag.
+	*/
+	Validators map[int]Validator
+	/*
+		CheckDuplicateAxis specifies whether Add should check
+		the collection for documents sharing an axis value with
+		the entity being added, before inserting it.
+
+		This check is a convenience for collections without a
+		unique index on their axis fields; it is racy (concurrent
+		inserts can still produce duplicates) and is therefore no
+		guarantee of uniqueness.
+	*/
+	CheckDuplicateAxis bool
+	/*
+		IDGenerator generates the IDs of the entities added
+		using Add, such as UUIDs or ULIDs, in place of the
+		database. If it is nil, the database assigns IDs.
+	*/
+	IDGenerator IDGenerator
+	/*
+		Encryptor encrypts the values of the fields tagged with
+		eField.EncryptTag before they are stored, and decrypts
+		them when they are read. It is required if the
+		SchemaDefinition has encrypted fields.
+
+		Encrypted fields are stored as ciphertexts and therefore
+		cannot be queried (or indexed) by value.
+	*/
+	Encryptor Encryptor
+	/*
+		ResolveReference returns the Entity with the given
+		EntityID, the value of an eField.RefTag, or nil if
+		there is none. It is used by ReadPopulated to read
+		referenced documents from their Entities' collections,
+		and is set by the multiplexer for the Entities which
+		it manages.
+	*/
+	ResolveReference func(entityID string) *Entity
+	/*
+		computed stores the computed fields of the
+		Entity, in order of evaluation.
+	*/
+	computed []computedField
+	/*
+		requestFields maps the RequestIDs of the fields of
+		the SchemaDefinition to their indices. It is built
+		by NewEntity; see FieldByRequestID.
+	*/
+	requestFields map[string]int
+	/*
+		fields describes the fields of the SchemaDefinition,
+		in declaration order. It is built by NewEntity; see
+		Fields.
+	*/
+	fields []FieldInfo
+}
+
+/*
+NewEntity returns an Entity for the given definition, using
+the given collection for persistent storage.
+
+The validation tags of the definition's fields are compiled
+into the Entity's Validators; any malformed tags result in
+an error, as do AxisTag values other than eField.AxisUnique
+and eField.AxisNonUnique (optionally with a priority, see
+eField.CheckAxisTag), unrecognized IndexTag values
+(see Optimize), malformed NormalizeTag values (see
+eField.CheckNormalizeTag), malformed RequireIfTag values
+(see CheckRequired) and malformed EncryptTag values (see
+eField.CheckEncryptTag).
+*/
+func NewEntity(definition reflect.Type, storage *mongo.Collection) (*Entity, error) {
+	if definition == nil || definition.Kind() != reflect.Struct {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	for i := 0; i < definition.NumField(); i++ {
+		field := definition.Field(i)
+		if err := eField.CheckAxisTag(field); err != nil {
+			return nil, err
+		}
+		if err := eField.CheckNormalizeTag(field); err != nil {
+			return nil, err
+		}
+		if err := eField.CheckEncryptTag(field); err != nil {
+			return nil, err
+		}
+		if tag := field.Tag.Get(eField.RequireIfTag); tag != "" && tag != "-" {
+			if _, err := parseRequireIf(definition, tag); err != nil {
+				return nil, err
+			}
+		}
+		if tag := field.Tag.Get(eField.IndexTag); tag != "" && tag != "-" {
+			if _, err := indexValue(field); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	validators, err := CompileValidators(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entity{
+		SchemaDefinition: definition,
+		PStorage:         storage,
+		Validators:       validators,
+		requestFields:    requestFieldIndex(definition),
+		fields:           fieldInfos(definition, nil),
+	}, nil
+}
+
+/*
+requestFieldIndex maps the RequestID (the Request/JSON/BSON/field
+name, in that priority) of each field of the given definition to
+the field's index.
+*/
+func requestFieldIndex(definition reflect.Type) map[string]int {
+	index := make(map[string]int)
+	for i := 0; i < definition.NumField(); i++ {
+		index[eField.NameByPriority(definition.Field(i), eField.PriorityRequest)] = i
+	}
+	return index
+}
+
+/*
+FieldByRequestID returns the field of the Entity e's SchemaDefinition
+which is identified by the given RequestID (the key used for the field
+in request payloads), and whether such a field exists.
+
+For Entities created by NewEntity, the lookup uses an index built
+once; otherwise, the fields are scanned.
+*/
+func (e *Entity) FieldByRequestID(requestID string) (reflect.StructField, bool) {
+	index := e.requestFields
+	if index == nil {
+		index = requestFieldIndex(e.SchemaDefinition)
+	}
+
+	i, ok := index[requestID]
+	if !ok {
+		return reflect.StructField{}, false
+	}
+	return e.SchemaDefinition.Field(i), true
+}
+
+/*
+WithStorage returns a copy of the Entity e which uses the given
+collection for persistent storage. This can be used to route the
+CRUD operations of an Entity to one of several collections, such
+as a collection per tenant.
+*/
+func (e *Entity) WithStorage(storage *mongo.Collection) *Entity {
+	routed := *e
+	routed.PStorage = storage
+	return &routed
 }
 
 /*
@@ -122,45 +322,347 @@ func (e *Entity) typeCheck(entity interface{}) bool {
 /*
 Add adds the given entity to the Entity e.
 The given entity is expected to be of struct kind.
+The values of any computed fields (see AddComputedField)
+are populated before insertion, and the values of fields
+with a NormalizeTag are normalized. If any required field
+is empty, an error wrapping entityErrors.BodyIncomplete is
+returned (see CheckRequired). The values of encrypted fields
+are encrypted using the Entity's Encryptor. If the Entity
+has an IDGenerator, the ID is generated (see GenerateID).
+The entity's Validators are not run: use Validate first.
 
 This addition represents an actual insertion to the
 underlying database collection pointed at by e.
 
+If the Entity's CheckDuplicateAxis is set and a document
+sharing an axis value with the given entity exists, an
+entityErrors.DuplicateAxis error is returned.
+
 The added document's database ID is then returned, or
 any entityErrors that occurred.
+
+IDs which are not of type primitive.ObjectID, such as
+string UUIDs from an IDGenerator, cannot be returned and
+primitive.NilObjectID is returned in their place. To learn
+such an ID, populate it using GenerateID before calling
+Add, which keeps IDs that are already set:
+
+	user, err := users.GenerateID(user)
+	if err == nil {
+		_, err = users.Add(user)
+	}
 */
 func (e *Entity) Add(entity interface{}) (primitive.ObjectID, error) {
+	return e.add(entity, func(doc bson.M) (*mongo.InsertOneResult, error) {
+		return e.PStorage.InsertOne(context.TODO(), doc)
+	})
+}
+
+/*
+add prepares the given entity for insertion as described by Add
+and uses the given insert function to insert it.
+*/
+func (e *Entity) add(entity interface{}, insert func(doc bson.M) (*mongo.InsertOneResult, error)) (primitive.ObjectID, error) {
 	nilID := primitive.NilObjectID
 
 	if !e.typeCheck(entity) {
 		return nilID, entityErrors.IncompatibleEntityType
 	}
 
+	entity, err := e.applyComputed(entity)
+	if err != nil {
+		return nilID, err
+	}
+	entity = normalize(entity)
+
 	dbDoc := ToBSON(entity)
 	if dbDoc == nil || len(dbDoc) == 0 {
 		return nilID, entityErrors.BodyIncomplete
 	}
+	if err := e.CheckRequired(entity); err != nil {
+		return nilID, err
+	}
 
-	// TODO: add check for whether the defined axis fields are unique
+	if e.CheckDuplicateAxis {
+		err := e.checkDuplicateAxis(entity, func(filter bson.M) (bool, error) {
+			err := e.PStorage.FindOne(context.TODO(), filter).Err()
+			if err == mongo.ErrNoDocuments {
+				return false, nil
+			}
+			return err == nil, err
+		})
+		if err != nil {
+			return nilID, err
+		}
+	}
 
-	res, err := e.PStorage.InsertOne(context.TODO(), dbDoc)
+	if entity, err = e.GenerateID(entity); err != nil {
+		return nilID, err
+	}
+	stored, err := e.encrypt(entity)
+	if err != nil {
+		return nilID, err
+	}
+
+	dbDoc = ToBSON(stored)
+	if e.IDGenerator != nil {
+		dbDoc["_id"] = idValue(stored)
+	}
+
+	res, err := insert(dbDoc)
 	if err != nil {
 		return nilID, err
 	}
 
 	addedID, ok := res.InsertedID.(primitive.ObjectID)
-	if !ok {
+	if !ok && e.IDGenerator != nil {
+		return nilID, nil
+	} else if !ok {
 		return nilID, entityErrors.AddedIDParseFail
 	}
 
 	return addedID, nil
 }
 
+/*
+AddRaw adds the given entity to the Entity e as is, for trusted
+internal callers such as data migrations which import legacy data.
+
+UNSAFE: unlike Add, AddRaw does not check required fields, populate
+computed fields, normalize fields, encrypt fields or check for
+duplicate axis values. Neither runs the entity's Validators, which
+callers of Add are expected to run first (see Validate). AddRaw must
+never be used with client-supplied data.
+
+The added document's database ID is returned, or any entityErrors
+that occurred.
+*/
+func (e *Entity) AddRaw(ctx context.Context, entity interface{}) (primitive.ObjectID, error) {
+	return e.addRaw(entity, func(doc bson.M) (*mongo.InsertOneResult, error) {
+		return e.PStorage.InsertOne(ctx, doc)
+	})
+}
+
+/*
+addRaw type checks the given entity and uses the given insert
+function to insert its BSON encoding.
+*/
+func (e *Entity) addRaw(entity interface{}, insert func(doc bson.M) (*mongo.InsertOneResult, error)) (primitive.ObjectID, error) {
+	if !e.typeCheck(entity) {
+		return primitive.NilObjectID, entityErrors.IncompatibleEntityType
+	}
+
+	res, err := insert(ToBSON(entity))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	addedID, ok := res.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return primitive.NilObjectID, entityErrors.AddedIDParseFail
+	}
+	return addedID, nil
+}
+
+/*
+normalize returns a copy of the given entity with the values of
+its fields normalized according to their eField.NormalizeTag.
+*/
+func normalize(entity interface{}) interface{} {
+	v := reflect.New(reflect.TypeOf(entity)).Elem()
+	v.Set(reflect.ValueOf(entity))
+	eField.NormalizeStruct(v)
+	return v.Interface()
+}
+
+/*
+CheckRequired reports the fields of the given entity which are
+required but missing, using an error wrapping
+entityErrors.BodyIncomplete (see entityErrors.MissingFields).
+
+A field is missing if it is a nil pointer (or interface), an
+empty string, slice or map, primitive.NilObjectID or a zero
+time.Time. Other values, such as a false bool or a 0 number,
+are valid values and never count as missing.
+
+A field is required if its RequireTag is "true", or if the
+condition given by its RequireIfTag holds: for example, a field
+tagged with _rqif_:"needsShipping=true" is required when the
+field identified by the RequestID "needsShipping" is true.
+Fields are named by their JSON/BSON/field name, in declaration
+order. A nil error is returned if no required field is empty.
+*/
+func (e *Entity) CheckRequired(entity interface{}) error {
+	if !e.typeCheck(entity) {
+		return entityErrors.IncompatibleEntityType
+	}
+
+	t := reflect.TypeOf(entity)
+	v := reflect.ValueOf(entity)
+	missing := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		required := field.Tag.Get(eField.RequireTag) == "true"
+		if tag := field.Tag.Get(eField.RequireIfTag); !required && tag != "" && tag != "-" {
+			condition, err := parseRequireIf(t, tag)
+			if err != nil {
+				return err
+			}
+			required = condition.holds(v)
+		}
+
+		if required && isMissing(v.Field(i)) {
+			missing = append(missing, eField.NameByPriority(field, eField.PriorityJsonBson))
+		}
+	}
+
+	if len(missing) != 0 {
+		return entityErrors.MissingFields(missing)
+	}
+	return nil
+}
+
+/*
+isMissing returns whether the given value of a required field
+is missing (see CheckRequired).
+*/
+func isMissing(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	switch v.Type() {
+	case reflect.TypeOf(primitive.ObjectID{}), reflect.TypeOf(time.Time{}):
+		return eField.IsZero(v)
+	}
+	return false
+}
+
+/*
+requireCondition is the parsed form of an eField.RequireIfTag
+value: it holds when the field at index has the given value.
+*/
+type requireCondition struct {
+	index int
+	value interface{}
+}
+
+/*
+parseRequireIf parses the given eField.RequireIfTag value, of the
+form "<RequestID>=<value>", against the given definition. The value
+is parsed into the type of the referenced field (see
+eField.ParseValue). An entityErrors.TagUndefined error is returned
+if the value is malformed, references an unknown field or cannot be
+parsed.
+*/
+func parseRequireIf(definition reflect.Type, tag string) (*requireCondition, error) {
+	parts := strings.SplitN(tag, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, entityErrors.TagUndefined(eField.RequireIfTag, tag)
+	}
+
+	index, ok := requestFieldIndex(definition)[parts[0]]
+	if !ok {
+		return nil, entityErrors.TagUndefined(eField.RequireIfTag, tag)
+	}
+
+	value, err := eField.ParseValue(parts[1], definition.Field(index).Type)
+	if err != nil {
+		return nil, entityErrors.TagUndefined(eField.RequireIfTag, tag)
+	}
+	return &requireCondition{index: index, value: value}, nil
+}
+
+/*
+holds reports whether the condition holds for the given
+entity value.
+*/
+func (rc *requireCondition) holds(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Field(rc.index).Interface(), rc.value)
+}
+
+/*
+checkDuplicateAxis uses the given exists function to check
+whether a document sharing any of the (non-empty) unique axis
+values (eField.AxisUnique) of the given entity exists. If so,
+entityErrors.DuplicateAxis is returned.
+*/
+func (e *Entity) checkDuplicateAxis(entity interface{}, exists func(filter bson.M) (bool, error)) error {
+	t := reflect.TypeOf(entity)
+	v := reflect.ValueOf(entity)
+
+	axes := bson.A{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !eField.IsUniqueAxis(field) || eField.IsZero(v.Field(i)) {
+			continue
+		}
+
+		var fName = eField.NameByPriority(field, eField.PriorityBsonJson)
+		axes = append(axes, bson.M{fName: v.Field(i).Interface()})
+	}
+
+	if len(axes) == 0 {
+		return nil
+	}
+
+	found, err := exists(bson.M{"$or": axes})
+	if err != nil {
+		return err
+	} else if found {
+		return entityErrors.DuplicateAxis
+	}
+	return nil
+}
+
+/*
+AxisValues returns the values of the axis fields (see eField.IsAxis)
+of the given entity, keyed by their BSON/JSON/field name (in that
+priority). Fields with zero values are omitted; if all axis fields
+are zero, entityErrors.UndefinedAxis is returned.
+
+AxisValues does not apply the precedence used by Filter: the
+primary axis (the "_id" field) is not an axis field and is never
+included, and the returned map carries no axis priorities. Use
+Filter to choose the single field to query by.
+*/
+func (e *Entity) AxisValues(entity interface{}) (map[string]interface{}, error) {
+	if !e.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	t := reflect.TypeOf(entity)
+	v := reflect.ValueOf(entity)
+
+	values := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !eField.IsAxis(field) || eField.IsZero(v.Field(i)) {
+			continue
+		}
+
+		values[eField.NameByPriority(field, eField.PriorityBsonJson)] = v.Field(i).Interface()
+	}
+
+	if len(values) == 0 {
+		return nil, entityErrors.UndefinedAxis
+	}
+	return values, nil
+}
+
 /*
 Edit uses the axes of the given entity to find a
 document in the underlying database collection pointed
 at by e and edits it according to the specified spec.
 
+If the spec sets the value of an encrypted field, the
+value is encrypted using the Entity's Encryptor.
+
 An error is returned which, if all went alright, should
 be expected to be nil.
 */
@@ -174,6 +676,11 @@ func (e *Entity) Edit(entity interface{}, spec spec.ESpec) error {
 		return entityErrors.UndefinedAxis
 	}
 
+	spec, err := e.encryptSpec(spec)
+	if err != nil {
+		return err
+	}
+
 	res := e.PStorage.FindOneAndUpdate(
 		context.TODO(), filter, spec.ToUpdateSpec())
 	return res.Err()
@@ -185,7 +692,8 @@ matches any documents in the underlying database collection
 pointed at by e.
 If any documents are matched and dest is non-nil, the matched
 document will be decoded into dest, after which the fields can
-be accessed.
+be accessed. If dest points to an instance of the Entity's
+SchemaDefinition, its encrypted fields are decrypted.
 If dest is left nil, the result is not decoded.
 
 An error is also returned which, if all went alright, should
@@ -206,9 +714,12 @@ func (e *Entity) Exists(entity, dest interface{}) (bool, error) {
 		if dest != nil {
 			err := res.Decode(dest)
 			if err != nil {
-				return true, entityErrors.DBDecodeFail
+				return true, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
 			}
 
+			if v := reflect.ValueOf(dest); v.Kind() == reflect.Ptr && v.Elem().Type() == e.SchemaDefinition {
+				return true, e.decrypt(v.Elem())
+			}
 			return true, nil
 		}
 		return true, nil
@@ -216,6 +727,32 @@ func (e *Entity) Exists(entity, dest interface{}) (bool, error) {
 	return false, res.Err()
 }
 
+/*
+ExistsWhere returns whether any document in the underlying database
+collection pointed at by e matches the given query ESpecs, which are
+combined using BuildFilter. Unlike Exists, no document is read: at
+most one document is counted, which makes ExistsWhere suitable for
+cheap checks such as whether an email address is taken.
+*/
+func (e *Entity) ExistsWhere(ctx context.Context, filter []spec.ESpec) (bool, error) {
+	return e.existsWhere(filter, func(filter bson.M) (bool, error) {
+		count, err := e.PStorage.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+		return count > 0, err
+	})
+}
+
+/*
+existsWhere builds the filter for the given specs and uses the given
+exists function to check whether any document matches it.
+*/
+func (e *Entity) existsWhere(specs []spec.ESpec, exists func(filter bson.M) (bool, error)) (bool, error) {
+	filter, err := e.BuildFilter(specs)
+	if err != nil {
+		return false, err
+	}
+	return exists(filter)
+}
+
 /*
 Delete deletes the given entity from the underlying database
 collection pointed at by e.
@@ -245,47 +782,191 @@ Optimize is a function that creates indexes for the axis fields
 in the underlying EntityDefinition type.
 
 Optimize searches for "index" tags in the fields of the type
-underlying the EntityDefinition. A eField with with an "index" tag
+underlying the EntityDefinition. An axis eField with an "index" tag
 is optimized. The IndexModel entry for this eField has the Key
 corresponding to the BSON/JSON/eField name (in that priority) and
-value corresponding to the "index" tag value if non-empty and
-a default index type of "text".
+value corresponding to the "index" tag value: one of "text", "1",
+"-1" (ascending and descending), "2dsphere" or "hashed". The value
+"true" selects a text index. Other values result in an error.
+
+Fields with a collation tag (eField.CollationTag) are indexed
+using that collation. Its value is a locale, optionally followed by
+a colon and a comparison strength, which defaults to 2 (so that the
+index is case-insensitive). For example "en" or "en:1". Optimized
+fields sharing the same collation are indexed together.
+Note that queries, such as those using axis filters, only use a
+collated index if they specify the same collation.
+
+Fields with a partial index tag (eField.PartialIndexTag) are indexed
+with a partial filter expression, so that only documents matching it
+are indexed. Its value is a comma separated list of "field:value"
+conditions, for example "deleted:false". Values are parsed as bools
+or numbers where possible and are strings otherwise. Optimized fields
+are grouped into the same index only if they share both the collation
+and the partial filter.
 */
 func (e *Entity) Optimize() error {
-	keys := bson.D{}
+	index, err := e.indexModels()
+	if err != nil {
+		return err
+	}
+
+	if len(index) == 0 {
+		return nil
+	}
+
+	opts := options.CreateIndexes().SetMaxTime(3 * time.Second)
+	_, err = e.PStorage.Indexes().CreateMany(context.TODO(), index, opts)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+IndexModels returns the IndexModels which Optimize creates for the
+Entity e, for callers which create indexes themselves, for example
+in batches (see multiplexer.EMux.OptimizeAll).
+*/
+func (e *Entity) IndexModels() ([]mongo.IndexModel, error) {
+	return e.indexModels()
+}
+
+/*
+indexModels returns the IndexModels for the fields of the Entity
+e which are to be optimized. Fields which share the same index
+options are grouped into the same (compound) index, in order of
+declaration.
+*/
+func (e *Entity) indexModels() ([]mongo.IndexModel, error) {
+	index := make([]mongo.IndexModel, 0)
+	groups := make(map[string]int)
 
 	for i := 0; i < e.SchemaDefinition.NumField(); i++ {
 		field := e.SchemaDefinition.Field(i)
 
 		// Ignore eField if IndexTag not set
 		indexTag := field.Tag.Get(eField.IndexTag)
-		axisTag := field.Tag.Get(eField.AxisTag)
-		if !(indexTag == "true" && axisTag == "true") {
+		if indexTag == "" || indexTag == "-" || !eField.IsAxis(field) {
 			continue
 		}
 
 		var key = eField.NameByPriority(field, eField.PriorityBsonJson)
 
-		var indexType string
-		if !(indexTag == "" || indexTag == "-") {
-			indexType = indexTag
-		} else {
-			// TODO: infer index type from eField type
-			indexType = "text"
+		indexType, err := indexValue(field)
+		if err != nil {
+			return nil, err
+		}
+
+		collationTag := field.Tag.Get(eField.CollationTag)
+		partialTag := field.Tag.Get(eField.PartialIndexTag)
+		groupKey := collationTag + "\x00" + partialTag
+
+		group, ok := groups[groupKey]
+		if !ok {
+			model := mongo.IndexModel{Keys: bson.D{}}
+			if collationTag != "" || partialTag != "" {
+				model.Options = options.Index()
+			}
+			if collationTag != "" {
+				collation, err := parseCollation(collationTag)
+				if err != nil {
+					return nil, err
+				}
+				model.Options.SetCollation(collation)
+			}
+			if partialTag != "" {
+				expression, err := parsePartialFilter(partialTag)
+				if err != nil {
+					return nil, err
+				}
+				model.Options.SetPartialFilterExpression(expression)
+			}
+
+			group = len(index)
+			groups[groupKey] = group
+			index = append(index, model)
 		}
 
-		keys = append(keys, bson.E{Key: key, Value: indexType})
+		index[group].Keys = append(index[group].Keys.(bson.D), bson.E{Key: key, Value: indexType})
 	}
 
-	if len(keys) == 0 {
-		return nil
+	return index, nil
+}
+
+/*
+indexTypes maps the accepted values of the eField.IndexTag to the
+value of the field's key in an IndexModel. "true" selects the default
+index type, a text index.
+*/
+var indexTypes = map[string]interface{}{
+	"true":     "text",
+	"text":     "text",
+	"1":        1,
+	"-1":       -1,
+	"2dsphere": "2dsphere",
+	"hashed":   "hashed",
+}
+
+/*
+indexValue returns the IndexModel key value for the eField.IndexTag of
+the given field. An error naming the field is returned if the tag has
+an unrecognized value.
+*/
+func indexValue(field reflect.StructField) (interface{}, error) {
+	tag := field.Tag.Get(eField.IndexTag)
+	value, ok := indexTypes[tag]
+	if !ok {
+		return nil, entityErrors.FieldTagUndefined(eField.IndexTag, tag, field.Name)
 	}
-	index := []mongo.IndexModel{{Keys: keys}}
+	return value, nil
+}
 
-	opts := options.CreateIndexes().SetMaxTime(3 * time.Second)
-	_, err := e.PStorage.Indexes().CreateMany(context.TODO(), index, opts)
-	if err != nil {
-		return err
+/*
+parsePartialFilter parses the value of a eField.PartialIndexTag
+into a partial filter expression.
+*/
+func parsePartialFilter(tag string) (bson.M, error) {
+	expression := bson.M{}
+
+	for _, condition := range strings.Split(tag, ",") {
+		parts := strings.SplitN(condition, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, entityErrors.TagUndefined(eField.PartialIndexTag, tag)
+		}
+
+		var value interface{} = parts[1]
+		if b, err := strconv.ParseBool(parts[1]); err == nil {
+			value = b
+		} else if n, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+			value = n
+		} else if f, err := strconv.ParseFloat(parts[1], 64); err == nil {
+			value = f
+		}
+		expression[parts[0]] = value
 	}
-	return nil
+
+	return expression, nil
+}
+
+/*
+parseCollation parses the value of a eField.CollationTag into
+a Collation.
+*/
+func parseCollation(tag string) (*options.Collation, error) {
+	parts := strings.SplitN(tag, ":", 2)
+	collation := &options.Collation{Locale: parts[0], Strength: 2}
+
+	if len(parts) == 2 {
+		strength, err := strconv.Atoi(parts[1])
+		if err != nil || strength < 1 || strength > 5 {
+			return nil, entityErrors.TagUndefined(eField.CollationTag, tag)
+		}
+		collation.Strength = strength
+	}
+
+	if collation.Locale == "" {
+		return nil, entityErrors.TagUndefined(eField.CollationTag, tag)
+	}
+	return collation, nil
 }
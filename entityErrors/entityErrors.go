@@ -1,6 +1,9 @@
 package entityErrors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 var (
 	/*
@@ -34,4 +37,127 @@ var (
 		when attempting to add an incomplete Entity to the database.
 	*/
 	BodyIncomplete = fmt.Errorf("entity body incomplete- will not add")
+	/*
+		DuplicateAxis is an error which signifies that an Entity
+		could not be added because another Entity in the collection
+		shares one of its axis values (Axis Policy).
+	*/
+	DuplicateAxis = fmt.Errorf("duplicate entity axis value (Axis Policy)")
+	/*
+		NotFound is an error which signifies that no document in an
+		Entity's collection matched the filter of an operation.
+	*/
+	NotFound = fmt.Errorf("no matching entity found")
+	/*
+		EncryptorUndefined is an error which signifies that an
+		Entity has encrypted fields but no Encryptor with which
+		to encrypt or decrypt their values.
+	*/
+	EncryptorUndefined = fmt.Errorf("no encryptor for encrypted fields")
 )
+
+/*
+TagUndefined is an error representing that a tag has been
+given a value which cannot be understood.
+*/
+func TagUndefined(tag, value string) error {
+	return fmt.Errorf("undefined value '%s' for '%s' tag", value, tag)
+}
+
+/*
+MissingFields is an error representing that the given fields were
+omitted from an Entity's body. It wraps BodyIncomplete.
+*/
+func MissingFields(fields []string) error {
+	return fmt.Errorf("%w: missing %s", BodyIncomplete, strings.Join(fields, ", "))
+}
+
+/*
+ValidationFailure is the type of the errors returned by
+ValidationFail. It can be used with errors.As to obtain the
+name of the field which failed validation.
+*/
+type ValidationFailure struct {
+	Field string
+}
+
+func (vf *ValidationFailure) Error() string {
+	return fmt.Sprintf("validation failed for field '%s'", vf.Field)
+}
+
+/*
+ValidationFail is an error representing that the value of
+an Entity's field does not satisfy its validation tag.
+*/
+func ValidationFail(field string) error {
+	return &ValidationFailure{Field: field}
+}
+
+/*
+FieldTagUndefined is an error representing that a tag on the
+given field has been given a value which cannot be understood.
+*/
+func FieldTagUndefined(tag, value, field string) error {
+	return fmt.Errorf("undefined value '%s' for '%s' tag on '%s'", value, tag, field)
+}
+
+/*
+UndefinedPath is an error representing that a (dotted) field
+path does not exist in an Entity's definition.
+*/
+func UndefinedPath(path string) error {
+	return fmt.Errorf("path '%s' undefined in entity definition", path)
+}
+
+/*
+PatchOperationInvalid is an error representing that a JSON
+Patch operation cannot be applied to an Entity.
+*/
+func PatchOperationInvalid(op, path string) error {
+	return fmt.Errorf("invalid patch operation '%s' on '%s'", op, path)
+}
+
+/*
+FieldNotEditable is an error representing that a change was given
+for a field which is not classified as editable.
+*/
+func FieldNotEditable(field string) error {
+	return fmt.Errorf("field '%s' is not editable", field)
+}
+
+/*
+causeError is an error which is matched by its sentinel (using
+errors.Is) and unwraps to the underlying cause.
+*/
+type causeError struct {
+	sentinel error
+	cause    error
+}
+
+func (e *causeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.sentinel, e.cause)
+}
+
+// Is reports whether the target is the error's sentinel.
+func (e *causeError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// Unwrap returns the error's cause.
+func (e *causeError) Unwrap() error {
+	return e.cause
+}
+
+/*
+Wrap returns an error which both matches the given sentinel, such
+as DBDecodeFail, and wraps the given underlying cause. This allows
+callers to check the kind of failure using errors.Is, while the
+cause remains available to errors.Is and errors.As. If the cause
+is nil, the sentinel is returned.
+*/
+func Wrap(sentinel, cause error) error {
+	if cause == nil {
+		return sentinel
+	}
+	return &causeError{sentinel: sentinel, cause: cause}
+}
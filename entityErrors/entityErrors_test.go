@@ -0,0 +1,61 @@
+package entityErrors_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+// sentinels lists the sentinel errors referenced across the library.
+var sentinels = map[string]error{
+	"IncompatibleEntityType":   entityErrors.IncompatibleEntityType,
+	"UndefinedAxis":            entityErrors.UndefinedAxis,
+	"DBDecodeFail":             entityErrors.DBDecodeFail,
+	"AddedIDParseFail":         entityErrors.AddedIDParseFail,
+	"BodyIncomplete":           entityErrors.BodyIncomplete,
+	"DuplicateAxis":            entityErrors.DuplicateAxis,
+	"NotFound":                 entityErrors.NotFound,
+	"EncryptorUndefined":       entityErrors.EncryptorUndefined,
+	"DBUninitialized":          entityErrors.DBUninitialized,
+	"IncompleteEntityMetadata": entityErrors.IncompleteEntityMetadata,
+	"NoClassificationFields":   entityErrors.NoClassificationFields,
+	"InvalidDataType":          entityErrors.InvalidDataType,
+	"InvalidEntityID":          entityErrors.InvalidEntityID,
+	"EmbeddedWriteDataInvalid": entityErrors.EmbeddedWriteDataInvalid,
+	"InvalidEntityLink":        entityErrors.InvalidEntityLink,
+	"PayloadDecodeFailed":      entityErrors.PayloadDecodeFailed,
+	"UnregisteredEntityType":   entityErrors.UnregisteredEntityType,
+	"StreamingUnsupported":     entityErrors.StreamingUnsupported,
+	"MuxCtxNotFound":           entityErrors.MuxCtxNotFound,
+	"MuxCtxCorrupt":            entityErrors.MuxCtxCorrupt,
+}
+
+func TestSentinels(t *testing.T) {
+	messages := make(map[string]string)
+	for name, err := range sentinels {
+		if err == nil || err.Error() == "" {
+			t.Errorf("%s: undefined", name)
+			continue
+		}
+		if other, ok := messages[err.Error()]; ok {
+			t.Errorf("%s: message shared with %s", name, other)
+		}
+		messages[err.Error()] = name
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("unexpected EOF")
+	err := entityErrors.Wrap(entityErrors.DBDecodeFail, cause)
+
+	if !errors.Is(err, entityErrors.DBDecodeFail) || !errors.Is(err, cause) {
+		t.Errorf("wrapped error does not match sentinel and cause: %v", err)
+	}
+	if errors.Is(err, entityErrors.InvalidDataType) {
+		t.Errorf("wrapped error matches unrelated sentinel")
+	}
+	if entityErrors.Wrap(entityErrors.DBDecodeFail, nil) != entityErrors.DBDecodeFail {
+		t.Errorf("nil cause not unwrapped to sentinel")
+	}
+}
@@ -4,7 +4,11 @@ repeated use within the library.
 */
 package entityErrors
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 var (
 	/*
@@ -18,11 +22,59 @@ var (
 		as a result of undefined tags or tags with empty values.
 	*/
 	IncompleteEntityMetadata = fmt.Errorf("insufficient entity metadata")
-	NoClassificationFields   = fmt.Errorf("no classification fields")
-	InvalidDataType          = fmt.Errorf("data type invalid")
-	InvalidEntityID          = fmt.Errorf("entityID invalid")
+	/*
+		NoClassificationFields is an error which signifies that an
+		Entity has no fields classified for the requested middleware,
+		such as creation fields for the creation middleware.
+	*/
+	NoClassificationFields = fmt.Errorf("no classification fields")
+	/*
+		InvalidDataType is an error which signifies that a value
+		cannot be written to (or parsed into) a field's type.
+	*/
+	InvalidDataType = fmt.Errorf("data type invalid")
+	/*
+		InvalidEntityID is an error which signifies that an EntityID
+		is not registered with a multiplexer.
+	*/
+	InvalidEntityID = fmt.Errorf("entityID invalid")
+	/*
+		EmbeddedWriteDataInvalid is an error which signifies that the
+		payload for an embedded Entity (or collection of Entities)
+		does not have the expected shape, or could not be written.
+	*/
 	EmbeddedWriteDataInvalid = fmt.Errorf("embedded write data invalid")
-	InvalidEntityLink        = fmt.Errorf("invalid entity link")
+	/*
+		InvalidEntityLink is an error which signifies that a field
+		embedding (or referencing) another Entity is not linked to
+		that Entity's metadata, for example because it is not
+		registered with the multiplexer.
+	*/
+	InvalidEntityLink = fmt.Errorf("invalid entity link")
+	/*
+		PayloadDecodeFailed is an error which signifies that a request
+		payload is not well-formed JSON, or does not have the shape of
+		an Entity. It wraps the underlying decoding error.
+	*/
+	PayloadDecodeFailed = fmt.Errorf("payload decode fail")
+	/*
+		PayloadTooLarge is an error which signifies that a request
+		payload exceeds the limit on its size (see the multiplexer's
+		WithMaxBodySize).
+	*/
+	PayloadTooLarge = fmt.Errorf("payload too large")
+	/*
+		UnregisteredEntityType is an error which signifies that
+		a value's type does not correspond to any Entity managed
+		by a multiplexer.
+	*/
+	UnregisteredEntityType = fmt.Errorf("entity type not registered")
+	/*
+		StreamingUnsupported is an error which signifies that
+		a response cannot be streamed, since its writer is not
+		an http.Flusher.
+	*/
+	StreamingUnsupported = fmt.Errorf("response streaming unsupported")
 )
 
 /*
@@ -41,3 +93,88 @@ found to have duplicates.
 func DuplicateTag(tag, entity string) error {
 	return fmt.Errorf("duplicate '%s' tag on '%s'", tag, entity)
 }
+
+/*
+DuplicateEntityType is an error representing that a definition
+cannot be registered because its type is already registered
+under the given EntityID.
+*/
+func DuplicateEntityType(typeName, entityID string) error {
+	return fmt.Errorf("type '%s' already registered as '%s'", typeName, entityID)
+}
+
+/*
+EntityEmbedded is an error representing that an Entity cannot
+be removed because another Entity embeds it.
+*/
+func EntityEmbedded(entityID, embeddedBy string) error {
+	return fmt.Errorf("entity '%s' embedded by '%s'", entityID, embeddedBy)
+}
+
+/*
+StorageNameMismatch is an error representing that the name used
+to query a field differs from the name it is stored under.
+*/
+func StorageNameMismatch(field, queried, stored string) error {
+	return fmt.Errorf("field '%s' queried as '%s' but stored as '%s'", field, queried, stored)
+}
+
+/*
+IllegalEntityID is an error representing that the given EntityID
+cannot be used as a collection name, for the given reason.
+*/
+func IllegalEntityID(entityID, reason string) error {
+	return fmt.Errorf("entityID '%s' is not a valid collection name: %s", entityID, reason)
+}
+
+/*
+ExportUnsupported is an error representing that the documents of
+the given Entity cannot be exported, since its EntityID is a
+collection-name template.
+*/
+func ExportUnsupported(entityID string) error {
+	return fmt.Errorf("entity '%s' has templated collections and cannot be exported", entityID)
+}
+
+/*
+UnresolvedReference is an error representing that a reference
+to an instance of the given Entity does not match any document.
+*/
+func UnresolvedReference(entityID string) error {
+	return fmt.Errorf("unresolved reference to '%s'", entityID)
+}
+
+/*
+EntitiesUnreachable is an error representing that the collections
+of the given Entities, mapped to the errors encountered when
+querying them, could not be reached.
+*/
+func EntitiesUnreachable(failures map[string]error) error {
+	return fmt.Errorf("unreachable entities: %s", entityFailures(failures))
+}
+
+/*
+IndexCreationFailed is an error representing that the indexes of
+the given Entities, mapped to the errors encountered when creating
+them, could not be created.
+*/
+func IndexCreationFailed(failures map[string]error) error {
+	return fmt.Errorf("index creation failed: %s", entityFailures(failures))
+}
+
+/*
+entityFailures lists the given failures, ordered by EntityID.
+*/
+func entityFailures(failures map[string]error) string {
+	entityIDs := make([]string, 0, len(failures))
+	for entityID := range failures {
+		entityIDs = append(entityIDs, entityID)
+	}
+	sort.Strings(entityIDs)
+
+	reasons := make([]string, len(entityIDs))
+	for i, entityID := range entityIDs {
+		reasons[i] = fmt.Sprintf("%s: %s", entityID, failures[entityID])
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,459 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+type AxisUser struct {
+	Name     string `json:"name"`
+	Email    string `json:"email" bson:"email" _ax_:"true"`
+	Username string `json:"username" bson:"username" _ax_:"true"`
+}
+
+var axisUserEntity = &Entity{SchemaDefinition: TypeOf(AxisUser{}), CheckDuplicateAxis: true}
+
+func TestEntity_CheckDuplicateAxis(t *testing.T) {
+	var queried bson.M
+	existing := func(filter bson.M) (bool, error) {
+		queried = filter
+		return true, nil
+	}
+
+	err := axisUserEntity.checkDuplicateAxis(AxisUser{Name: "Jane", Email: "jane@example.com"}, existing)
+	if err != entityErrors.DuplicateAxis {
+		t.Fatal("expected duplicate axis error")
+	}
+
+	expected := bson.M{"$or": bson.A{bson.M{"email": "jane@example.com"}}}
+	if !reflect.DeepEqual(queried, expected) {
+		t.Fail()
+	}
+}
+
+func TestEntity_CheckDuplicateAxisUnique(t *testing.T) {
+	none := func(filter bson.M) (bool, error) { return false, nil }
+
+	if err := axisUserEntity.checkDuplicateAxis(AxisUser{Email: "jane@example.com"}, none); err != nil {
+		t.Fail()
+	}
+}
+
+func TestEntity_ExistsWhere(t *testing.T) {
+	var queried bson.M
+	existing := func(filter bson.M) (bool, error) {
+		queried = filter
+		return filter["email"] == "jane@example.com", nil
+	}
+
+	found, err := axisUserEntity.existsWhere([]spec.ESpec{{Field: "email", Target: "jane@example.com"}}, existing)
+	if err != nil || !found {
+		t.Fatalf("expected match, got %v", err)
+	}
+	if !reflect.DeepEqual(queried, bson.M{"email": "jane@example.com"}) {
+		t.Errorf("unexpected filter: %v", queried)
+	}
+
+	found, err = axisUserEntity.existsWhere([]spec.ESpec{{Field: "email", Target: "john@example.com"}}, existing)
+	if err != nil || found {
+		t.Errorf("expected no match, got %v", err)
+	}
+}
+
+func TestEntity_ExistsWhereUndefinedField(t *testing.T) {
+	called := false
+	exists := func(filter bson.M) (bool, error) {
+		called = true
+		return true, nil
+	}
+
+	if _, err := axisUserEntity.existsWhere([]spec.ESpec{{Field: "mail", Target: "x"}}, exists); err == nil || called {
+		t.Fail()
+	}
+}
+
+func TestEntity_AxisValues(t *testing.T) {
+	values, err := axisUserEntity.AxisValues(AxisUser{Name: "Jane", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"email": "jane@example.com"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected axis values: %v", values)
+	}
+}
+
+func TestEntity_AxisValuesUndefined(t *testing.T) {
+	if _, err := axisUserEntity.AxisValues(AxisUser{Name: "Jane"}); err != entityErrors.UndefinedAxis {
+		t.Fail()
+	}
+}
+
+type RequiredUser struct {
+	Name string   `json:"name" _rq_:"true"`
+	Tags []string `json:"tags" _rq_:"true"`
+}
+
+func TestEntity_CheckRequired(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(RequiredUser{})}
+
+	if err := ety.CheckRequired(RequiredUser{Name: "Jane", Tags: []string{"a"}}); err != nil {
+		t.Errorf("complete entity rejected: %v", err)
+	}
+	err := ety.CheckRequired(RequiredUser{Name: "Jane", Tags: []string{}})
+	if !errors.Is(err, entityErrors.BodyIncomplete) || !strings.Contains(err.Error(), "tags") {
+		t.Errorf("empty required slice accepted: %v", err)
+	}
+}
+
+type RequiredSettings struct {
+	Enabled bool  `json:"enabled" _rq_:"true"`
+	Limit   int64 `json:"limit" _rq_:"true"`
+}
+
+func TestEntity_CheckRequiredFalse(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(RequiredSettings{})}
+
+	if err := ety.CheckRequired(RequiredSettings{Enabled: false, Limit: 0}); err != nil {
+		t.Errorf("required false bool or 0 number reported missing: %v", err)
+	}
+}
+
+type ShippedOrder struct {
+	NeedsShipping   bool   `json:"needsShipping"`
+	ShippingAddress string `json:"shippingAddress" _rqif_:"needsShipping=true"`
+	Method          string `json:"method" _rqif_:"shippingAddress=express"`
+}
+
+func TestEntity_CheckRequiredIf(t *testing.T) {
+	ety, err := NewEntity(TypeOf(ShippedOrder{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ety.CheckRequired(ShippedOrder{NeedsShipping: true})
+	if !errors.Is(err, entityErrors.BodyIncomplete) || !strings.Contains(err.Error(), "shippingAddress") {
+		t.Errorf("conditionally required field not required: %v", err)
+	}
+	if err := ety.CheckRequired(ShippedOrder{NeedsShipping: false}); err != nil {
+		t.Errorf("conditionally required field required: %v", err)
+	}
+	if err := ety.CheckRequired(ShippedOrder{NeedsShipping: true, ShippingAddress: "1 Main St"}); err != nil {
+		t.Errorf("complete entity rejected: %v", err)
+	}
+}
+
+type EUnknownRequireIf struct {
+	Address string `json:"address" _rqif_:"needsShipping=true"`
+}
+
+type EMalformedRequireIf struct {
+	NeedsShipping bool   `json:"needsShipping"`
+	Address       string `json:"address" _rqif_:"needsShipping=maybe"`
+}
+
+func TestNewEntity_RequireIfTag(t *testing.T) {
+	for _, definition := range []interface{}{EUnknownRequireIf{}, EMalformedRequireIf{}} {
+		if _, err := NewEntity(TypeOf(definition), nil); err == nil {
+			t.Errorf("malformed %s accepted for %T", eField.RequireIfTag, definition)
+		}
+	}
+}
+
+func TestEntity_FieldByRequestID(t *testing.T) {
+	ety, err := NewEntity(TypeOf(AxisUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if field, ok := ety.FieldByRequestID("username"); !ok || field.Name != "Username" {
+		t.Errorf("known request id not found")
+	}
+	if _, ok := ety.FieldByRequestID("foo"); ok {
+		t.Errorf("unknown request id found")
+	}
+	if field, ok := axisUserEntity.FieldByRequestID("email"); !ok || field.Name != "Email" {
+		t.Errorf("request id not found without index")
+	}
+}
+
+type CollatedUser struct {
+	Email    string `json:"email" _ax_:"true" _ix_:"true" _coll_:"en"`
+	Username string `json:"username" _ax_:"true" _ix_:"true"`
+	Handle   string `json:"handle" _ax_:"true" _ix_:"true" _coll_:"en"`
+}
+
+func TestEntity_IndexModelsCollation(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(CollatedUser{})}
+
+	models, err := ety.indexModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 {
+		t.Fatalf("expected 2 index models, got %d", len(models))
+	}
+
+	expectedKeys := bson.D{{Key: "email", Value: "text"}, {Key: "handle", Value: "text"}}
+	if !reflect.DeepEqual(models[0].Keys, expectedKeys) {
+		t.Error("unexpected keys for collated index")
+	}
+
+	collation := models[0].Options.Collation
+	if collation == nil || collation.Locale != "en" || collation.Strength != 2 {
+		t.Error("unexpected collation")
+	}
+
+	if models[1].Options != nil {
+		t.Error("uncollated index has options")
+	}
+}
+
+type InvalidCollation struct {
+	Email string `json:"email" _ax_:"true" _ix_:"true" _coll_:"en:strong"`
+}
+
+func TestEntity_IndexModelsInvalidCollation(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(InvalidCollation{})}
+
+	if _, err := ety.indexModels(); err == nil {
+		t.Fail()
+	}
+}
+
+type PartialIndexUser struct {
+	Email   string `json:"email" _ax_:"true" _ix_:"true" _ixp_:"deleted:false"`
+	Deleted bool   `json:"deleted"`
+}
+
+func TestEntity_IndexModelsPartialFilter(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(PartialIndexUser{})}
+
+	models, err := ety.indexModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].Options == nil {
+		t.Fatal("expected 1 index model with options")
+	}
+
+	expected := bson.M{"deleted": false}
+	if !reflect.DeepEqual(models[0].Options.PartialFilterExpression, expected) {
+		t.Fail()
+	}
+}
+
+func TestParsePartialFilterInvalid(t *testing.T) {
+	if _, err := parsePartialFilter("deleted"); err == nil {
+		t.Fail()
+	}
+}
+
+type TypoAxis struct {
+	Email string `json:"email" _ax_:"tru"`
+}
+
+type NonUniqueAxis struct {
+	Email string `json:"email" bson:"email" _ax_:"true"`
+	Team  string `json:"team" bson:"team" _ax_:"nonunique"`
+}
+
+func TestNewEntityAxisTag(t *testing.T) {
+	_, err := NewEntity(TypeOf(TypoAxis{}), nil)
+	if err == nil || err.Error() != entityErrors.FieldTagUndefined("_ax_", "tru", "Email").Error() {
+		t.Errorf("expected undefined axis tag error naming the field, got %v", err)
+	}
+
+	if _, err := NewEntity(TypeOf(NonUniqueAxis{}), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEntity_CheckDuplicateAxisNonUnique(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(NonUniqueAxis{})}
+
+	var queried bson.M
+	existing := func(filter bson.M) (bool, error) {
+		queried = filter
+		return true, nil
+	}
+
+	_ = ety.checkDuplicateAxis(NonUniqueAxis{Email: "jane@example.com", Team: "core"}, existing)
+	if !reflect.DeepEqual(queried, bson.M{"$or": bson.A{bson.M{"email": "jane@example.com"}}}) {
+		t.Errorf("non-unique axis checked for duplicates: %v", queried)
+	}
+
+	if err := ety.checkDuplicateAxis(NonUniqueAxis{Team: "core"}, existing); err != nil {
+		t.Errorf("non-unique axis reported as duplicate: %v", err)
+	}
+	if filter := Filter(NonUniqueAxis{Team: "core"}); !reflect.DeepEqual(filter, bson.M{"team": "core"}) {
+		t.Errorf("non-unique axis not used as filter: %v", filter)
+	}
+}
+
+type PrioritizedAxes struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Username string             `json:"username" _ax_:"nonunique"`
+	Team     string             `json:"team" _ax_:"nonunique:2"`
+	Email    string             `json:"email" _ax_:"true:1"`
+}
+
+func TestFilterAxisPrecedence(t *testing.T) {
+	if _, err := NewEntity(TypeOf(PrioritizedAxes{}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	id := primitive.NewObjectID()
+	if filter := Filter(PrioritizedAxes{ID: id, Email: "jane@example.com"}); !reflect.DeepEqual(filter, bson.M{"_id": id}) {
+		t.Errorf("expected ID to take precedence, got %v", filter)
+	}
+	if filter := Filter(PrioritizedAxes{Username: "jane", Team: "core", Email: "jane@example.com"}); !reflect.DeepEqual(filter, bson.M{"email": "jane@example.com"}) {
+		t.Errorf("expected primary axis filter, got %v", filter)
+	}
+	if filter := Filter(PrioritizedAxes{Username: "jane", Team: "core"}); !reflect.DeepEqual(filter, bson.M{"team": "core"}) {
+		t.Errorf("expected secondary axis filter, got %v", filter)
+	}
+	if filter := Filter(PrioritizedAxes{Username: "jane"}); !reflect.DeepEqual(filter, bson.M{"username": "jane"}) {
+		t.Errorf("expected unprioritized axis filter, got %v", filter)
+	}
+}
+
+type NumberedTicket struct {
+	ID      string    `bson:"_id"`
+	Number  int64     `json:"number" _ax_:"true"`
+	Open    bool      `json:"open" _ax_:"nonunique"`
+	Created time.Time `json:"created" _ax_:"nonunique"`
+	Code    string    `json:"code" _ax_:"true"`
+}
+
+func TestFilterZeroAxes(t *testing.T) {
+	if filter := Filter(NumberedTicket{Code: "T-1"}); !reflect.DeepEqual(filter, bson.M{"code": "T-1"}) {
+		t.Errorf("zero non-string axes used in filter: %v", filter)
+	}
+	if filter := Filter(NumberedTicket{Number: 7, Code: "T-1"}); !reflect.DeepEqual(filter, bson.M{"number": int64(7)}) {
+		t.Errorf("expected number filter, got %v", filter)
+	}
+	if filter := Filter(NumberedTicket{}); filter != nil {
+		t.Errorf("expected no filter for zero entity, got %v", filter)
+	}
+}
+
+type BadAxisPriority struct {
+	Email string `json:"email" _ax_:"true:first"`
+}
+
+func TestNewEntityAxisPriorityInvalid(t *testing.T) {
+	_, err := NewEntity(TypeOf(BadAxisPriority{}), nil)
+	if err == nil || err.Error() != entityErrors.FieldTagUndefined("_ax_", "true:first", "Email").Error() {
+		t.Errorf("expected undefined axis tag error, got %v", err)
+	}
+}
+
+type IndexTypes struct {
+	Email    string  `json:"email" _ax_:"true" _ix_:"text"`
+	Username string  `json:"username" _ax_:"true" _ix_:"1"`
+	Location []int64 `json:"location" _ax_:"nonunique" _ix_:"2dsphere"`
+}
+
+type BogusIndex struct {
+	Email string `json:"email" _ax_:"true" _ix_:"bogus"`
+}
+
+func TestEntity_IndexModelsTypes(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(IndexTypes{})}
+
+	models, err := ety.indexModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedKeys := bson.D{
+		{Key: "email", Value: "text"},
+		{Key: "username", Value: 1},
+		{Key: "location", Value: "2dsphere"},
+	}
+	if len(models) != 1 || !reflect.DeepEqual(models[0].Keys, expectedKeys) {
+		t.Errorf("unexpected index models: %v", models)
+	}
+}
+
+func TestEntity_IndexModelsBogus(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(BogusIndex{})}
+	if _, err := ety.indexModels(); err == nil {
+		t.Errorf("expected unknown index type to be rejected")
+	}
+
+	if _, err := NewEntity(TypeOf(BogusIndex{}), nil); err == nil {
+		t.Errorf("expected NewEntity to reject unknown index type")
+	}
+}
+
+type NormalizedUser struct {
+	Email string `json:"email" _ax_:"true" _norm_:"trim,lower"`
+	Name  string `json:"name"`
+}
+
+func TestEntity_Normalize(t *testing.T) {
+	if _, err := NewEntity(TypeOf(NormalizedUser{}), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := ToBSON(normalize(NormalizedUser{Email: " Jane.Doe@Example.com", Name: "Jane"}))
+	if stored["email"] != "jane.doe@example.com" || stored["name"] != "Jane" {
+		t.Errorf("unexpected document: %v", stored)
+	}
+
+	filter := Filter(NormalizedUser{Email: "JANE.DOE@example.COM"})
+	if !reflect.DeepEqual(filter, bson.M{"email": "jane.doe@example.com"}) {
+		t.Errorf("unexpected filter: %v", filter)
+	}
+}
+
+type LegacyUser struct {
+	Email string `json:"email" _rq_:"true" _va_:"rep/email/"`
+	Name  string `json:"name"`
+}
+
+func TestEntity_AddRaw(t *testing.T) {
+	ety, err := NewEntity(TypeOf(LegacyUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := LegacyUser{Email: "not an email", Name: "Legacy"}
+	if err := ety.Validate(legacy); err == nil {
+		t.Fatal("expected legacy entity to fail validation")
+	}
+
+	id := primitive.NewObjectID()
+	var inserted bson.M
+	addedID, err := ety.addRaw(legacy, func(doc bson.M) (*mongo.InsertOneResult, error) {
+		inserted = doc
+		return &mongo.InsertOneResult{InsertedID: id}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addedID != id {
+		t.Errorf("unexpected ID %v", addedID)
+	}
+	if !reflect.DeepEqual(inserted, bson.M{"email": "not an email", "name": "Legacy"}) {
+		t.Errorf("unexpected document %v", inserted)
+	}
+
+	if _, err := ety.addRaw(AxisUser{}, nil); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected IncompatibleEntityType, got %v", err)
+	}
+}
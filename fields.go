@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"reflect"
+
+	"github.com/navaz-alani/entity/eField"
+)
+
+/*
+FieldInfo describes a field of an Entity's SchemaDefinition,
+as returned by Fields.
+*/
+type FieldInfo struct {
+	// Name is the name of the field in the SchemaDefinition.
+	Name string
+	/*
+		Index is the index sequence of the field in the
+		SchemaDefinition, for use with reflect.Value.FieldByIndex.
+	*/
+	Index []int
+	/*
+		RequestID is the key used for the field in request
+		payloads (the Request/JSON/BSON/field name, in that
+		priority).
+	*/
+	RequestID string
+	/*
+		StorageName is the name under which the field is stored
+		(see eField.StorageName); it is empty for fields which
+		are not stored.
+	*/
+	StorageName string
+	// Kind is the kind of the field's type.
+	Kind reflect.Kind
+	/*
+		Tokens are the tokens of the field's eField.HandleTag,
+		in the order in which they are given, without duplicates.
+	*/
+	Tokens []rune
+	// Axis reports whether the field is an axis field.
+	Axis bool
+}
+
+/*
+Fields returns a description of the fields of the Entity e's
+SchemaDefinition, in declaration order. The fields of embedded
+structs, and of structs stored with the BSON "inline" option,
+are listed in place of the struct field itself, as they are
+classified by the multiplexer.
+
+The returned slice is a copy; modifying it does not affect the
+Entity. For Entities created by NewEntity, it is built once;
+otherwise, the SchemaDefinition is scanned.
+*/
+func (e *Entity) Fields() []FieldInfo {
+	fields := e.fields
+	if fields == nil {
+		fields = fieldInfos(e.SchemaDefinition, nil)
+	}
+
+	snapshot := make([]FieldInfo, len(fields))
+	for i, field := range fields {
+		field.Index = append(make([]int, 0, len(field.Index)), field.Index...)
+		field.Tokens = append(make([]rune, 0, len(field.Tokens)), field.Tokens...)
+		snapshot[i] = field
+	}
+	return snapshot
+}
+
+/*
+fieldInfos describes the fields of the given struct type, whose
+index sequence in the SchemaDefinition is given by prefix.
+*/
+func fieldInfos(structType reflect.Type, prefix []int) []FieldInfo {
+	fields := make([]FieldInfo, 0, structType.NumField())
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		index := make([]int, len(prefix), len(prefix)+1)
+		copy(index, prefix)
+		index = append(index, i)
+
+		if field.Type.Kind() == reflect.Struct && (field.Anonymous || eField.IsInline(field)) {
+			fields = append(fields, fieldInfos(field.Type, index)...)
+			continue
+		}
+
+		fields = append(fields, FieldInfo{
+			Name:        field.Name,
+			Index:       index,
+			RequestID:   eField.NameByPriority(field, eField.PriorityRequest),
+			StorageName: eField.StorageName(field),
+			Kind:        field.Type.Kind(),
+			Tokens:      eField.HandleTokens(field),
+			Axis:        eField.IsAxis(field),
+		})
+	}
+
+	return fields
+}
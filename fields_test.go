@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FieldsAudit struct {
+	CreatedBy string `json:"createdBy" _hd_:"r"`
+}
+
+type DescribedUser struct {
+	ID    primitive.ObjectID `json:"-" bson:"_id"`
+	Email string             `json:"email" bson:"email" _ax_:"true" _hd_:"ccr"`
+	FieldsAudit
+	Name  string `_req_:"fullName" bson:"name" _hd_:"ce"`
+	Notes string `bson:"-"`
+}
+
+func TestEntity_Fields(t *testing.T) {
+	ety, err := NewEntity(TypeOf(DescribedUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []FieldInfo{
+		{Name: "ID", Index: []int{0}, RequestID: "_id", StorageName: "_id",
+			Kind: reflect.Array, Tokens: []rune{}},
+		{Name: "Email", Index: []int{1}, RequestID: "email", StorageName: "email",
+			Kind: reflect.String, Tokens: []rune{'c', 'r'}, Axis: true},
+		{Name: "CreatedBy", Index: []int{2, 0}, RequestID: "createdBy", StorageName: "createdby",
+			Kind: reflect.String, Tokens: []rune{'r'}},
+		{Name: "Name", Index: []int{3}, RequestID: "fullName", StorageName: "name",
+			Kind: reflect.String, Tokens: []rune{'c', 'e'}},
+		{Name: "Notes", Index: []int{4}, RequestID: "Notes", StorageName: "",
+			Kind: reflect.String, Tokens: []rune{}},
+	}
+
+	fields := ety.Fields()
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("unexpected fields:\n%v\nexpected:\n%v", fields, expected)
+	}
+
+	manual := &Entity{SchemaDefinition: TypeOf(DescribedUser{})}
+	if !reflect.DeepEqual(manual.Fields(), expected) {
+		t.Errorf("fields of a manual Entity differ")
+	}
+
+	fields[1].Tokens[0] = 'x'
+	fields[1].Index[0] = 9
+	fields[0].Name = "Changed"
+	if !reflect.DeepEqual(ety.Fields(), expected) {
+		t.Errorf("modifying the snapshot affected the Entity")
+	}
+}
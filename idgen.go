@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"reflect"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+IDGenerator is a function which generates the ID of a new entity,
+such as a UUID or ULID. The generated value must be assignable (or
+convertible) to the type of the Entity's ID field, that is, the
+field with the BSON tag "_id".
+*/
+type IDGenerator func() (interface{}, error)
+
+/*
+GenerateID returns a copy of the given entity with its ID field
+(the field with the BSON tag "_id") populated using the Entity e's
+IDGenerator, if the field is zero. The entity is returned as is if
+the Entity has no IDGenerator or the ID is already set.
+
+An entityErrors.UndefinedPath error is returned if the Entity has
+an IDGenerator but its SchemaDefinition has no ID field, and an
+error wrapping entityErrors.InvalidDataType is returned if the
+generated value cannot be written to the ID field.
+*/
+func (e *Entity) GenerateID(entity interface{}) (interface{}, error) {
+	if !e.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	} else if e.IDGenerator == nil {
+		return entity, nil
+	}
+
+	index := idFieldIndex(e.SchemaDefinition)
+	if index < 0 {
+		return nil, entityErrors.UndefinedPath("_id")
+	}
+
+	v := reflect.New(e.SchemaDefinition).Elem()
+	v.Set(reflect.ValueOf(entity))
+	if field := v.Field(index); eField.IsZero(field) {
+		id, err := e.IDGenerator()
+		if err != nil {
+			return nil, err
+		}
+		if err := eField.WriteToField(&field, id); err != nil {
+			return nil, err
+		}
+	}
+	return v.Interface(), nil
+}
+
+/*
+idFieldIndex returns the index of the field of the given definition
+stored as "_id" (such as with the BSON tag "_id,omitempty"), or -1 if
+there is none.
+*/
+func idFieldIndex(definition reflect.Type) int {
+	for i := 0; i < definition.NumField(); i++ {
+		if eField.StorageName(definition.Field(i)) == "_id" {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+idValue returns the value of the ID field of the given entity, or
+nil if it has none.
+*/
+func idValue(entity interface{}) interface{} {
+	index := idFieldIndex(reflect.TypeOf(entity))
+	if index < 0 {
+		return nil
+	}
+	return reflect.ValueOf(entity).Field(index).Interface()
+}
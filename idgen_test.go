@@ -0,0 +1,120 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+type Ticket struct {
+	ID    string `json:"id" bson:"_id"`
+	Title string `json:"title" bson:"title"`
+}
+
+type OmittedIDTicket struct {
+	ID    string `json:"id" bson:"_id,omitempty"`
+	Title string `json:"title" bson:"title"`
+}
+
+// ulidGenerator returns an IDGenerator of sequential ULID-like IDs
+func ulidGenerator() IDGenerator {
+	n := 0
+	return func() (interface{}, error) {
+		n++
+		return fmt.Sprintf("01ARZ3NDEKTSV4RRFFQ69G5F%02d", n), nil
+	}
+}
+
+func TestEntity_IDGenerator(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(Ticket{}), IDGenerator: ulidGenerator()}
+
+	var inserted bson.M
+	insert := func(doc bson.M) (*mongo.InsertOneResult, error) {
+		inserted = doc
+		return &mongo.InsertOneResult{InsertedID: doc["_id"]}, nil
+	}
+
+	id, err := ety.add(Ticket{Title: "Broken link"}, insert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != primitive.NilObjectID {
+		t.Errorf("unexpected ObjectID %v", id)
+	}
+	if inserted["_id"] != "01ARZ3NDEKTSV4RRFFQ69G5F01" || inserted["title"] != "Broken link" {
+		t.Errorf("ID not generated before insert: %v", inserted)
+	}
+
+	if _, err := ety.add(Ticket{ID: "custom", Title: "Typo"}, insert); err != nil {
+		t.Fatal(err)
+	}
+	if inserted["_id"] != "custom" {
+		t.Errorf("existing ID replaced: %v", inserted)
+	}
+}
+
+func TestEntity_IDGeneratorOmitEmpty(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(OmittedIDTicket{}), IDGenerator: ulidGenerator()}
+
+	// the generated ID can be learned before adding
+	generated, err := ety.GenerateID(OmittedIDTicket{Title: "Broken link"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket := generated.(OmittedIDTicket)
+	if ticket.ID != "01ARZ3NDEKTSV4RRFFQ69G5F01" {
+		t.Errorf("ID not generated: %v", ticket)
+	}
+
+	var inserted bson.M
+	_, err = ety.add(ticket, func(doc bson.M) (*mongo.InsertOneResult, error) {
+		inserted = doc
+		return &mongo.InsertOneResult{InsertedID: doc["_id"]}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted["_id"] != ticket.ID || len(inserted) != 2 {
+		t.Errorf("generated ID not inserted as '_id': %v", inserted)
+	}
+
+	if filter := Filter(ticket); filter["_id"] != ticket.ID {
+		t.Errorf("expected filter by ID, got %v", filter)
+	}
+}
+
+func TestEntity_IDGeneratorDefault(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(CachedAccount{})}
+	assigned := primitive.NewObjectID()
+
+	id, err := ety.add(CachedAccount{Email: "jane@example.com"}, func(doc bson.M) (*mongo.InsertOneResult, error) {
+		if _, ok := doc["_id"]; ok {
+			t.Errorf("ID assigned without IDGenerator: %v", doc)
+		}
+		return &mongo.InsertOneResult{InsertedID: assigned}, nil
+	})
+	if err != nil || id != assigned {
+		t.Errorf("expected database ID %v, got %v (%v)", assigned, id, err)
+	}
+}
+
+func TestEntity_GenerateIDIncompatible(t *testing.T) {
+	ety := &Entity{
+		SchemaDefinition: TypeOf(CachedAccount{}),
+		IDGenerator:      func() (interface{}, error) { return 42, nil },
+	}
+	if _, err := ety.GenerateID(CachedAccount{}); !errors.Is(err, entityErrors.InvalidDataType) {
+		t.Errorf("expected InvalidDataType, got %v", err)
+	}
+
+	ety = &Entity{SchemaDefinition: TypeOf(TestUser{}), IDGenerator: ulidGenerator()}
+	if _, err := ety.GenerateID(TestUser{}); err == nil {
+		t.Errorf("ID generated for definition without ID field")
+	}
+}
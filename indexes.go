@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+/*
+defaultIndexName is the name of the index MongoDB creates
+on the "_id" field of every collection.
+*/
+const defaultIndexName = "_id_"
+
+/*
+SyncIndexes reconciles the indexes of the Entity e's collection
+with the indexes declared by its field tags (see Optimize). Declared
+indexes which do not exist are created and existing indexes which are
+no longer declared are dropped. The default "_id_" index is never
+dropped.
+
+Indexes are compared by name; declared indexes use the default names
+generated by MongoDB (for example "email_text"). This makes it safe
+to call SyncIndexes repeatedly as index tags change.
+*/
+func (e *Entity) SyncIndexes(ctx context.Context) error {
+	declared, err := e.indexModels()
+	if err != nil {
+		return err
+	}
+
+	indexes := e.PStorage.Indexes()
+	cursor, err := indexes.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var specs []bson.M
+	if err := cursor.All(ctx, &specs); err != nil {
+		return err
+	}
+
+	existing := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		if name, ok := spec["name"].(string); ok {
+			existing = append(existing, name)
+		}
+	}
+
+	drop, create := reconcileIndexes(existing, declared)
+	for _, name := range drop {
+		if _, err := indexes.DropOne(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	if len(create) != 0 {
+		if _, err := indexes.CreateMany(ctx, create); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+reconcileIndexes returns the names of the existing indexes which
+are not declared, and the declared indexes which do not exist.
+*/
+func reconcileIndexes(existing []string, declared []mongo.IndexModel) ([]string, []mongo.IndexModel) {
+	declaredNames := make(map[string]bool)
+	create := make([]mongo.IndexModel, 0)
+
+	existingNames := make(map[string]bool)
+	for _, name := range existing {
+		existingNames[name] = true
+	}
+
+	for _, model := range declared {
+		name := indexName(model)
+		declaredNames[name] = true
+		if !existingNames[name] {
+			create = append(create, model)
+		}
+	}
+
+	drop := make([]string, 0)
+	for _, name := range existing {
+		if name != defaultIndexName && !declaredNames[name] {
+			drop = append(drop, name)
+		}
+	}
+
+	return drop, create
+}
+
+/*
+indexName returns the name of the given index, which is the name
+given in its options or otherwise the name MongoDB generates from
+its keys.
+*/
+func indexName(model mongo.IndexModel) string {
+	if model.Options != nil && model.Options.Name != nil {
+		return *model.Options.Name
+	}
+
+	keys, _ := model.Keys.(bson.D)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestReconcileIndexes(t *testing.T) {
+	declared := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: "text"}}},
+		{Keys: bson.D{{Key: "username", Value: 1}}},
+	}
+	existing := []string{"_id_", "email_text", "name_text"}
+
+	drop, create := reconcileIndexes(existing, declared)
+
+	if !reflect.DeepEqual(drop, []string{"name_text"}) {
+		t.Errorf("unexpected dropped indexes: %v", drop)
+	}
+	if !reflect.DeepEqual(create, declared[1:]) {
+		t.Errorf("unexpected created indexes: %v", create)
+	}
+}
+
+func TestReconcileIndexesInSync(t *testing.T) {
+	declared := []mongo.IndexModel{{Keys: bson.D{{Key: "email", Value: "text"}}}}
+
+	drop, create := reconcileIndexes([]string{"_id_", "email_text"}, declared)
+	if len(drop) != 0 || len(create) != 0 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+editToken is the eField.HandleTag token which classifies a field
+as editable (see multiplexer.EditFieldsToken).
+*/
+const editToken = "e"
+
+/*
+mergeConfig stores the configuration of a Merge.
+*/
+type mergeConfig struct {
+	ignoreNotEditable bool
+}
+
+/*
+MergeOption is a function used to configure Merge.
+*/
+type MergeOption func(*mergeConfig)
+
+/*
+IgnoreNotEditable makes Merge skip changes to fields which are
+undefined or not editable, rather than failing.
+*/
+func IgnoreNotEditable() MergeOption {
+	return func(cfg *mergeConfig) {
+		cfg.ignoreNotEditable = true
+	}
+}
+
+/*
+Merge applies the given changes, keyed by RequestID (see
+FieldByRequestID), onto the given target, which must be a
+pointer to an instance of the Entity e's SchemaDefinition.
+Only the fields named in changes are written, using
+eField.WriteToField; this is useful for PATCH handlers.
+
+Only fields whose eField.HandleTag contains the edit token
+("e") can be changed. A change to any other field, or to an
+undefined field, results in an entityErrors.FieldNotEditable
+or entityErrors.UndefinedPath error respectively, unless the
+IgnoreNotEditable option is given.
+
+Changes are checked before any are written, so that the
+target is left untouched if Merge fails on a field.
+*/
+func (e *Entity) Merge(target interface{}, changes map[string]interface{}, opts ...MergeOption) error {
+	cfg := &mergeConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != e.SchemaDefinition {
+		return entityErrors.IncompatibleEntityType
+	}
+
+	fields := make(map[string]reflect.StructField)
+	for requestID := range changes {
+		field, ok := e.FieldByRequestID(requestID)
+		switch {
+		case ok && strings.Contains(field.Tag.Get(eField.HandleTag), editToken):
+			fields[requestID] = field
+		case cfg.ignoreNotEditable:
+			continue
+		case !ok:
+			return entityErrors.UndefinedPath(requestID)
+		default:
+			return entityErrors.FieldNotEditable(requestID)
+		}
+	}
+
+	// write to a copy so that a failed conversion leaves the target untouched
+	merged := reflect.New(e.SchemaDefinition).Elem()
+	merged.Set(v.Elem())
+	for requestID, field := range fields {
+		fieldValue := merged.FieldByIndex(field.Index)
+		if err := eField.WriteToField(&fieldValue, changes[requestID]); err != nil {
+			return err
+		}
+	}
+
+	v.Elem().Set(merged)
+	return nil
+}
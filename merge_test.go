@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+type EditableUser struct {
+	Name  string `json:"name" _hd_:"ce"`
+	Email string `json:"email" _hd_:"c"`
+	Age   int64  `json:"age" _hd_:"ce"`
+}
+
+var editableUserEntity = &Entity{SchemaDefinition: TypeOf(EditableUser{})}
+
+func TestEntity_Merge(t *testing.T) {
+	user := EditableUser{Name: "Jane", Email: "jane@example.com", Age: 30}
+
+	if err := editableUserEntity.Merge(&user, map[string]interface{}{"name": "Janet"}); err != nil {
+		t.Fatal(err)
+	}
+	if user != (EditableUser{Name: "Janet", Email: "jane@example.com", Age: 30}) {
+		t.Errorf("unexpected merge result: %v", user)
+	}
+}
+
+func TestEntity_MergeNotEditable(t *testing.T) {
+	user := EditableUser{Name: "Jane", Email: "jane@example.com"}
+	changes := map[string]interface{}{"name": "Janet", "email": "janet@example.com"}
+
+	err := editableUserEntity.Merge(&user, changes)
+	if err == nil || err.Error() != entityErrors.FieldNotEditable("email").Error() {
+		t.Errorf("expected FieldNotEditable, got %v", err)
+	}
+	if user.Name != "Jane" {
+		t.Errorf("target changed by failed merge")
+	}
+
+	if err := editableUserEntity.Merge(&user, changes, IgnoreNotEditable()); err != nil {
+		t.Fatal(err)
+	}
+	if user != (EditableUser{Name: "Janet", Email: "jane@example.com"}) {
+		t.Errorf("unexpected merge result: %v", user)
+	}
+}
+
+func TestEntity_MergeInvalid(t *testing.T) {
+	user := EditableUser{Name: "Jane", Age: 30}
+
+	if err := editableUserEntity.Merge(&user, map[string]interface{}{"nickname": "JJ"}); err == nil {
+		t.Errorf("expected undefined field to be rejected")
+	}
+	if err := editableUserEntity.Merge(&user, map[string]interface{}{"name": "Janet", "age": "thirty"}); err == nil {
+		t.Errorf("expected invalid data to be rejected")
+	}
+	if user.Name != "Jane" || user.Age != 30 {
+		t.Errorf("target changed by failed merge: %v", user)
+	}
+	if err := editableUserEntity.Merge(user, map[string]interface{}{}); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected non-pointer target to be rejected")
+	}
+}
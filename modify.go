@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+FindOneAndUpdate atomically finds a document matching the given query
+ESpecs (see BuildFilter) in the underlying database collection pointed
+at by e and applies the given update ESpecs to it. This allows, for
+example, a job to be claimed from a work queue by updating its status.
+
+The matched document is returned as an instance of the Entity's
+SchemaDefinition: as it is after the update if returnNew is true, or
+as it was before the update otherwise. If no document matches, an
+entityErrors.NotFound error is returned.
+*/
+func (e *Entity) FindOneAndUpdate(ctx context.Context, filter, changes []spec.ESpec, returnNew bool) (interface{}, error) {
+	return e.findOneAndUpdate(filter, changes, returnNew,
+		func(filter, update bson.M, opts *options.FindOneAndUpdateOptions) (bson.Raw, error) {
+			res := e.PStorage.FindOneAndUpdate(ctx, filter, update, opts)
+			if res.Err() == mongo.ErrNoDocuments {
+				return nil, entityErrors.NotFound
+			}
+			return res.DecodeBytes()
+		})
+}
+
+/*
+findOneAndUpdate builds the filter and update document for the given
+specs and uses the given run function to perform the operation, before
+decoding the returned document.
+*/
+func (e *Entity) findOneAndUpdate(filter, changes []spec.ESpec, returnNew bool,
+	run func(filter, update bson.M, opts *options.FindOneAndUpdateOptions) (bson.Raw, error)) (interface{}, error) {
+	query, err := e.BuildFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := e.updateDocument(changes)
+	if err != nil {
+		return nil, err
+	}
+
+	returnDocument := options.Before
+	if returnNew {
+		returnDocument = options.After
+	}
+
+	raw, err := run(query, update, options.FindOneAndUpdate().SetReturnDocument(returnDocument))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := reflect.New(e.SchemaDefinition)
+	if err := bson.Unmarshal(raw, doc.Interface()); err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	if err := e.decrypt(doc.Elem()); err != nil {
+		return nil, err
+	}
+	return doc.Elem().Interface(), nil
+}
+
+/*
+updateDocument combines the given update ESpecs into a single update
+document, grouping the changes by update operator, after checking that
+the Field of each ESpec resolves to a field of the Entity e. ESpecs
+which are Omitted (see spec.ESpec.OmitEmpty) are left out, and the
+values set for encrypted fields are encrypted.
+*/
+func (e *Entity) updateDocument(changes []spec.ESpec) (bson.M, error) {
+	update := bson.M{}
+	for i := range changes {
+		if _, _, err := e.ResolvePath(changes[i].Field); err != nil {
+			return nil, err
+		}
+		if changes[i].Omitted() {
+			continue
+		}
+
+		encrypted, err := e.encryptSpec(changes[i])
+		if err != nil {
+			return nil, err
+		}
+		for operator, change := range encrypted.ToUpdateSpec() {
+			fields, ok := update[operator].(bson.M)
+			if !ok {
+				fields = bson.M{}
+				update[operator] = fields
+			}
+			for field, value := range change.(bson.M) {
+				fields[field] = value
+			}
+		}
+	}
+	return update, nil
+}
@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+type Job struct {
+	Name   string `json:"name" bson:"name"`
+	Status string `json:"status" bson:"status"`
+}
+
+var jobEntity = &Entity{SchemaDefinition: TypeOf(Job{})}
+
+/*
+claimJob mocks the database, returning the document before or after
+the update depending on the requested ReturnDocument.
+*/
+func claimJob(t *testing.T) func(filter, update bson.M, opts *options.FindOneAndUpdateOptions) (bson.Raw, error) {
+	return func(filter, update bson.M, opts *options.FindOneAndUpdateOptions) (bson.Raw, error) {
+		if !reflect.DeepEqual(filter, bson.M{"status": "queued"}) {
+			t.Errorf("unexpected filter: %v", filter)
+		}
+		if !reflect.DeepEqual(update, bson.M{"$set": bson.M{"status": "claimed"}}) {
+			t.Errorf("unexpected update: %v", update)
+		}
+
+		job := Job{Name: "build", Status: "queued"}
+		if *opts.ReturnDocument == options.After {
+			job.Status = "claimed"
+		}
+		return bson.Marshal(job)
+	}
+}
+
+func TestEntity_FindOneAndUpdate(t *testing.T) {
+	filter := []spec.ESpec{{Field: "status", Target: "queued"}}
+	changes := []spec.ESpec{spec.Set("status", "claimed")}
+
+	after, err := jobEntity.findOneAndUpdate(filter, changes, true, claimJob(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != (Job{Name: "build", Status: "claimed"}) {
+		t.Errorf("expected updated document, got %v", after)
+	}
+
+	before, err := jobEntity.findOneAndUpdate(filter, changes, false, claimJob(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != (Job{Name: "build", Status: "queued"}) {
+		t.Errorf("expected original document, got %v", before)
+	}
+}
+
+func TestEntity_FindOneAndUpdateNotFound(t *testing.T) {
+	none := func(filter, update bson.M, opts *options.FindOneAndUpdateOptions) (bson.Raw, error) {
+		return nil, entityErrors.NotFound
+	}
+
+	_, err := jobEntity.findOneAndUpdate(nil, []spec.ESpec{spec.Set("status", "claimed")}, true, none)
+	if err != entityErrors.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func TestEntity_UpdateDocument(t *testing.T) {
+	update, err := jobEntity.updateDocument([]spec.ESpec{
+		spec.Set("status", "claimed"),
+		spec.Set("name", "deploy"),
+		spec.Unset("status"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bson.M{
+		"$set":   bson.M{"status": "claimed", "name": "deploy"},
+		"$unset": bson.M{"status": ""},
+	}
+	if !reflect.DeepEqual(update, expected) {
+		t.Errorf("unexpected update: %v", update)
+	}
+
+	if _, err := jobEntity.updateDocument([]spec.ESpec{spec.Set("owner", "x")}); err == nil {
+		t.Errorf("expected undefined field to be rejected")
+	}
+}
+
+func TestEntity_UpdateDocumentOmitEmpty(t *testing.T) {
+	changes := []spec.ESpec{
+		{Field: "name", Target: "deploy", UpdateOperator: "set", OmitEmpty: true},
+		{Field: "status", Target: "", UpdateOperator: "set"},
+	}
+
+	update, err := jobEntity.updateDocument(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(update, bson.M{"$set": bson.M{"name": "deploy", "status": ""}}) {
+		t.Errorf("zero-valued change without OmitEmpty dropped: %v", update)
+	}
+
+	changes[1].OmitEmpty = true
+	update, err = jobEntity.updateDocument(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(update, bson.M{"$set": bson.M{"name": "deploy"}}) {
+		t.Errorf("zero-valued change with OmitEmpty kept: %v", update)
+	}
+}
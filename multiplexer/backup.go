@@ -0,0 +1,212 @@
+package multiplexer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+importBatchSize is the maximum number of documents inserted by
+Import in a single request.
+*/
+const importBatchSize = 500
+
+/*
+cursorCloseTimeout bounds the time spent closing a cursor once an
+Export is done, which may be after its context has been canceled.
+*/
+const cursorCloseTimeout = 10 * time.Second
+
+/*
+documentCursor is the part of a *mongo.Cursor used by Export.
+*/
+type documentCursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+/*
+backupRecord is the envelope of a document written by Export: one
+is written per line, holding the EntityID of the Entity to which
+the document belongs and the document's canonical extended JSON
+encoding (so that BSON types such as ObjectIDs and dates survive
+the round trip).
+*/
+type backupRecord struct {
+	EntityID string          `json:"entity"`
+	Document json.RawMessage `json:"document"`
+}
+
+/*
+Export writes the documents of every Entity which has a collection
+to the given writer, in order of EntityID, as newline-delimited JSON
+records of the form:
+
+	{"entity":"user","document":{"_id":{"$oid":"..."},"name":"Jane"}}
+
+Documents are streamed from their collections rather than buffered,
+so Export can be used with large collections. Entities registered
+while Export runs are not exported. Export stops at the first error,
+or with the context's error once ctx is done, which can leave the
+output incomplete. The output can be reloaded using Import.
+
+Entities whose EntityIDs are collection-name templates (see
+CollectionFor) are stored across many collections, which the output
+cannot distinguish: if the EMux manages any, an
+entityErrors.ExportUnsupported error is returned and nothing is
+written.
+*/
+func (em *EMux) Export(ctx context.Context, w io.Writer) error {
+	return em.export(ctx, w, func(meta *metaEntity) (documentCursor, error) {
+		return meta.Entity.PStorage.Find(ctx, bson.M{})
+	})
+}
+
+/*
+export writes the records described by Export to the given writer,
+using the given find function to open a cursor over the documents
+of each Entity, until the given context is done.
+*/
+func (em *EMux) export(ctx context.Context, w io.Writer, find func(meta *metaEntity) (documentCursor, error)) error {
+	// snapshot the Entities, so that the EMux is not locked while streaming
+	em.mu.RLock()
+	metas := make([]*metaEntity, 0, len(em.Entities))
+	for _, entityID := range em.entityIDs() {
+		metas = append(metas, em.Entities[entityID])
+	}
+	em.mu.RUnlock()
+
+	for _, meta := range metas {
+		if isCollectionTemplate(meta.EntityID) {
+			return entityErrors.ExportUnsupported(meta.EntityID)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, meta := range metas {
+		if meta.Entity.PStorage == nil {
+			continue
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cursor, err := find(meta)
+		if err != nil {
+			return err
+		}
+		if err := exportCursor(ctx, encoder, meta.EntityID, cursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+exportCursor encodes a record for each document of the given cursor,
+which belongs to the Entity with the given EntityID, and closes it.
+Iteration stops with the context's error once it is done.
+*/
+func exportCursor(ctx context.Context, encoder *json.Encoder, entityID string, cursor documentCursor) error {
+	defer func() {
+		// close the cursor even if ctx is done, without waiting indefinitely
+		closeCtx, cancel := context.WithTimeout(context.Background(), cursorCloseTimeout)
+		defer cancel()
+		_ = cursor.Close(closeCtx)
+	}()
+
+	for cursor.Next(ctx) {
+		var doc bson.Raw
+		if err := cursor.Decode(&doc); err != nil {
+			return entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+		}
+
+		extJSON, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+		}
+		if err := encoder.Encode(backupRecord{EntityID: entityID, Document: extJSON}); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return cursor.Err()
+}
+
+/*
+Import inserts the documents read from the given reader, in the format
+written by Export, into the collections of their Entities. Records are
+decoded one at a time and inserted in batches, so Import can be used
+with large backups.
+
+The documents are inserted as is: like entity.Entity.AddRaw, no checks
+are run on them. If a record names an EntityID which the EMux does not
+manage, an entityErrors.InvalidEntityID error is returned, and Import
+stops at the first error; documents inserted up to this point remain.
+*/
+func (em *EMux) Import(ctx context.Context, r io.Reader) error {
+	return em.importRecords(r, func(meta *metaEntity, docs []interface{}) error {
+		_, err := meta.Entity.PStorage.InsertMany(ctx, docs)
+		return err
+	})
+}
+
+/*
+importRecords reads the records described by Import from the given
+reader and uses the given insertMany function to insert consecutive
+documents of the same Entity, at most importBatchSize at a time.
+*/
+func (em *EMux) importRecords(r io.Reader, insertMany func(meta *metaEntity, docs []interface{}) error) error {
+	var batchMeta *metaEntity
+	batch := make([]interface{}, 0, importBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := insertMany(batchMeta, batch)
+		batch = make([]interface{}, 0, importBatchSize)
+		return err
+	}
+
+	decoder := json.NewDecoder(r)
+	for {
+		var record backupRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return entityErrors.Wrap(entityErrors.PayloadDecodeFailed, err)
+		}
+
+		meta := em.meta(record.EntityID)
+		if meta == nil {
+			return entityErrors.InvalidEntityID
+		} else if meta.Entity.PStorage == nil {
+			return entityErrors.DBUninitialized
+		}
+
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(record.Document, true, &doc); err != nil {
+			return entityErrors.Wrap(entityErrors.PayloadDecodeFailed, err)
+		}
+
+		if meta != batchMeta || len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+			batchMeta = meta
+		}
+		batch = append(batch, doc)
+	}
+
+	return flush()
+}
@@ -0,0 +1,220 @@
+package multiplexer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+type ArchivedPost struct {
+	Title string `json:"title" _id_:"!archived-post" _hd_:"c"`
+}
+
+type ArchivedComment struct {
+	Body string `json:"body" _id_:"!archived-comment" _hd_:"c"`
+}
+
+/*
+sliceCursor is a documentCursor over a slice of documents.
+*/
+type sliceCursor struct {
+	docs   []bson.D
+	i      int
+	closed bool
+}
+
+func (c *sliceCursor) Next(ctx context.Context) bool {
+	c.i++
+	return c.i <= len(c.docs)
+}
+
+func (c *sliceCursor) Decode(val interface{}) error {
+	raw, err := bson.Marshal(c.docs[c.i-1])
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, val)
+}
+
+func (c *sliceCursor) Err() error { return nil }
+
+func (c *sliceCursor) Close(ctx context.Context) error {
+	c.closed = true
+	return nil
+}
+
+func backupMux(t *testing.T) *EMux {
+	mux, err := Create(TestDB{}, ArchivedPost{}, ArchivedComment{}, ENoDBColl{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.E("archived-post").PStorage = &mongo.Collection{}
+	mux.E("archived-comment").PStorage = &mongo.Collection{}
+	return mux
+}
+
+func TestEMuxExportImport(t *testing.T) {
+	postID := primitive.NewObjectID()
+	stored := map[string][]bson.D{
+		"archived-post": {
+			{{Key: "_id", Value: postID}, {Key: "title", Value: "Hello"}},
+			{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "title", Value: "World"}},
+		},
+		"archived-comment": {
+			{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "body", Value: "First!"}},
+		},
+	}
+
+	cursors := make([]*sliceCursor, 0)
+	var backup bytes.Buffer
+	err := backupMux(t).export(context.Background(), &backup, func(meta *metaEntity) (documentCursor, error) {
+		cursor := &sliceCursor{docs: stored[meta.EntityID]}
+		cursors = append(cursors, cursor)
+		return cursor, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(backup.String(), "\n"); lines != 3 {
+		t.Errorf("expected 3 records, got %d:\n%s", lines, backup.String())
+	}
+	for _, cursor := range cursors {
+		if !cursor.closed {
+			t.Errorf("cursor not closed")
+		}
+	}
+
+	restored := make(map[string][]interface{})
+	err = backupMux(t).importRecords(&backup, func(meta *metaEntity, docs []interface{}) error {
+		restored[meta.EntityID] = append(restored[meta.EntityID], docs...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for entityID, docs := range stored {
+		if len(restored[entityID]) != len(docs) {
+			t.Errorf("expected %d '%s' documents, got %d", len(docs), entityID, len(restored[entityID]))
+		}
+	}
+	if first := restored["archived-post"][0].(bson.D); first[0].Value != postID {
+		t.Errorf("document ID not preserved: %v", first)
+	}
+}
+
+func TestEMuxImportUnknownEntity(t *testing.T) {
+	backup := strings.NewReader(`{"entity":"unknown","document":{"name":"Jane"}}` + "\n")
+	err := backupMux(t).importRecords(backup, func(meta *metaEntity, docs []interface{}) error {
+		t.Errorf("unexpected insertion")
+		return nil
+	})
+	if err != entityErrors.InvalidEntityID {
+		t.Errorf("expected InvalidEntityID, got %v", err)
+	}
+
+	err = backupMux(t).importRecords(strings.NewReader("{"), nil)
+	if !errors.Is(err, entityErrors.PayloadDecodeFailed) {
+		t.Errorf("expected PayloadDecodeFailed, got %v", err)
+	}
+}
+
+func TestEMuxExportUnlocked(t *testing.T) {
+	mux := backupMux(t)
+
+	// registering while a backup streams must not wait for it to finish
+	var backup bytes.Buffer
+	err := mux.export(context.Background(), &backup, func(meta *metaEntity) (documentCursor, error) {
+		if meta.EntityID != "archived-comment" {
+			return &sliceCursor{}, nil
+		}
+
+		registered := make(chan error, 1)
+		go func() { registered <- mux.Register(TestUser{}) }()
+
+		select {
+		case err := <-registered:
+			if err != nil {
+				t.Errorf("registration failed: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("registration blocked by export")
+		}
+		return &sliceCursor{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mux.E("user") == nil {
+		t.Errorf("entity not registered")
+	}
+}
+
+func TestEMuxExportTemplated(t *testing.T) {
+	mux, err := Create(TestDB{}, ArchivedPost{}, TenantUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.E("archived-post").PStorage = &mongo.Collection{}
+
+	var backup bytes.Buffer
+	err = mux.export(context.Background(), &backup, func(meta *metaEntity) (documentCursor, error) {
+		t.Errorf("unexpected export of '%s'", meta.EntityID)
+		return &sliceCursor{}, nil
+	})
+	if err == nil {
+		t.Errorf("expected templated Entity to be rejected")
+	}
+	if backup.Len() != 0 {
+		t.Errorf("expected nothing to be written, got:\n%s", backup.String())
+	}
+}
+
+func TestEMuxExportCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stored := []bson.D{
+		{{Key: "title", Value: "Hello"}},
+		{{Key: "title", Value: "World"}},
+	}
+
+	// the export is canceled after its first document
+	var backup bytes.Buffer
+	cursors := 0
+	err := backupMux(t).export(ctx, &backup, func(meta *metaEntity) (documentCursor, error) {
+		cursors++
+		return &cancelingCursor{sliceCursor{docs: stored}, cancel}, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if lines := strings.Count(backup.String(), "\n"); lines != 1 || cursors != 1 {
+		t.Errorf("export continued after cancellation: %d records, %d cursors", lines, cursors)
+	}
+}
+
+/*
+cancelingCursor is a sliceCursor which cancels a context once its
+first document is decoded, and stops once that context is done.
+*/
+type cancelingCursor struct {
+	sliceCursor
+	cancel context.CancelFunc
+}
+
+func (c *cancelingCursor) Next(ctx context.Context) bool {
+	return ctx.Err() == nil && c.sliceCursor.Next(ctx)
+}
+
+func (c *cancelingCursor) Decode(val interface{}) error {
+	defer c.cancel()
+	return c.sliceCursor.Decode(val)
+}
@@ -42,16 +42,59 @@ containing configuration tokens. These tokens are single characters
 (runes) which can be used to classify an eField. For example, the
 CreationFieldsToken token can be used used to specify which
 fields should be parsed from an http.Response body for the
-middleware generation.
+middleware generation. Similarly, the RetrievalFieldsToken
+specifies which fields can be used as URL query filters by the
+RetrievalMiddleware, and the EditFieldsToken specifies which fields
+can be changed by entity.Merge. Characters which are not HandleTokens
+are ignored, and reported through the EMux's warning handler (see
+WithWarningHandler).
 
 entity.AxisTag - This tag is used to specify which fields can be
 considered to be unique (to an Entity) within a collection.
 The tag value which indicates that an eField is an axis eField is
-the string "true"-- all other values are rejected.
+the string "true"-- all other values are rejected, except for
+"nonunique", which marks an axis field whose values may be shared
+(it is not checked for duplicates). Either value may be followed by
+a priority, as in "true:1"; when an Entity is looked up, its "_id"
+field is used if set, and otherwise its set axis field with the
+lowest priority (see entity.Filter).
 
 entity.IndexTag - This tag is used to specify the fields for which
 an index needs to be built in the database collection. This is used
 hand in hand with the entity.Axis tag; in order for a eField's index
 to be constructed, both these tags have to be set to "true".
+
+entity.ValidateTag - This tag is used to constrain the values of
+string fields using a regular expression or a named preset. See the
+entity package documentation for the accepted values. Malformed
+values cause Create to fail.
+
+entity.DefaultTag - This tag is used to provide a default value for a
+creation field which is omitted from a creation request payload. The
+value is parsed into the field's type (string, numeric or bool kinds).
+
+entity.ServerTag - When set to "true", this tag marks a field whose value
+is assigned by the server. Such fields are never read from a creation
+request payload, even if present, which prevents mass-assignment.
+
+entity.RequestTag - This tag overrides the key used for a field in request
+payloads (and query parameters), which otherwise is the field's JSON/BSON/field
+name. It does not affect the field's JSON (de)serialization.
+
+entity.RefTag - This tag stores a reference to an instance of another Entity,
+given by its EntityID, in a primitive.ObjectID field. In a creation payload,
+the field can hold either the referenced instance's hex ID, or an object of
+axis values which is resolved to the ID of the matching document. A reference
+which matches no document fails the request.
+
+entity.ExtraTag - When set to "true" on a map[string]interface{} creation
+field, this tag makes the field collect the keys of a creation payload which
+do not match any other field, for schemaless extension data. Create fails if
+more than one field has this tag.
+
+entity.NormalizeTag - This tag lists normalizations ("lower", "upper" or
+"trim", comma-separated) applied to a string field's value in creation
+payloads. Axis filters built from payloads (see AxisFilter) are normalized
+likewise, so that lookups match the stored values.
 */
 package multiplexer
@@ -0,0 +1,97 @@
+package multiplexer
+
+import (
+	"context"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/multiplexer/muxHandle"
+)
+
+/*
+pingConfig stores the configuration of a health check.
+*/
+type pingConfig struct {
+	collections bool
+}
+
+/*
+PingOption is a function used to configure the health check
+performed by Ping.
+*/
+type PingOption func(*pingConfig)
+
+/*
+WithCollectionCheck makes Ping also verify that the collection
+of each managed Entity can be queried, using a (cheap) estimated
+document count.
+*/
+func WithCollectionCheck() PingOption {
+	return func(cfg *pingConfig) {
+		cfg.collections = true
+	}
+}
+
+/*
+Ping checks that the database used by the EMux is reachable, for
+example to implement a readiness probe. The DBHandler used to create
+the EMux is pinged if it implements muxHandle.Pinger; otherwise, the
+database is assumed to be reachable.
+
+With WithCollectionCheck, the collection of each Entity is queried
+as well, and an entityErrors.EntitiesUnreachable error naming every
+failing Entity is returned. Entities without a collection, such as
+those with templated EntityIDs, are not checked.
+*/
+func (em *EMux) Ping(ctx context.Context, opts ...PingOption) error {
+	cfg := &pingConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if pinger, ok := em.db.(muxHandle.Pinger); ok {
+		if err := pinger.Ping(ctx); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.collections {
+		return nil
+	}
+	return em.checkCollections(func(meta *metaEntity) error {
+		_, err := meta.Entity.PStorage.EstimatedDocumentCount(ctx)
+		return err
+	})
+}
+
+/*
+checkCollections runs the given check for each Entity which has a
+collection, aggregating the failures into an
+entityErrors.EntitiesUnreachable error.
+*/
+func (em *EMux) checkCollections(check func(meta *metaEntity) error) error {
+	if failures := em.eachCollection(check); len(failures) != 0 {
+		return entityErrors.EntitiesUnreachable(failures)
+	}
+	return nil
+}
+
+/*
+eachCollection runs the given function for each Entity which has a
+collection, in order of EntityID, returning the errors encountered keyed by EntityID.
+*/
+func (em *EMux) eachCollection(run func(meta *metaEntity) error) map[string]error {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	failures := make(map[string]error)
+	for _, entityID := range em.entityIDs() {
+		meta := em.Entities[entityID]
+		if meta.Entity.PStorage == nil {
+			continue
+		}
+		if err := run(meta); err != nil {
+			failures[entityID] = err
+		}
+	}
+	return failures
+}
@@ -0,0 +1,70 @@
+package multiplexer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type PingDB struct {
+	TestDB
+	err error
+}
+
+func (db PingDB) Ping(ctx context.Context) error {
+	return db.err
+}
+
+func TestEMuxPing(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Ping(context.Background()); err != nil {
+		t.Errorf("handler without Ping failed health check: %v", err)
+	}
+
+	mux, err = Create(PingDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Ping(context.Background()); err != nil {
+		t.Errorf("reachable database failed health check: %v", err)
+	}
+}
+
+func TestEMuxPingFail(t *testing.T) {
+	unreachable := errors.New("server selection timeout")
+	mux, err := Create(PingDB{err: unreachable}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Ping(context.Background()); err != unreachable {
+		t.Errorf("expected ping error, got %v", err)
+	}
+}
+
+func TestEMuxCheckCollections(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{}, Member{}, ENoDBColl{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := make(map[string]bool)
+	err = mux.checkCollections(func(meta *metaEntity) error {
+		checked[meta.EntityID] = true
+		if meta.EntityID == "member" {
+			return errors.New("not authorized")
+		}
+		return nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "member: not authorized") || strings.Contains(err.Error(), "user") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(checked) != 2 {
+		t.Errorf("expected entities with collections to be checked, got %v", checked)
+	}
+}
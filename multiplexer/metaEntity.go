@@ -3,9 +3,15 @@ package multiplexer
 import (
 	"reflect"
 	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/navaz-alani/entity"
 	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
 )
 
 type (
@@ -23,9 +29,22 @@ type (
 		EntityID string
 		/*
 			FieldClassifications maps a eField classification to
-			a slice of pointers to condensedFields.
+			a slice of pointers to condensedFields, in order of
+			declaration. Consumers must iterate over the slices
+			(or HandleTokens) rather than the map where order
+			matters.
 		*/
 		FieldClassifications map[rune][]*condensedField
+		/*
+			collectionOptions are the options used when obtaining
+			the Entity's database collections.
+		*/
+		collectionOptions []*options.CollectionOptions
+		/*
+			pool stores pointers (as reflect.Values) to zeroed
+			instances of the Entity. See WithEntityPool.
+		*/
+		pool *sync.Pool
 	}
 
 	/*
@@ -36,6 +55,13 @@ type (
 	condensedField struct {
 		// Name is the eField's eField name.
 		Name string
+		/*
+			Index is the index sequence of the eField within the
+			Entity's definition, for use with FieldByIndex. It has
+			more than one element for fields promoted from anonymous
+			embedded structs.
+		*/
+		Index []int
 		// Type is the reflection type of the eField.
 		Type reflect.Type
 		/*
@@ -44,11 +70,43 @@ type (
 			It can be equal to the Name eField.
 		*/
 		RequestID string
+		/*
+			FallbackIDs are the eField's BSON name and Go name,
+			where they differ from the RequestID, in that order.
+			They are checked when the RequestID is absent from a
+			payload if key fallback is enabled (see
+			EMux.SetKeyFallback).
+		*/
+		FallbackIDs []string
 		/*
 			Value is used to store the collection name that this
 			field specifies.
 		*/
 		Value string
+		/*
+			Default is the value, parsed from the entity.DefaultTag,
+			which is written to this field when it is omitted from
+			a creation payload. It is nil if no default is defined.
+		*/
+		Default interface{}
+		/*
+			ServerManaged specifies whether the field's entity.ServerTag
+			is "true", in which case its value is never read from a
+			creation payload.
+		*/
+		ServerManaged bool
+		/*
+			Reference is the EntityID given by the field's
+			entity.RefTag. The EmbeddedEntity's Meta of a
+			reference field links to the referenced Entity.
+		*/
+		Reference string
+		/*
+			CatchAll specifies whether the field's entity.ExtraTag
+			is "true", in which case it collects the payload keys
+			which do not match any other creation field.
+		*/
+		CatchAll bool
 		/*
 			EmbeddedEntity is used to store an internal reference to
 			the Entity whose type this field specifies.
@@ -101,7 +159,8 @@ const (
 	EntityIDToken rune = '*'
 	/*
 		AxisFieldToken maps to an array containing fields which
-		are tagged as axis fields.
+		are tagged as axis fields. Fields whose entity.AxisTag is
+		"true" or "nonunique" are also classified under this token.
 	*/
 	AxisFieldToken rune = 'a'
 	/*
@@ -110,6 +169,18 @@ const (
 		for creating an instance of an Entity.
 	*/
 	CreationFieldsToken rune = 'c'
+	/*
+		RetrievalFieldsToken maps to an array containing fields
+		which can be used to filter Entities in retrieval
+		requests.
+	*/
+	RetrievalFieldsToken rune = 'r'
+	/*
+		EditFieldsToken maps to an array containing fields which
+		can be changed after an Entity has been created, for
+		example using entity.Merge.
+	*/
+	EditFieldsToken rune = 'e'
 )
 
 /*
@@ -119,20 +190,198 @@ the entity.HandleTag of a struct eField for classification.
 var HandleTokens = []rune{
 	CreationFieldsToken,
 	AxisFieldToken,
+	RetrievalFieldsToken,
+	EditFieldsToken,
+}
+
+/*
+clone returns a copy of the metaEntity whose condensedFields are
+copied as well, so that the links of the copy can be changed without
+affecting readers of the original. A condensedField classified under
+several tokens is copied once.
+*/
+func (meta *metaEntity) clone() *metaEntity {
+	copied := *meta
+	copied.FieldClassifications = make(map[rune][]*condensedField, len(meta.FieldClassifications))
+
+	fields := make(map[*condensedField]*condensedField)
+	for tok, classified := range meta.FieldClassifications {
+		copies := make([]*condensedField, len(classified))
+		for i, cf := range classified {
+			if fields[cf] == nil {
+				cfCopy := *cf
+				fields[cf] = &cfCopy
+			}
+			copies[i] = fields[cf]
+		}
+		copied.FieldClassifications[tok] = copies
+	}
+
+	return &copied
 }
 
 /*
 classifyFields is a function which iterates over the fields of
 the given Type and classifies them by their HandleTag tokens.
+
+The fields of anonymous embedded structs are classified as if
+they were declared in the given Type, following Go's promotion
+semantics. The same applies to struct fields with the BSON
+",inline" option, whose sub-fields are stored (and therefore
+read from payloads) at the top level of the document.
 */
 func classifyFields(defType reflect.Type) map[rune][]*condensedField {
 	classifications := map[rune][]*condensedField{}
+	classifyStructFields(defType, nil, classifications)
+	return classifications
+}
+
+/*
+classifyStructFields classifies the fields of the given struct
+Type, whose index sequence in the Entity's definition is given
+by prefix, into the given class map.
+*/
+func classifyStructFields(structType reflect.Type, prefix []int, classes map[rune][]*condensedField) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
 
-	for i := 0; i < defType.NumField(); i++ {
-		classifyHandleTags(defType.Field(i), classifications)
+		index := make([]int, len(prefix), len(prefix)+1)
+		copy(index, prefix)
+		index = append(index, i)
+
+		if field.Type.Kind() == reflect.Struct && (field.Anonymous || eField.IsInline(field)) {
+			classifyStructFields(field.Type, index, classes)
+			continue
+		}
+
+		field.Index = index
+		classifyHandleTags(field, classes)
 	}
+}
 
-	return classifications
+/*
+parseDefaults parses the entity.DefaultTag values of the creation
+fields of the given type into the Default of their condensedFields.
+An error is returned if a default cannot be parsed into its field's
+type.
+*/
+func parseDefaults(defType reflect.Type, classifications map[rune][]*condensedField) error {
+	for _, cf := range classifications[CreationFieldsToken] {
+		field := defType.FieldByIndex(cf.Index)
+
+		tag := field.Tag.Get(eField.DefaultTag)
+		if tag == "" {
+			continue
+		}
+
+		value, err := eField.ParseValue(tag, field.Type)
+		if err != nil {
+			return entityErrors.TagUndefined(eField.DefaultTag, tag)
+		}
+		cf.Default = value
+	}
+
+	return nil
+}
+
+/*
+checkReferences verifies that the creation fields of the given type
+which have an entity.RefTag store a primitive.ObjectID.
+*/
+func checkReferences(classifications map[rune][]*condensedField) error {
+	for _, cf := range classifications[CreationFieldsToken] {
+		if cf.Reference != "" && cf.Type != reflect.TypeOf(primitive.ObjectID{}) {
+			return entityErrors.TagUndefined(eField.RefTag, cf.Reference)
+		}
+	}
+	return nil
+}
+
+/*
+checkCatchAll verifies that at most one of the creation fields of the
+given type has an entity.ExtraTag, and that such a field is of type
+map[string]interface{}.
+*/
+func checkCatchAll(defType reflect.Type, classifications map[rune][]*condensedField) error {
+	found := false
+	for _, cf := range classifications[CreationFieldsToken] {
+		if !cf.CatchAll {
+			continue
+		}
+		if cf.Type != reflect.TypeOf(map[string]interface{}{}) {
+			return entityErrors.FieldTagUndefined(eField.ExtraTag, "true", cf.Name)
+		}
+		if found {
+			return entityErrors.DuplicateTag(eField.ExtraTag, defType.Name())
+		}
+		found = true
+	}
+	return nil
+}
+
+/*
+catchAllField returns the creation field of the given metaEntity which
+collects unmatched payload keys, or nil if there is none.
+*/
+func catchAllField(meta *metaEntity) *condensedField {
+	for _, cf := range meta.FieldClassifications[CreationFieldsToken] {
+		if cf.CatchAll {
+			return cf
+		}
+	}
+	return nil
+}
+
+/*
+fallbackIDs returns the FallbackIDs of the given eField: its BSON
+name (or Go name, if it has no BSONTag) and its Go name, leaving out
+names equal to its RequestID.
+*/
+func fallbackIDs(field reflect.StructField) []string {
+	requestID := eField.NameByPriority(field, eField.PriorityRequest)
+	ids := make([]string, 0)
+	for _, id := range []string{
+		eField.NameByPriority(field, eField.Priority{Tags: []string{eField.BSONTag}}),
+		field.Name,
+	} {
+		if id != requestID && (len(ids) == 0 || ids[0] != id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+/*
+checkHandleTokens verifies that the entity.HandleTag values of the
+fields of the given struct Type (including the fields promoted from
+anonymous and inline structs, see classifyFields) consist of
+HandleTokens. The first unknown token is returned as an error.
+*/
+func checkHandleTokens(structType reflect.Type) error {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Type.Kind() == reflect.Struct && (field.Anonymous || eField.IsInline(field)) {
+			if err := checkHandleTokens(field.Type); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, tok := range eField.HandleTokens(field) {
+			if !strings.ContainsRune(string(HandleTokens), tok) {
+				return entityErrors.FieldTagUndefined(eField.HandleTag, string(tok), field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+embeddable returns whether the given type can be the type of
+an embedded Entity.
+*/
+func embeddable(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
 }
 
 /*
@@ -149,6 +398,14 @@ func classifyHandleTags(field reflect.StructField, classes map[rune][]*condensed
 	cFlag, cType := eField.CheckCollectionEmbedding(field)
 	sFlag, sType := eField.CheckStructEmbedding(field)
 
+	/*
+		Only (collections of) structs can embed Entities; other values,
+		such as []string, primitive.ObjectID (a [12]byte) or time.Time,
+		are written directly using eField.WriteToField.
+	*/
+	cFlag = cFlag && embeddable(cType)
+	sFlag = sFlag && embeddable(sType)
+
 	var embeddedType reflect.Type
 	if cFlag {
 		embeddedType = cType
@@ -157,9 +414,14 @@ func classifyHandleTags(field reflect.StructField, classes map[rune][]*condensed
 	}
 
 	newField := &condensedField{
-		Name:      field.Name,
-		Type:      field.Type,
-		RequestID: eField.NameByPriority(field, eField.PriorityJsonBson),
+		Name:          field.Name,
+		Index:         field.Index,
+		Type:          field.Type,
+		RequestID:     eField.NameByPriority(field, eField.PriorityRequest),
+		FallbackIDs:   fallbackIDs(field),
+		ServerManaged: field.Tag.Get(eField.ServerTag) == "true",
+		Reference:     field.Tag.Get(eField.RefTag),
+		CatchAll:      field.Tag.Get(eField.ExtraTag) == "true",
 		EmbeddedEntity: Embedding{
 			CFlag:        cFlag,
 			SFlag:        sFlag,
@@ -167,6 +429,12 @@ func classifyHandleTags(field reflect.StructField, classes map[rune][]*condensed
 		},
 	}
 
+	/*
+		Tokens which are not HandleTokens have no effect here,
+		since only the HandleTokens are looked up (see
+		checkHandleTokens).
+	*/
+	tokens := string(eField.HandleTokens(field))
 	for _, tok := range HandleTokens {
 		if classes[tok] == nil {
 			classes[tok] = make([]*condensedField, 0)
@@ -182,7 +450,9 @@ func classifyHandleTags(field reflect.StructField, classes map[rune][]*condensed
 			classes[EntityIDToken] = append(classes[EntityIDToken], newField)
 		}
 
-		if tag := field.Tag.Get(eField.HandleTag); strings.ContainsAny(tag, string(tok)) {
+		if strings.ContainsRune(tokens, tok) {
+			classes[tok] = append(classes[tok], newField)
+		} else if tok == AxisFieldToken && eField.IsAxis(field) {
 			classes[tok] = append(classes[tok], newField)
 		}
 	}
@@ -0,0 +1,73 @@
+package multiplexer
+
+/*
+These are the operation names reported to Metrics timers.
+*/
+const (
+	OperationCreate   = "create"
+	OperationRetrieve = "retrieve"
+	OperationRead     = "read"
+)
+
+/*
+Metrics is an interface which can be used to record the outcomes
+and latency of the requests handled by the EMux generated
+middleware and handlers, per Entity and per operation.
+
+The interface is intentionally small so that it can easily be
+adapted to Prometheus or any other metrics library.
+*/
+type Metrics interface {
+	// IncCreate records a creation request, err indicating failure.
+	IncCreate(entityID string, err bool)
+	// IncRetrieve records a retrieval request, err indicating failure.
+	IncRetrieve(entityID string, err bool)
+	// IncRead records a read request, err indicating failure.
+	IncRead(entityID string, err bool)
+	// StartTimer starts timing a request for the given operation.
+	StartTimer(entityID, operation string) Timer
+}
+
+/*
+Timer measures the latency of a single request.
+*/
+type Timer interface {
+	// ObserveDuration records the time elapsed since the Timer started.
+	ObserveDuration()
+}
+
+/*
+noopMetrics is the Metrics used when none have been set.
+*/
+type noopMetrics struct{}
+
+func (noopMetrics) IncCreate(string, bool)          {}
+func (noopMetrics) IncRetrieve(string, bool)        {}
+func (noopMetrics) IncRead(string, bool)            {}
+func (noopMetrics) StartTimer(string, string) Timer { return noopTimer{} }
+
+/*
+noopTimer is the Timer returned by noopMetrics.
+*/
+type noopTimer struct{}
+
+func (noopTimer) ObserveDuration() {}
+
+/*
+SetMetrics sets the Metrics used by the middleware and handlers
+generated by em. Setting nil restores the default no-op Metrics.
+*/
+func (em *EMux) SetMetrics(m Metrics) {
+	em.metrics = m
+}
+
+/*
+recorder returns the Metrics of em, or a no-op Metrics
+if none have been set.
+*/
+func (em *EMux) recorder() Metrics {
+	if em.metrics == nil {
+		return noopMetrics{}
+	}
+	return em.metrics
+}
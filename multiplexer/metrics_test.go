@@ -0,0 +1,85 @@
+package multiplexer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeMetrics records counters for assertions
+type fakeMetrics struct {
+	creates, createErrors, observed int
+}
+
+func (fm *fakeMetrics) IncCreate(_ string, err bool) {
+	fm.creates++
+	if err {
+		fm.createErrors++
+	}
+}
+
+func (fm *fakeMetrics) IncRetrieve(string, bool) {}
+
+func (fm *fakeMetrics) IncRead(string, bool) {}
+
+func (fm *fakeMetrics) StartTimer(string, string) Timer { return fm }
+
+func (fm *fakeMetrics) ObserveDuration() { fm.observed++ }
+
+func TestEMux_SetMetricsCreate(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := &fakeMetrics{}
+	mux.SetMetrics(metrics)
+
+	hd, err := mux.CreationMiddleware("validated-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := hd(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, payload := range []string{DummyValidatedUserJSON, DummyInvalidUserJSON, "{"} {
+		req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(payload)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if metrics.creates != 3 || metrics.createErrors != 2 || metrics.observed != 3 {
+		t.Errorf("unexpected counters: %+v", *metrics)
+	}
+}
+
+func TestEMux_SetMetricsCreatePreview(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := &fakeMetrics{}
+	mux.SetMetrics(metrics)
+
+	hd, err := mux.CreationMiddleware("validated-user", WithPreview())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := hd(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, payload := range []string{DummyValidatedUserJSON, DummyInvalidUserJSON, "{"} {
+		req, err := http.NewRequest("POST", "/?preview=true", bytes.NewReader([]byte(payload)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// previews are still timed, but not counted as creations
+	if metrics.creates != 0 || metrics.observed != 3 {
+		t.Errorf("unexpected counters: %+v", *metrics)
+	}
+}
@@ -3,10 +3,19 @@ package multiplexer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/navaz-alani/entity"
 	"github.com/navaz-alani/entity/eField"
@@ -38,6 +47,38 @@ type (
 			lookup for EntityID by a reflect.Type
 		*/
 		TypeMap TypeMap
+		/*
+			metrics records the outcomes of requests handled by
+			the generated middleware. See SetMetrics.
+		*/
+		metrics Metrics
+		/*
+			errorResponder writes error responses for the generated
+			middleware. See SetErrorResponder.
+		*/
+		errorResponder ErrorResponder
+		// keyFallback is set by SetKeyFallback.
+		keyFallback bool
+		/*
+			db is the handler used to create the EMux. It is
+			disconnected by Close if it is a muxHandle.Disconnector.
+		*/
+		db muxHandle.DBHandler
+		// closed records whether Close has been called.
+		closed bool
+		/*
+			collections caches the collections obtained for
+			templated EntityIDs. See CollectionFor.
+		*/
+		collections map[string]*mongo.Collection
+		// naming is set by WithNamingStrategy.
+		naming NamingStrategy
+		// collectionGuard is set by WithCollectionGuard.
+		collectionGuard CollectionGuard
+		// warn is set by WithWarningHandler.
+		warn func(err error)
+		// mu guards the Entities, TypeMap and collections after creation.
+		mu sync.RWMutex
 	}
 
 	/*
@@ -50,6 +91,30 @@ type (
 		Entity by type of an instance.
 	*/
 	TypeMap map[reflect.Type]string
+
+	/*
+		Definition pairs an Entity definition with the options used
+		when obtaining its database collection. A Definition can be
+		given to Create in place of a bare definition, for example:
+
+			eMux, err := multiplexer.Create(dbPtr, multiplexer.Definition{
+				Type:    User{},
+				Options: []*options.CollectionOptions{collOpts},
+			})
+	*/
+	Definition struct {
+		// Type is the empty/zero struct defining the Entity.
+		Type interface{}
+		// Options are passed to the DBHandler's Collection function.
+		Options []*options.CollectionOptions
+		/*
+			StrictNames causes Create to fail if a field's storage
+			name differs from the name it is queried by, instead
+			of reporting a warning (see WithWarningHandler). See
+			checkStorageNames.
+		*/
+		StrictNames bool
+	}
 )
 
 /*
@@ -65,7 +130,10 @@ To modify the options for the collection, the client can
 use the db pointer used during initialization
 */
 func (em *EMux) Collection(entityID string) *mongo.Collection {
-	return em.Entities[entityID].Entity.PStorage
+	if meta := em.meta(entityID); meta != nil {
+		return meta.Entity.PStorage
+	}
+	return nil
 }
 
 /*
@@ -75,12 +143,166 @@ This Entity can be used normally to carry out CRUD operations
 for instances of the Entity.
 */
 func (em *EMux) E(entityID string) *entity.Entity {
-	if meta := em.Entities[entityID]; meta != nil {
+	if meta := em.meta(entityID); meta != nil {
 		return meta.Entity
 	}
 	return nil
 }
 
+/*
+BatchResult is the result of pre-processing one item of a batch
+given to ValidateBatch.
+*/
+type BatchResult struct {
+	// Index is the index of the item in the batch.
+	Index int
+	// Entity is the pre-processed Entity, if the item is valid.
+	Entity interface{}
+	/*
+		Errors are the reasons the item is invalid. For items which
+		fail validation, there is an error for each invalid field.
+		An error hit while writing the item, such as an invalid
+		embedded Entity, is reported alone.
+	*/
+	Errors []error
+}
+
+/*
+ValidateBatch pre-processes each of the given items, which are keyed
+by the RequestIDs of the creation fields of the Entity corresponding
+to the given entityID (as for CreationMiddleware), and returns a
+BatchResult for each. Nothing is written to the database, so this
+can be used to check items before importing them.
+
+If the entityID is not registered, every item is reported with an
+entityErrors.InvalidEntityID error.
+*/
+func (em *EMux) ValidateBatch(entityID string, items []map[string]interface{}) []BatchResult {
+	meta := em.meta(entityID)
+
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i].Index = i
+		if meta == nil {
+			results[i].Errors = []error{entityErrors.InvalidEntityID}
+			continue
+		}
+
+		// errors hit while writing (including invalid embedded Entities) end the item
+		value, err := em.writePayload(meta, item, reflect.New(meta.Entity.SchemaDefinition).Elem())
+		if err != nil {
+			results[i].Errors = []error{err}
+			continue
+		}
+
+		// report every invalid field of the fully written item
+		if errs := meta.Entity.ValidateFields(value.Interface()); len(errs) != 0 {
+			results[i].Errors = errs
+			continue
+		}
+		results[i].Entity = value.Interface()
+	}
+
+	return results
+}
+
+/*
+Validate runs the validation of the Entity whose definition is the
+type of the given value. Pointers to such values are also accepted.
+
+If the value's type is not managed by the EMux, the error
+entityErrors.UnregisteredEntityType is returned.
+*/
+func (em *EMux) Validate(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if !value.IsValid() {
+		return entityErrors.UnregisteredEntityType
+	}
+
+	em.mu.RLock()
+	entityID, ok := em.TypeMap[value.Type()]
+	em.mu.RUnlock()
+	if !ok {
+		return entityErrors.UnregisteredEntityType
+	}
+	return em.meta(entityID).Entity.Validate(value.Interface())
+}
+
+/*
+AxisFilter uses the axis fields of the Entity corresponding to the given
+entityID to create a BSON filter from the given payload. The payload is
+expected to be keyed by the fields' RequestIDs, as is the case for the
+payloads parsed by the generated middleware.
+
+The first axis field (in order of declaration) which has a value in the
+payload is used for the filter, under its BSON/JSON/field name (in that
+priority), normalized according to its entity.NormalizeTag. If none of
+the axis fields have a value in the payload, an entityErrors.UndefinedAxis
+error is returned.
+
+As with entity.Filter, when the axis field is indexed with a collation
+(entity.CollationTag), queries using the filter should specify the same
+collation.
+*/
+func (em *EMux) AxisFilter(entityID string, payload map[string]interface{}) (bson.M, error) {
+	meta := em.meta(entityID)
+	if meta == nil {
+		return nil, entityErrors.InvalidEntityID
+	}
+	return axisFilter(meta, payload)
+}
+
+/*
+FieldsFor returns the RequestIDs (the names used in request payloads)
+of the fields of the Entity corresponding to the given entityID which
+are classified under the given token, one of the HandleTokens. For
+example, the fields which can be provided when creating an Entity are
+those classified under CreationFieldsToken.
+
+An entityErrors.InvalidEntityID error is returned if the entityID is not
+registered, and an entityErrors.TagUndefined error if the token is not
+one of the HandleTokens.
+*/
+func (em *EMux) FieldsFor(entityID string, token rune) ([]string, error) {
+	meta := em.meta(entityID)
+	if meta == nil {
+		return nil, entityErrors.InvalidEntityID
+	}
+
+	isHandleToken := false
+	for _, tok := range HandleTokens {
+		isHandleToken = isHandleToken || tok == token
+	}
+	if !isHandleToken {
+		return nil, entityErrors.TagUndefined(eField.HandleTag, string(token))
+	}
+
+	fields := make([]string, 0)
+	for _, cf := range meta.FieldClassifications[token] {
+		fields = append(fields, cf.RequestID)
+	}
+	return fields, nil
+}
+
+/*
+axisFilter returns the filter for the first axis field of the Entity
+corresponding to the given metaEntity which is set in the payload, as
+described by AxisFilter.
+*/
+func axisFilter(meta *metaEntity, payload map[string]interface{}) (bson.M, error) {
+	for _, af := range meta.FieldClassifications[AxisFieldToken] {
+		filterValue := payload[af.RequestID]
+		if filterValue == nil || filterValue == "" {
+			continue
+		}
+
+		field := meta.Entity.SchemaDefinition.FieldByIndex(af.Index)
+		return bson.M{eField.NameByPriority(field, eField.PriorityBsonJson): eField.Normalize(field, filterValue)}, nil
+	}
+
+	return nil, entityErrors.UndefinedAxis
+}
+
 /*
 Create uses the given definitions to create an EMux which manages the
 corresponding Entities. The definitions are expected to be an array of
@@ -101,14 +323,46 @@ Remember, when instantiating an Entity, it is important to have a defined
 location for persistent storage. In this case, it is a *mongo.Collection.
 For each definition, a collection in the database is initialized iff the IDTag
 does NOT start with a "!". The name of the collection created is exactly the
-same as the definition's EntityID (last IDTag value). Note, also, that the "!"
-used when avoiding collection creation does NOT could as part of the EntityID.
+same as the definition's EntityID (last IDTag value), unless a NamingStrategy
+is given using WithNamingStrategy. Note, also, that the "!" used when avoiding
+collection creation does NOT could as part of the EntityID.
+
+MuxOptions, such as WithNamingStrategy, may be given among the definitions;
+they are applied before any Entity is registered.
+
+An EntityID may also be a collection-name template, such as "users_{tenant}",
+for storing the data of each tenant in a separate collection. Collections for
+such Entities are created on demand; see CollectionFor and EFor.
 
 Entities for which a database collection has been created are then indexed
 against their axis fields which have been marked for indexing. A field can be
 specified as an axis field by using the entity.AxisTag while index creation is
 specified using the entity.IndexTag. Only fields with the AxisTag set to "true"
 and a non-empty IndexTag are indexed.
+
+A definition may also be wrapped in a Definition in order to specify the
+options for its database collection, such as read and write concerns.
+
+Fields which are queried under a different name than the one the mongo
+driver stores them by (such as fields with a JSON tag but no BSON tag)
+cause a warning to be passed to the handler given using WithWarningHandler
+(warnings are discarded if there is none), or Create to fail if the
+Definition's StrictNames is set.
+
+Each definition must be a struct (see entity.TypeOf), or a pointer to one,
+such as &User{}, which defines the same Entity as User{}; any other definition
+causes Create to fail with an entityErrors.IncompatibleEntityType error.
+
+The DefaultTag values of creation fields are parsed into the fields' types;
+a value which cannot be parsed causes Create to fail.
+
+EntityIDs must be valid collection names: Create fails with an
+entityErrors.IllegalEntityID error for EntityIDs containing "$" or null
+characters, or starting with the reserved "system." prefix.
+
+The ValidateTag values of each definition are compiled when the Entity is
+created. A definition with a malformed ValidateTag causes Create to fail with
+the corresponding error.
 */
 func Create(db muxHandle.DBHandler, definitions ...interface{}) (*EMux, error) {
 	if db == nil {
@@ -117,68 +371,535 @@ func Create(db muxHandle.DBHandler, definitions ...interface{}) (*EMux, error) {
 
 	entityMap := make(map[string]*metaEntity)
 	typeMap := make(map[reflect.Type]string)
-	newMux := &EMux{Entities: entityMap, TypeMap: typeMap}
+	newMux := &EMux{Entities: entityMap, TypeMap: typeMap, db: db}
 
-	// populate entity metadata
+	// apply options before registering any Entity
 	for i := 0; i < len(definitions); i++ {
-		defType := reflect.TypeOf(definitions[i])
-		fieldClassifications := classifyFields(defType)
-
-		createCollection := true
-		var EntityID string
-
-		// Extract collection name
-		collectionNameClassification := fieldClassifications[EntityIDToken]
-		if len(collectionNameClassification) == 0 || collectionNameClassification[0].Value == "" {
-			return nil, entityErrors.NoTag(eField.IDTag, defType.Name())
-		} else if collectionNameClassification[0].Value[0] != '!' {
-			EntityID = collectionNameClassification[0].Value
-		} else {
-			EntityID = collectionNameClassification[0].Value[1:]
-			createCollection = false
+		if opt, ok := definitions[i].(MuxOption); ok {
+			opt(newMux)
 		}
+	}
 
-		// create collection
-		var defCollection *mongo.Collection
-		if createCollection {
-			defCollection = db.Collection(EntityID)
+	// populate entity metadata
+	for i := 0; i < len(definitions); i++ {
+		if _, ok := definitions[i].(MuxOption); ok {
+			continue
 		}
+		if err := newMux.register(definitions[i]); err != nil {
+			return nil, err
+		}
+	}
 
-		// create & register entity
-		defEntity := &entity.Entity{
-			SchemaDefinition: defType,
-			PStorage:         defCollection,
+	newMux.link()
+	return newMux, nil
+}
+
+/*
+Register adds the Entity for the given definition to the EMux after
+its creation, for applications which discover Entities at runtime.
+The definition is handled exactly as in Create; registering a
+definition whose EntityID is already in use fails with an
+entityErrors.DuplicateTag error, and one whose type is already
+registered fails with an entityErrors.DuplicateEntityType error.
+
+Register is safe for concurrent use, including while the EMux's
+middleware is serving requests: the creation middleware picks up
+the links to newly registered Entities on its next request.
+*/
+func (em *EMux) Register(definition interface{}) error {
+	if err := em.register(definition); err != nil {
+		return err
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.link()
+	return nil
+}
+
+/*
+Deregister removes the Entity corresponding to the given entityID from
+the EMux. If the entityID is not registered, an entityErrors.InvalidEntityID
+error is returned.
+
+If another registered Entity embeds the one being removed, an
+entityErrors.EntityEmbedded error is returned, unless force is true; in
+that case, the links to the removed Entity are cleared, so the embedding
+fields are no longer pre-processed by the creation middleware.
+
+Deregister is safe for concurrent use.
+*/
+func (em *EMux) Deregister(entityID string, force bool) error {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	removed := em.Entities[entityID]
+	if removed == nil {
+		return entityErrors.InvalidEntityID
+	}
+
+	for _, id := range em.entityIDs() {
+		meta := em.Entities[id]
+		if id == entityID || force {
+			continue
 		}
 
-		if newMux.Entities[EntityID] == nil {
-			meta := &metaEntity{
-				Entity:               defEntity,
-				EntityID:             EntityID,
-				FieldClassifications: fieldClassifications,
+		for _, field := range meta.FieldClassifications[CreationFieldsToken] {
+			if field.EmbeddedEntity.Meta == removed {
+				return entityErrors.EntityEmbedded(entityID, id)
 			}
+		}
+	}
+
+	// links to the removed Entity are cleared by link
+	delete(em.Entities, entityID)
+	delete(em.TypeMap, removed.Entity.SchemaDefinition)
+	em.link()
+	return nil
+}
+
+/*
+WithWarningHandler makes the EMux pass the problems it finds with its
+definitions which do not prevent their registration, such as storage
+name mismatches (see Definition) and unknown entity.HandleTag tokens
+(which are ignored), to the given handler. For example,
+to log them:
 
-			newMux.Entities[EntityID] = meta
-			newMux.TypeMap[defType] = EntityID
-		} else {
-			return nil, entityErrors.DuplicateTag(eField.IDTag, defType.Name())
+	eMux, err := multiplexer.Create(dbPtr, multiplexer.WithWarningHandler(func(err error) {
+		log.Printf("multiplexer: %s", err)
+	}), User{})
+
+Without a handler, such warnings are discarded.
+*/
+func WithWarningHandler(handler func(err error)) MuxOption {
+	return func(em *EMux) {
+		em.warn = handler
+	}
+}
+
+/*
+warning passes the given problem with the given definition to the
+EMux's warning handler, if one is set (see WithWarningHandler).
+*/
+func (em *EMux) warning(defType reflect.Type, err error) {
+	if em.warn != nil {
+		em.warn(fmt.Errorf("%s: %w", defType.Name(), err))
+	}
+}
+
+/*
+register creates the Entity for the given definition (as described
+by Create) and adds it to the EMux, without linking embedded Entities.
+*/
+func (em *EMux) register(definition interface{}) error {
+	var collectionOptions []*options.CollectionOptions
+	strictNames := false
+	if def, ok := definition.(Definition); ok {
+		definition = def.Type
+		collectionOptions = def.Options
+		strictNames = def.StrictNames
+	}
+
+	defType := definitionType(definition)
+	if defType == nil {
+		return entityErrors.IncompatibleEntityType
+	}
+	if err := checkStorageNames(defType); err != nil {
+		if strictNames {
+			return err
+		}
+		em.warning(defType, err)
+	}
+	if err := checkHandleTokens(defType); err != nil {
+		em.warning(defType, err)
+	}
+	fieldClassifications := classifyFields(defType)
+	if err := parseDefaults(defType, fieldClassifications); err != nil {
+		return err
+	}
+	if err := checkReferences(fieldClassifications); err != nil {
+		return err
+	}
+	if err := checkCatchAll(defType, fieldClassifications); err != nil {
+		return err
+	}
+
+	createCollection := true
+	var EntityID string
+
+	// Extract collection name
+	collectionNameClassification := fieldClassifications[EntityIDToken]
+	if len(collectionNameClassification) == 0 || collectionNameClassification[0].Value == "" {
+		return entityErrors.NoTag(eField.IDTag, defType.Name())
+	} else if collectionNameClassification[0].Value[0] != '!' {
+		EntityID = collectionNameClassification[0].Value
+	} else {
+		EntityID = collectionNameClassification[0].Value[1:]
+		createCollection = false
+	}
+	if err := checkEntityID(EntityID); err != nil {
+		return err
+	}
+
+	// create collection; templated collections are created on demand
+	var defCollection *mongo.Collection
+	if createCollection && !isCollectionTemplate(EntityID) {
+		defCollection = em.db.Collection(em.collectionName(EntityID), collectionOptions...)
+	}
+
+	// create & register entity
+	defEntity, err := entity.NewEntity(defType, defCollection)
+	if err != nil {
+		return err
+	}
+
+	if err := em.add(&metaEntity{
+		Entity:               defEntity,
+		EntityID:             EntityID,
+		FieldClassifications: fieldClassifications,
+		collectionOptions:    collectionOptions,
+	}); err != nil {
+		return err
+	}
+
+	// run indexing
+	if defCollection != nil {
+		_ = defEntity.Optimize()
+	}
+	return nil
+}
+
+/*
+RegisterEntity adds the given, pre-built, Entity to the EMux under the
+given entityID. This allows an Entity to be configured, for example with
+computed fields or additional Validators, before it is registered.
+
+The Entity's fields are classified and its defaults parsed as in Create,
+but its PStorage is used as is: no collection is created and no indexes
+are built. Embedded Entities are linked as in Register.
+
+RegisterEntity is safe for concurrent use.
+*/
+func (em *EMux) RegisterEntity(entityID string, e *entity.Entity) error {
+	if entityID == "" {
+		return entityErrors.InvalidEntityID
+	}
+	if err := checkEntityID(entityID); err != nil {
+		return err
+	}
+	if e == nil || e.SchemaDefinition == nil || e.SchemaDefinition.Kind() != reflect.Struct {
+		return entityErrors.IncompatibleEntityType
+	}
+
+	if err := checkHandleTokens(e.SchemaDefinition); err != nil {
+		em.warning(e.SchemaDefinition, err)
+	}
+	fieldClassifications := classifyFields(e.SchemaDefinition)
+	if err := parseDefaults(e.SchemaDefinition, fieldClassifications); err != nil {
+		return err
+	}
+	if err := checkReferences(fieldClassifications); err != nil {
+		return err
+	}
+	if err := checkCatchAll(e.SchemaDefinition, fieldClassifications); err != nil {
+		return err
+	}
+
+	if err := em.add(&metaEntity{
+		Entity:               e,
+		EntityID:             entityID,
+		FieldClassifications: fieldClassifications,
+	}); err != nil {
+		return err
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	em.link()
+	return nil
+}
+
+/*
+add stores the given metaEntity in the EMux, unless its EntityID is
+already in use, in which case an entityErrors.DuplicateTag error is
+returned, or its definition is already registered under another
+EntityID, in which case an entityErrors.DuplicateEntityType error is
+returned. The references of its Entity are resolved using the EMux,
+unless a ResolveReference function is already set.
+*/
+func (em *EMux) add(meta *metaEntity) error {
+	defType := meta.Entity.SchemaDefinition
+	meta.pool = &sync.Pool{New: func() interface{} {
+		return reflect.New(defType)
+	}}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	if em.Entities[meta.EntityID] != nil {
+		return entityErrors.DuplicateTag(eField.IDTag, defType.Name())
+	} else if entityID, ok := em.TypeMap[defType]; ok {
+		return entityErrors.DuplicateEntityType(defType.Name(), entityID)
+	}
+	em.Entities[meta.EntityID] = meta
+	em.TypeMap[defType] = meta.EntityID
+	if meta.Entity.ResolveReference == nil {
+		meta.Entity.ResolveReference = em.referencedEntity
+	}
+	return nil
+}
+
+/*
+referencedEntity returns the Entity corresponding to the given
+entityID, or nil if there is no such Entity. It resolves the
+references of the EMux's Entities (see entity.Entity.ReadPopulated).
+*/
+func (em *EMux) referencedEntity(entityID string) *entity.Entity {
+	if meta := em.meta(entityID); meta != nil {
+		return meta.Entity
+	}
+	return nil
+}
+
+/*
+checkEntityID verifies that the given EntityID (without a "!" prefix)
+can be used as a MongoDB collection name: it must be non-empty, must
+not contain "$" or null characters and must not use the reserved
+"system." prefix.
+*/
+func checkEntityID(entityID string) error {
+	switch {
+	case entityID == "":
+		return entityErrors.IllegalEntityID(entityID, "empty name")
+	case strings.ContainsAny(entityID, "$\x00"):
+		return entityErrors.IllegalEntityID(entityID, "contains '$' or a null character")
+	case strings.HasPrefix(entityID, "system."):
+		return entityErrors.IllegalEntityID(entityID, "reserved 'system.' prefix")
+	}
+	return nil
+}
+
+/*
+definitionType returns the type of the given definition (see
+entity.TypeOf), dereferencing pointer definitions such as &User{} so
+that the Entity is defined (and looked up in the TypeMap) by the
+struct type. Nil is returned for definitions of other kinds.
+*/
+func definitionType(definition interface{}) reflect.Type {
+	t := reflect.TypeOf(definition)
+	if t == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return entity.TypeOf(reflect.Zero(t).Interface())
+}
+
+/*
+checkStorageNames verifies that the fields of the given definition
+are queried (by their BSON/JSON/field name, in that priority) under
+the name the mongo driver stores them by (see eField.StorageName).
+This is not the case, for example, for a field with a JSON tag but
+no BSON tag. The first mismatch is returned as an error.
+*/
+func checkStorageNames(defType reflect.Type) error {
+	for i := 0; i < defType.NumField(); i++ {
+		field := defType.Field(i)
+
+		stored := eField.StorageName(field)
+		if field.PkgPath != "" || field.Anonymous || eField.IsInline(field) || stored == "" {
+			continue
 		}
 
-		// run indexing
-		if EntityID != "" {
-			_ = defEntity.Optimize()
+		if queried := eField.NameByPriority(field, eField.PriorityBsonJson); queried != stored {
+			return entityErrors.StorageNameMismatch(field.Name, queried, stored)
 		}
 	}
+	return nil
+}
 
-	newMux.link()
-	return newMux, nil
+/*
+isCollectionTemplate returns whether the given EntityID is a
+collection-name template, such as "users_{tenant}".
+*/
+func isCollectionTemplate(entityID string) bool {
+	return strings.Contains(entityID, "{")
+}
+
+/*
+CollectionGuard is a function which decides whether the collection of
+an Entity with a templated EntityID may be obtained for the given
+params (see CollectionFor), for example by checking the tenant against
+an allow-list of known tenants.
+*/
+type CollectionGuard func(entityID string, params map[string]string) bool
+
+/*
+WithCollectionGuard makes the EMux consult the given CollectionGuard
+before obtaining a collection for a templated EntityID. Collections
+for params which the guard rejects are not created.
+*/
+func WithCollectionGuard(guard CollectionGuard) MuxOption {
+	return func(em *EMux) {
+		em.collectionGuard = guard
+	}
+}
+
+/*
+CollectionFor returns the collection of the Entity corresponding to the
+given entityID, for an Entity whose EntityID is a collection-name template
+such as "users_{tenant}". Each "{key}" placeholder is substituted with the
+value of key in params. For example, with params {"tenant": "acme"}, the
+collection "users_acme" is returned. The collection is obtained from the
+EMux's DBHandler on first use and indexed like any other Entity collection.
+
+For an Entity whose EntityID is not a template, its collection is returned
+and params are ignored. If the entityID is not registered, if a placeholder
+is not given a (non-empty) value in params, if the resulting name is not a
+valid collection name or if the EMux's CollectionGuard rejects the params,
+nil is returned.
+
+Every collection obtained is cached by the EMux for its lifetime. Since
+params are often derived from requests (see WithTenantResolver), a
+CollectionGuard should be given using WithCollectionGuard so that clients
+cannot make the EMux create (and retain) arbitrarily many collections.
+*/
+func (em *EMux) CollectionFor(entityID string, params map[string]string) *mongo.Collection {
+	collection, err := em.collectionFor(entityID, params)
+	if err != nil {
+		return nil
+	}
+	return collection
+}
+
+/*
+collectionFor returns the collection described by CollectionFor, or
+the reason it cannot be obtained: an entityErrors.InvalidEntityID error
+if the entityID is not registered, and an entityErrors.IllegalEntityID
+error otherwise.
+*/
+func (em *EMux) collectionFor(entityID string, params map[string]string) (*mongo.Collection, error) {
+	meta := em.meta(entityID)
+	if meta == nil {
+		return nil, entityErrors.InvalidEntityID
+	} else if !isCollectionTemplate(entityID) {
+		return meta.Entity.PStorage, nil
+	}
+
+	name := entityID
+	for key, value := range params {
+		placeholder := "{" + key + "}"
+		if value == "" && strings.Contains(name, placeholder) {
+			return nil, entityErrors.IllegalEntityID(entityID, "empty value for "+placeholder)
+		}
+		name = strings.Replace(name, placeholder, value, -1)
+	}
+	if isCollectionTemplate(name) {
+		return nil, entityErrors.IllegalEntityID(name, "unresolved placeholder")
+	} else if err := checkEntityID(name); err != nil {
+		return nil, err
+	} else if em.collectionGuard != nil && !em.collectionGuard(entityID, params) {
+		return nil, entityErrors.IllegalEntityID(name, "rejected by collection guard")
+	}
+
+	em.mu.Lock()
+	if em.collections == nil {
+		em.collections = make(map[string]*mongo.Collection)
+	}
+	collection, exists := em.collections[name]
+	if !exists {
+		collection = em.db.Collection(name, meta.collectionOptions...)
+		em.collections[name] = collection
+	}
+	em.mu.Unlock()
+
+	if !exists {
+		_ = meta.Entity.WithStorage(collection).Optimize()
+	}
+	return collection, nil
+}
+
+/*
+EFor returns the Entity corresponding to the given entityID, with its
+persistent storage routed to the collection given by CollectionFor for
+the given params. Its CRUD operations therefore act on that collection.
+If there is no such collection, nil is returned.
+*/
+func (em *EMux) EFor(entityID string, params map[string]string) *entity.Entity {
+	collection := em.CollectionFor(entityID, params)
+	if collection == nil {
+		return nil
+	}
+	return em.meta(entityID).Entity.WithStorage(collection)
+}
+
+/*
+entityIDs returns the EntityIDs of the Entities of the EMux in sorted
+order, so that operations over all Entities (and the errors they
+report) are deterministic. It must be called with mu held.
+*/
+func (em *EMux) entityIDs() []string {
+	ids := make([]string, 0, len(em.Entities))
+	for id := range em.Entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+/*
+meta returns the metadata of the Entity corresponding to the given
+entityID, or nil if there is no such Entity.
+*/
+func (em *EMux) meta(entityID string) *metaEntity {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.Entities[entityID]
+}
+
+/*
+Close releases the resources held by the EMux. If the DBHandler used
+to create the EMux implements muxHandle.Disconnector (for example, a
+wrapper around a *mongo.Client owned by the EMux), it is disconnected.
+The registered Entities are also released.
+
+Close is idempotent; calls after the first return nil.
+*/
+func (em *EMux) Close(ctx context.Context) error {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	if em.closed {
+		return nil
+	}
+	em.closed = true
+
+	em.Entities = make(EntityMap)
+	em.TypeMap = make(TypeMap)
+
+	if disconnector, ok := em.db.(muxHandle.Disconnector); ok {
+		return disconnector.Disconnect(ctx)
+	}
+	return nil
 }
 
 /*
 link creates internal representations of embedded struct field types
-for parsing in middleware.
+for parsing in middleware. Once the EMux is shared, link must be
+called with mu held.
+
+Since middleware reads the links without holding mu, link does not
+modify the metadata of registered Entities; it replaces each with a
+linked copy (see metaEntity.clone) instead.
 */
 func (em *EMux) link() {
-	for _, meta := range em.Entities {
+	linked := make(EntityMap, len(em.Entities))
+	for id, meta := range em.Entities {
+		linked[id] = meta.clone()
+	}
+
+	for _, meta := range linked {
 		// todo: append other field classes to `fields` for linking too
 		fields := meta.FieldClassifications[CreationFieldsToken]
 
@@ -186,20 +907,316 @@ func (em *EMux) link() {
 			field := fields[i]
 
 			var embedID string
-			if field.EmbeddedEntity.CFlag || field.EmbeddedEntity.SFlag {
+			if field.Reference != "" {
+				embedID = field.Reference
+			} else if field.EmbeddedEntity.CFlag || field.EmbeddedEntity.SFlag {
 				embedID = em.TypeMap[field.EmbeddedEntity.EmbeddedType]
 			} else {
 				embedID = em.TypeMap[field.Type]
 			}
 
-			if embedID == "" {
-				continue
-			}
-
 			// create reference to embedded Entity metadata
-			field.EmbeddedEntity.Meta = em.Entities[embedID]
+			field.EmbeddedEntity.Meta = linked[embedID]
 		}
 	}
+
+	for id, meta := range linked {
+		em.Entities[id] = meta
+	}
+}
+
+/*
+latest returns the current metadata of the Entity described by the
+given metaEntity, which link replaces when Entities are registered or
+removed. If the Entity has been removed, the given metaEntity is
+returned.
+*/
+func (em *EMux) latest(meta *metaEntity) *metaEntity {
+	if current := em.meta(meta.EntityID); current != nil {
+		return current
+	}
+	return meta
+}
+
+/*
+DefaultMaxBodySize is the default limit, in bytes, on the size of
+the request payloads decoded by the creation middleware.
+*/
+const DefaultMaxBodySize int64 = 1 << 20
+
+/*
+limitedBody is an io.Reader which reads at most n more bytes
+from r. Reading beyond the limit returns the bytes up to the
+limit along with entityErrors.PayloadTooLarge, so that an
+oversized payload can be told apart from a malformed one.
+*/
+type limitedBody struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// read one byte past the limit to detect an oversized payload
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+
+	n = int(l.n)
+	l.n = 0
+	return n, entityErrors.PayloadTooLarge
+}
+
+/*
+These are the request parameters which mark a creation request
+as a preview, when previews are enabled using WithPreview. Either
+is considered set when its value is "true".
+*/
+const (
+	// PreviewQueryKey is the URL query parameter marking a preview.
+	PreviewQueryKey = "preview"
+	// PreviewHeader is the request header marking a preview.
+	PreviewHeader = "X-Entity-Preview"
+)
+
+/*
+creationConfig stores the configuration of a creation
+middleware.
+*/
+type creationConfig struct {
+	maxBodySize int64
+	preview     bool
+	strict      bool
+	streaming   bool
+	pooled      bool
+	pooledCtx   bool
+	// decodePassthrough is set by WithDecodeErrorPassthrough.
+	decodePassthrough bool
+}
+
+/*
+CreationOption is a function used to configure the middleware
+returned by CreationMiddleware.
+*/
+type CreationOption func(*creationConfig)
+
+/*
+WithMaxBodySize sets the limit, in bytes, on the size of the
+request payloads decoded by the creation middleware. It
+defaults to DefaultMaxBodySize.
+*/
+func WithMaxBodySize(size int64) CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.maxBodySize = size
+	}
+}
+
+/*
+WithPreview enables preview requests, which are marked using the
+PreviewQueryKey or PreviewHeader. For a preview request, the
+pre-processed Entity is written back to the client as JSON with a
+"200 OK" status and the next handler is not called, so nothing is
+stored. If pre-processing fails, an error response is written using
+the EMux's ErrorResponder. Preview requests are not counted as
+creation requests by the EMux's Metrics (see SetMetrics).
+*/
+func WithPreview() CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.preview = true
+	}
+}
+
+/*
+WithStrictFields makes every creation field mandatory: if any are
+omitted from a payload, pre-processing fails with an error wrapping
+entityErrors.BodyIncomplete which names all the missing fields.
+Fields with a DefaultTag, computed fields and server-managed fields
+are exempt, since their values do not come from the payload.
+*/
+func WithStrictFields() CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.strict = true
+	}
+}
+
+/*
+WithEntityPool makes the creation middleware pre-process payloads into
+instances of the Entity drawn from a pool, rather than allocating a new
+instance per request. Each instance is zeroed before being returned to
+the pool and the Entity stored in the request context is a copy, so no
+data is shared between requests. This does not apply to WithStreaming.
+*/
+func WithEntityPool() CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.pooled = true
+	}
+}
+
+/*
+WithContextPool makes the creation middleware acquire the request's
+muxContext.EMuxContext from a pool (see muxContext.Acquire), and
+release it once the next handler returns.
+
+The EMuxContext must therefore not be used after the next handler
+returns, for example by goroutines it starts; see muxContext.Release.
+*/
+func WithContextPool() CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.pooledCtx = true
+	}
+}
+
+/*
+WithDecodeErrorPassthrough makes the creation middleware call the next
+handler when a payload cannot be decoded, rather than responding with
+"400 Bad Request". The entityErrors.PayloadDecodeFailed error (wrapping
+the decoding error) is then recorded in the request's muxContext, as for
+pre-processing failures. Payloads exceeding the maximum body size are
+still rejected.
+*/
+func WithDecodeErrorPassthrough() CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.decodePassthrough = true
+	}
+}
+
+/*
+CreationOptions is the configuration of a creation middleware as a
+struct, for use with CreationMiddlewareWithOptions. Its zero value
+is the default configuration; each field corresponds to one of the
+CreationOption functions.
+*/
+type CreationOptions struct {
+	/*
+		MaxBodySize is the limit on the size of request payloads
+		(see WithMaxBodySize). DefaultMaxBodySize is used if it is
+		not positive.
+	*/
+	MaxBodySize int64
+	// Preview enables preview requests; see WithPreview.
+	Preview bool
+	// StrictFields makes every creation field mandatory; see WithStrictFields.
+	StrictFields bool
+	// Streaming enables streaming decoding; see WithStreaming.
+	Streaming bool
+	// EntityPool enables pooling of Entity instances; see WithEntityPool.
+	EntityPool bool
+	// ContextPool enables pooling of request contexts; see WithContextPool.
+	ContextPool bool
+	/*
+		DecodeErrorPassthrough passes decoding failures on to the
+		next handler; see WithDecodeErrorPassthrough.
+	*/
+	DecodeErrorPassthrough bool
+}
+
+/*
+options returns the CreationOption functions equivalent to the
+CreationOptions.
+*/
+func (o CreationOptions) options() []CreationOption {
+	opts := make([]CreationOption, 0)
+	if o.MaxBodySize > 0 {
+		opts = append(opts, WithMaxBodySize(o.MaxBodySize))
+	}
+	if o.Preview {
+		opts = append(opts, WithPreview())
+	}
+	if o.StrictFields {
+		opts = append(opts, WithStrictFields())
+	}
+	if o.Streaming {
+		opts = append(opts, WithStreaming())
+	}
+	if o.EntityPool {
+		opts = append(opts, WithEntityPool())
+	}
+	if o.ContextPool {
+		opts = append(opts, WithContextPool())
+	}
+	if o.DecodeErrorPassthrough {
+		opts = append(opts, WithDecodeErrorPassthrough())
+	}
+	return opts
+}
+
+/*
+newCreationConfig returns the creationConfig resulting from applying
+the given options to the default configuration.
+*/
+func newCreationConfig(opts []CreationOption) *creationConfig {
+	cfg := &creationConfig{maxBodySize: DefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+/*
+SetKeyFallback sets whether the creation middleware (and ValidateBatch)
+looks for a creation field's value under its BSON name and then its Go
+name when the payload has no value under its RequestID. This allows,
+for example, an embedded object to be keyed by BSON names while the
+parent uses JSON names.
+
+Key fallback is disabled by default, since a payload key could then
+match more than one field: a key which is the RequestID of one field
+and a fallback name of another is read into both.
+*/
+func (em *EMux) SetKeyFallback(enabled bool) {
+	em.keyFallback = enabled
+}
+
+/*
+payloadValue returns the value for the given creation field in the
+given payload, checking the field's FallbackIDs if key fallback is
+enabled (see SetKeyFallback).
+*/
+func (em *EMux) payloadValue(cf *condensedField, payload map[string]interface{}) interface{} {
+	if value := payload[cf.RequestID]; value != nil || !em.keyFallback {
+		return value
+	}
+	for _, id := range cf.FallbackIDs {
+		if value := payload[id]; value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+/*
+missingFields returns the RequestIDs of the creation fields of the
+given metaEntity which must be, but are not, provided in the payload
+(see WithStrictFields).
+*/
+func (em *EMux) missingFields(meta *metaEntity, payload map[string]interface{}) []string {
+	missing := make([]string, 0)
+	for _, cf := range meta.FieldClassifications[CreationFieldsToken] {
+		if cf.Default != nil || cf.ServerManaged || cf.CatchAll || meta.Entity.IsComputed(cf.Name) {
+			continue
+		}
+		if em.payloadValue(cf, payload) == nil {
+			missing = append(missing, cf.RequestID)
+		}
+	}
+	return missing
+}
+
+/*
+isPreview returns whether the given request is marked as a
+preview request.
+*/
+func isPreview(r *http.Request) bool {
+	return r.URL.Query().Get(PreviewQueryKey) == "true" ||
+		r.Header.Get(PreviewHeader) == "true"
 }
 
 /*
@@ -215,46 +1232,118 @@ is used to check the incoming request payload for a corresponding value.
 This means that if the JSONTag is defined for the eField, it will be assumed
 to be the corresponding eField in the JSON payload. Otherwise, the BSONTag
 is checked next. If the BSONTag is also empty, the eField's name is used.
+See SetKeyFallback for also accepting BSON and Go names in payloads.
 
 The returned function is middleware which can be used on an httprouter.Router
 so that when a request is received by the client's httprouter.DBHandler, an
 auto-completed version of the entity is present in the request context.
 
-NOTE: This functionality does not yet support embedding of Entity
-types. This can be achieved through linking instead. This is a
-feature which has been planned for implementation.
+If the request payload cannot be decoded, an error response wrapping
+entityErrors.PayloadDecodeFailed is written using the EMux's ErrorResponder
+(see SetErrorResponder), unless WithDecodeErrorPassthrough is used. Payloads larger
+than DefaultMaxBodySize (see WithMaxBodySize) are rejected with a
+"413 Request Entity Too Large" response.
+
+A map[string]interface{} creation field whose ExtraTag is "true" is not read
+from the payload; instead, it collects the payload keys which do not match
+any other creation field. At most one field of an Entity can have this tag.
+
+Creation fields which are omitted from the payload are set to the value of
+their DefaultTag, if defined. Computed fields (see entity.AddComputedField)
+are never read from the payload, and neither are fields whose ServerTag is
+"true"; this prevents clients from assigning server-managed values.
+
+String fields with a NormalizeTag, such as "lower", are normalized before the
+pre-processed Entity is validated using its ValidateTag constraints. If
+pre-processing or validation fails, no Entity is stored in the request context;
+instead, the error is recorded and can be obtained through the Error method of
+the request's muxContext.EMuxContext.
 */
-func (em *EMux) CreationMiddleware(entityID string) (func(next http.HandlerFunc) http.HandlerFunc, error) {
-	var meta *metaEntity
-	if m := em.Entities[entityID]; m == nil || m.EntityID == "" {
+func (em *EMux) CreationMiddleware(entityID string, opts ...CreationOption) (func(next http.HandlerFunc) http.HandlerFunc, error) {
+	var registered *metaEntity
+	if m := em.meta(entityID); m == nil || m.EntityID == "" {
 		return nil, entityErrors.IncompleteEntityMetadata
 	} else {
-		meta = m
+		registered = m
 	}
 
-	if len(meta.FieldClassifications[CreationFieldsToken]) == 0 {
+	if len(registered.FieldClassifications[CreationFieldsToken]) == 0 {
 		return nil, entityErrors.NoClassificationFields
 	}
 
+	cfg := newCreationConfig(opts)
+
 	handle := func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			// Decode the incoming JSON payload
-			var req map[string]interface{}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				http.Error(w, "payload decode fail", http.StatusBadRequest)
-				return
+			// use the links to Entities registered since the middleware was created
+			meta := em.latest(registered)
+
+			metrics := em.recorder()
+			timer := metrics.StartTimer(meta.EntityID, OperationCreate)
+			defer timer.ObserveDuration()
+
+			// previews store nothing, so they are not counted as creations
+			preview := cfg.preview && isPreview(r)
+			countCreate := func(failed bool) {
+				if !preview {
+					metrics.IncCreate(meta.EntityID, failed)
+				}
+			}
+
+			body := &limitedBody{r: r.Body, n: cfg.maxBodySize}
+
+			var muxCtx *muxContext.EMuxContext
+			if cfg.pooledCtx {
+				muxCtx = muxContext.Acquire()
+				defer muxContext.Release(muxCtx)
+			} else {
+				muxCtx = muxContext.Create()
+			}
+
+			var preProcessedEntity reflect.Value
+			var err error
+			if cfg.streaming {
+				preProcessedEntity, err = em.streamEntity(meta, json.NewDecoder(body), cfg.strict)
+			} else {
+				preProcessedEntity, err = em.decodeEntity(meta, body, cfg)
+			}
+
+			var decodeErr *decodeError
+			if errors.As(err, &decodeErr) {
+				if errors.Is(decodeErr.err, entityErrors.PayloadTooLarge) {
+					countCreate(true)
+					// the rest of the payload is not read, so the connection cannot be reused
+					w.Header().Set("Connection", "close")
+					em.respondError(w, http.StatusRequestEntityTooLarge, decodeErr.err)
+					return
+				}
+
+				err = entityErrors.Wrap(entityErrors.PayloadDecodeFailed, decodeErr.err)
+				if !cfg.decodePassthrough {
+					countCreate(true)
+					em.respondError(w, http.StatusBadRequest, err)
+					return
+				}
 			}
 
-			preProcessedEntity, err := em.createEntity(em.Entities[entityID], req)
 			if err != nil {
-				// JSON pre-processing failed
-				//		TODO: add error in context for inspection purposes
-				next.ServeHTTP(w, r)
-				return
+				// JSON pre-processing failed; make error available for inspection
+				muxCtx.SetError(err)
+			} else {
+				_ = muxCtx.Set(meta.EntityID, preProcessedEntity.Interface())
 			}
+			countCreate(err != nil)
 
-			muxCtx := muxContext.Create()
-			_ = muxCtx.Set(meta.EntityID, preProcessedEntity.Interface())
+			if preview {
+				if err != nil {
+					em.respondError(w, http.StatusBadRequest, err)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(preProcessedEntity.Interface())
+				return
+			}
 
 			reqWithCtx := muxCtx.EmbedCtx(r, context.Background())
 			next.ServeHTTP(w, reqWithCtx)
@@ -264,23 +1353,193 @@ func (em *EMux) CreationMiddleware(entityID string) (func(next http.HandlerFunc)
 	return handle, nil
 }
 
-func (em *EMux) createEntity(meta *metaEntity, payload map[string]interface{}) (reflect.Value, error) {
-	var preProcessedEntity reflect.Value
-	var creationFields []*condensedField
+/*
+CreationMiddlewareWithOptions returns the creation middleware for the
+Entity corresponding to the given entityID, configured using the given
+CreationOptions rather than CreationOption functions. See
+CreationMiddleware.
+*/
+func (em *EMux) CreationMiddlewareWithOptions(entityID string, opts CreationOptions) (func(next http.HandlerFunc) http.HandlerFunc, error) {
+	return em.CreationMiddleware(entityID, opts.options()...)
+}
+
+/*
+payloadPool stores the maps which request payloads are decoded into,
+for reuse across requests. The values of a payload are copied into the
+typed fields of the pre-processed Entity, so no references to a map
+are retained once the Entity has been created.
+*/
+var payloadPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[string]interface{})
+	},
+}
+
+/*
+releasePayload clears the given payload map and returns it to the
+payloadPool.
+*/
+func releasePayload(payload map[string]interface{}) {
+	for key := range payload {
+		delete(payload, key)
+	}
+	payloadPool.Put(payload)
+}
+
+/*
+decodeEntity decodes the JSON payload read from body into a map and
+pre-processes it into the Entity corresponding to the given metaEntity.
+Errors in decoding the payload are returned as a *decodeError.
+*/
+func (em *EMux) decodeEntity(meta *metaEntity, body io.Reader, cfg *creationConfig) (reflect.Value, error) {
+	req := payloadPool.Get().(map[string]interface{})
+	defer releasePayload(req)
+
+	// Decode the incoming JSON payload
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		return reflect.Value{}, &decodeError{err}
+	}
+
+	if missing := em.missingFields(meta, req); cfg.strict && len(missing) != 0 {
+		return reflect.Value{}, entityErrors.MissingFields(missing)
+	} else if !cfg.pooled {
+		return em.createEntity(meta, req)
+	}
+
+	/*
+		The pooled instance is copied before being zeroed and returned to
+		the pool. Zeroing replaces (rather than clears) its slices and maps,
+		so the copy does not share any memory which is later reused.
+	*/
+	ptr := meta.pool.Get().(reflect.Value)
+	defer func() {
+		ptr.Elem().Set(reflect.Zero(ptr.Elem().Type()))
+		meta.pool.Put(ptr)
+	}()
+
+	populated, err := em.populateEntity(meta, req, ptr.Elem())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(populated.Interface()), nil
+}
 
+/*
+resolveReference returns the ID of the instance of the Entity referenced
+by the given field whose axis values are given in the payload. The
+lookup function is used to find the ID of the document matching the
+resulting axis filter.
+*/
+func resolveReference(cf *condensedField, payload map[string]interface{},
+	lookup func(meta *metaEntity, filter bson.M) (primitive.ObjectID, error)) (primitive.ObjectID, error) {
+	refMeta := cf.EmbeddedEntity.Meta
+	if refMeta == nil {
+		return primitive.NilObjectID, entityErrors.InvalidEntityLink
+	}
+
+	filter, err := axisFilter(refMeta, payload)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return lookup(refMeta, filter)
+}
+
+/*
+lookupID returns the ID of the document matching the given filter in
+the collection of the Entity corresponding to the given metaEntity.
+An entityErrors.UnresolvedReference error is returned if no document
+matches.
+*/
+func (em *EMux) lookupID(meta *metaEntity, filter bson.M) (primitive.ObjectID, error) {
+	if meta.Entity.PStorage == nil {
+		return primitive.NilObjectID, entityErrors.UnresolvedReference(meta.EntityID)
+	}
+
+	var ref struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	res := meta.Entity.PStorage.FindOne(context.TODO(), filter,
+		options.FindOne().SetProjection(bson.M{"_id": 1}))
+	if err := res.Decode(&ref); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return primitive.NilObjectID, entityErrors.UnresolvedReference(meta.EntityID)
+		}
+		return primitive.NilObjectID, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	return ref.ID, nil
+}
+
+func (em *EMux) createEntity(meta *metaEntity, payload map[string]interface{}) (reflect.Value, error) {
 	if meta == nil {
 		return reflect.ValueOf(nil), entityErrors.InvalidEntityID
-	} else {
-		preProcessedEntity = reflect.New(meta.Entity.SchemaDefinition).Elem()
-		creationFields = meta.FieldClassifications[CreationFieldsToken]
 	}
+	return em.populateEntity(meta, payload, reflect.New(meta.Entity.SchemaDefinition).Elem())
+}
+
+/*
+populateEntity writes the given payload into the given (zeroed) instance
+of the Entity corresponding to the given metaEntity and validates it, as
+described by CreationMiddleware.
+*/
+func (em *EMux) populateEntity(meta *metaEntity, payload map[string]interface{}, preProcessedEntity reflect.Value) (reflect.Value, error) {
+	preProcessedEntity, err := em.writePayload(meta, payload, preProcessedEntity)
+	if err != nil {
+		return preProcessedEntity, err
+	}
+
+	// validate populated entity
+	if err := meta.Entity.Validate(preProcessedEntity.Interface()); err != nil {
+		return preProcessedEntity, err
+	}
+
+	return preProcessedEntity, nil
+}
+
+/*
+writePayload writes the given payload into the given (zeroed) instance
+of the Entity corresponding to the given metaEntity, as described by
+CreationMiddleware, without validating it. Embedded Entities are
+validated as they are written.
+*/
+func (em *EMux) writePayload(meta *metaEntity, payload map[string]interface{}, preProcessedEntity reflect.Value) (reflect.Value, error) {
+	creationFields := meta.FieldClassifications[CreationFieldsToken]
 
 	for _, cf := range creationFields {
+		// computed fields cannot be set by the payload; the catch-all is set below
+		if meta.Entity.IsComputed(cf.Name) || cf.CatchAll {
+			continue
+		}
+
+		// server-managed fields cannot be set by the payload
+		if cf.ServerManaged {
+			if cf.Default != nil {
+				preProcessedEntity.FieldByIndex(cf.Index).Set(reflect.ValueOf(cf.Default))
+			}
+			continue
+		}
+
+		fieldData := em.payloadValue(cf, payload)
+
+		// write default value for omitted field
+		if fieldData == nil && cf.Default != nil {
+			preProcessedEntity.FieldByIndex(cf.Index).Set(reflect.ValueOf(cf.Default))
+			continue
+		}
+
 		// check if there is data to be written to this field
-		if fieldData := payload[cf.RequestID]; fieldData != nil {
-			fieldToWrite := preProcessedEntity.FieldByName(cf.Name)
+		if fieldData != nil {
+			fieldToWrite := preProcessedEntity.FieldByIndex(cf.Index)
 
-			if cf.EmbeddedEntity.CFlag {
+			if cf.Reference != "" {
+				// resolve referenced instance to its ID
+				if refData, ok := fieldData.(map[string]interface{}); ok {
+					id, err := resolveReference(cf, refData, em.lookupID)
+					if err != nil {
+						return preProcessedEntity, err
+					}
+					fieldData = id
+				}
+			} else if cf.EmbeddedEntity.CFlag {
 				if cf.EmbeddedEntity.Meta == nil {
 					return preProcessedEntity, entityErrors.InvalidEntityLink
 				}
@@ -307,6 +1566,13 @@ func (em *EMux) createEntity(meta *metaEntity, payload map[string]interface{}) (
 						return preProcessedEntity, err
 					}
 
+					// store address of new value for collections of pointers
+					if fieldToWrite.Type().Elem().Kind() == reflect.Ptr {
+						ptr := reflect.New(writeValue.Type())
+						ptr.Elem().Set(writeValue)
+						writeValue = ptr
+					}
+
 					// append new value
 					fieldToWrite.Set(reflect.Append(fieldToWrite, writeValue))
 				}
@@ -321,7 +1587,7 @@ func (em *EMux) createEntity(meta *metaEntity, payload map[string]interface{}) (
 				// recursively create entity for field
 				embedValue, err := em.createEntity(cf.EmbeddedEntity.Meta, writeData)
 				if err != nil {
-					return preProcessedEntity, entityErrors.EmbeddedWriteDataInvalid
+					return preProcessedEntity, entityErrors.Wrap(entityErrors.EmbeddedWriteDataInvalid, err)
 				}
 
 				// set data to be written
@@ -335,5 +1601,43 @@ func (em *EMux) createEntity(meta *metaEntity, payload map[string]interface{}) (
 		}
 	}
 
+	eField.NormalizeStruct(preProcessedEntity)
+
+	// collect unmatched keys into the catch-all field
+	if catchAll := catchAllField(meta); catchAll != nil {
+		if extra := em.extraKeys(meta, payload); len(extra) != 0 {
+			preProcessedEntity.FieldByIndex(catchAll.Index).Set(reflect.ValueOf(extra))
+		}
+	}
+
 	return preProcessedEntity, nil
 }
+
+/*
+extraKeys returns the entries of the given payload whose keys do not
+match any creation field of the given metaEntity, other than its
+catch-all field (see entity.ExtraTag). Keys matching the FallbackIDs
+of a field are matched if key fallback is enabled.
+*/
+func (em *EMux) extraKeys(meta *metaEntity, payload map[string]interface{}) map[string]interface{} {
+	known := make(map[string]bool)
+	for _, cf := range meta.FieldClassifications[CreationFieldsToken] {
+		if cf.CatchAll {
+			continue
+		}
+		known[cf.RequestID] = true
+		if em.keyFallback {
+			for _, id := range cf.FallbackIDs {
+				known[id] = true
+			}
+		}
+	}
+
+	extra := make(map[string]interface{})
+	for key, value := range payload {
+		if !known[key] {
+			extra[key] = value
+		}
+	}
+	return extra
+}
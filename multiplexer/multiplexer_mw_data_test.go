@@ -1,6 +1,10 @@
 package multiplexer
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // TestUser for middleware test
 type TestUser struct {
@@ -10,6 +14,13 @@ type TestUser struct {
 	//Age   int64              `json:"age" _hd_:"c"`
 }
 
+// AxisUser is a TestUser whose email is an axis field
+type AxisUser struct {
+	ID    primitive.ObjectID `json:"-" bson:"_id" _id_:"axis-user"`
+	Name  string             `json:"name" _hd_:"c"`
+	Email string             `json:"email" bson:"email" _ax_:"true" _hd_:"c"`
+}
+
 var DummyUserData = TestUser{Name: "Dummy UserEmbed", Email: "dummy@user.com"}
 
 const DummyUserDataJSON = `{"name": "Dummy UserEmbed","email": "dummy@user.com"}`
@@ -25,6 +36,32 @@ type Task struct {
 	Details TaskDetails `json:"details" _hd_:"c"`
 }
 
+// OptionalTask embeds an optional sub-document
+type OptionalTask struct {
+	Name    string       `json:"name" _id_:"optional-task" _hd_:"c"`
+	Details *TaskDetails `json:"details" _hd_:"c"`
+}
+
+var DummyOptionalTask = OptionalTask{
+	Name:    "test task",
+	Details: &TaskDetails{Date: "ISO_DUMMY_DATE"},
+}
+
+const DummyOptionalTaskJSON = `{"name": "test task", "details": {"date": "ISO_DUMMY_DATE"}}`
+
+// PtrCollUser embeds a collection of pointers
+type PtrCollUser struct {
+	Tasks []*Task `json:"tasks" _id_:"user-ptr-coll" _hd_:"c"`
+}
+
+var DummyPtrCollUser = PtrCollUser{
+	Tasks: []*Task{
+		{Name: "test task", Details: TaskDetails{Date: "ISO_DUMMY_DATE"}},
+	},
+}
+
+const DummyPtrCollUserJSON = `{"tasks": [{"name": "test task", "details": {"date": "ISO_DUMMY_DATE"}}]}`
+
 type UserEmbed struct {
 	Tasks Task `json:"tasks" _id_:"user-embed" _hd_:"c"`
 }
@@ -121,3 +158,247 @@ var DummyProjectJSON = `{
     }
   ]
 }`
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Validation setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type ValidatedUser struct {
+	ID    primitive.ObjectID `json:"-" bson:"_id" _id_:"validated-user"`
+	Name  string             `json:"name" _hd_:"c"`
+	Email string             `json:"email" _hd_:"c" _va_:"rep/email/"`
+}
+
+var DummyValidatedUser = ValidatedUser{Name: "Dummy User", Email: "dummy@user.com"}
+
+var DummyInvalidUser = ValidatedUser{Name: "Dummy User", Email: "not-an-email"}
+
+const DummyValidatedUserJSON = `{"name": "Dummy User","email": "dummy@user.com"}`
+
+// validated fields following a validated embedded Entity
+type ValidatedTeam struct {
+	ID    primitive.ObjectID `json:"-" bson:"_id" _id_:"validated-team"`
+	Lead  ValidatedUser      `json:"lead" _hd_:"c"`
+	Email string             `json:"email" _hd_:"c" _va_:"rep/email/"`
+}
+
+const DummyInvalidUserJSON = `{"name": "Dummy User","email": "not-an-email"}`
+
+// client-supplied IDs and non-struct collections
+type ClientNote struct {
+	ID     primitive.ObjectID   `json:"id" bson:"_id" _id_:"client-note" _hd_:"c"`
+	Text   string               `json:"text" _hd_:"c"`
+	Tags   []string             `json:"tags" _hd_:"c"`
+	Linked []primitive.ObjectID `json:"linked" _hd_:"c"`
+}
+
+const DummyClientNoteJSON = `{
+  "id": "5e8f1f7b4f1a4e6d9c3b2a10",
+  "text": "note",
+  "tags": ["a", "b"],
+  "linked": ["5e8f1f7b4f1a4e6d9c3b2a11"]
+}`
+
+var DummyClientNote = ClientNote{
+	ID:     primitive.ObjectID{0x5e, 0x8f, 0x1f, 0x7b, 0x4f, 0x1a, 0x4e, 0x6d, 0x9c, 0x3b, 0x2a, 0x10},
+	Text:   "note",
+	Tags:   []string{"a", "b"},
+	Linked: []primitive.ObjectID{{0x5e, 0x8f, 0x1f, 0x7b, 0x4f, 0x1a, 0x4e, 0x6d, 0x9c, 0x3b, 0x2a, 0x11}},
+}
+
+// time.Time fields are written from RFC 3339 strings, not embedded
+type Meeting struct {
+	ID      primitive.ObjectID `json:"-" bson:"_id" _id_:"meeting"`
+	Title   string             `json:"title" _hd_:"c"`
+	StartAt time.Time          `json:"startAt" _hd_:"c"`
+}
+
+const DummyMeetingJSON = `{"title": "standup", "startAt": "2020-04-01T09:30:00Z"}`
+
+var DummyMeeting = Meeting{
+	Title:   "standup",
+	StartAt: time.Date(2020, 4, 1, 9, 30, 0, 0, time.UTC),
+}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Retrieval setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type Member struct {
+	ID     primitive.ObjectID `json:"-" bson:"_id" _id_:"member"`
+	Name   string             `json:"name" _hd_:"c"`
+	Status string             `json:"status" bson:"status" _hd_:"cr"`
+	Age    int64              `json:"age" bson:"age" _hd_:"cr"`
+	// CreatedAt can be used for sorting
+	CreatedAt int64 `json:"createdAt" bson:"created_at" _hd_:"r"`
+}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Default value setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type Account struct {
+	Name   string `json:"name" _id_:"account" _hd_:"c"`
+	Status string `json:"status" _hd_:"c" _def_:"active"`
+	Active bool   `json:"active" _hd_:"c" _def_:"true"`
+}
+
+const DummyAccountJSON = `{"name": "acc"}`
+
+var DummyAccount = Account{Name: "acc", Status: "active", Active: true}
+
+const DummyAccountStatusJSON = `{"name": "acc", "status": "suspended"}`
+
+var DummyAccountStatus = Account{Name: "acc", Status: "suspended", Active: true}
+
+// invalid default value
+type EBadDefault struct {
+	Age int64 `json:"age" _id_:"bad-default" _hd_:"c" _def_:"old"`
+}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Anonymous embedding setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// BaseModel carries fields promoted into Profile
+type BaseModel struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id" _id_:"profile"`
+	CreatedBy string             `json:"createdBy" _hd_:"c"`
+}
+
+type Profile struct {
+	BaseModel
+	Bio string `json:"bio" _hd_:"c"`
+}
+
+const DummyProfileJSON = `{"createdBy": "admin", "bio": "hello"}`
+
+var DummyProfile = Profile{BaseModel: BaseModel{CreatedBy: "admin"}, Bio: "hello"}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Server-managed field setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type Post struct {
+	Title     string `json:"title" _id_:"post" _hd_:"c"`
+	CreatedBy string `json:"createdBy" _hd_:"c" _srv_:"true"`
+}
+
+const DummyPostJSON = `{"title": "hello", "createdBy": "attacker"}`
+
+var DummyPost = Post{Title: "hello"}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Request name setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type Subscriber struct {
+	Name  string `json:"name" _id_:"subscriber" _hd_:"c"`
+	Email string `json:"email" _req_:"e_mail" _hd_:"c"`
+}
+
+const DummySubscriberJSON = `{"name": "sub", "email": "ignored@user.com", "e_mail": "sub@user.com"}`
+
+var DummySubscriber = Subscriber{Name: "sub", Email: "sub@user.com"}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Inline embedding setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type Address struct {
+	City    string `json:"city" bson:"city" _hd_:"c"`
+	Country string `json:"country" bson:"country" _hd_:"c"`
+}
+
+type Customer struct {
+	Name    string  `json:"name" bson:"name" _id_:"customer" _hd_:"c"`
+	Address Address `json:"address" bson:",inline"`
+}
+
+const DummyCustomerJSON = `{"name": "cust", "city": "Waterloo", "country": "Canada"}`
+
+var DummyCustomer = Customer{Name: "cust", Address: Address{City: "Waterloo", Country: "Canada"}}
+
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+// Reference setup
+//~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+type Ticket struct {
+	ID   primitive.ObjectID `json:"-" bson:"_id" _id_:"ticket"`
+	Code string             `json:"code" bson:"code" _ax_:"true" _hd_:"c"`
+}
+
+type TicketHolder struct {
+	Name   string             `json:"name" bson:"name" _id_:"ticket-holder" _hd_:"c"`
+	Ticket primitive.ObjectID `json:"ticket" bson:"ticket" _ref_:"ticket" _hd_:"c"`
+}
+
+type EBadReference struct {
+	Ticket string `json:"ticket" bson:"ticket" _id_:"bad-reference" _ref_:"ticket" _hd_:"c"`
+}
+
+// Shipment embeds a ShippingAddress whose JSON and BSON names differ
+type Shipment struct {
+	Label   string          `json:"label" _id_:"shipment" _hd_:"c"`
+	Address ShippingAddress `json:"address" _hd_:"c"`
+}
+
+type ShippingAddress struct {
+	Street string `json:"street" bson:"street_name" _id_:"shipping-address" _hd_:"c"`
+	City   string `json:"city" bson:"city_name" _hd_:"c"`
+}
+
+// Extensible collects unknown payload keys into Extra
+type Extensible struct {
+	Name  string                 `json:"name" _id_:"extensible" _hd_:"c"`
+	Age   int                    `json:"age" _hd_:"c"`
+	Extra map[string]interface{} `json:"extra" _ext_:"true" _hd_:"c"`
+}
+
+const DummyExtensibleJSON = `{"name": "jane", "age": 30, "theme": "dark", "beta": true}`
+
+type EDuplicateCatchAll struct {
+	Name   string                 `json:"name" _id_:"duplicate-catch-all" _hd_:"c"`
+	Extra  map[string]interface{} `json:"extra" _ext_:"true" _hd_:"c"`
+	Extra2 map[string]interface{} `json:"extra2" _ext_:"true" _hd_:"c"`
+}
+
+// Mailbox case-folds its axis email
+type Mailbox struct {
+	Email string `json:"email" _id_:"mailbox" _ax_:"true" _norm_:"lower" _hd_:"c"`
+}
+
+// Schedule embeds TaskDetails, which Task embeds too
+type Schedule struct {
+	Details TaskDetails `json:"details" _id_:"schedule" _hd_:"c"`
+}
+
+// Ordered declares several creation and axis fields
+type Ordered struct {
+	Zeta  string `json:"zeta" _id_:"!ordered" _ax_:"true" _ix_:"true" _hd_:"c"`
+	Alpha string `json:"alpha" _ax_:"true" _ix_:"true" _hd_:"c"`
+	Mid   string `json:"mid" _ax_:"true" _ix_:"true" _hd_:"c"`
+	Beta  string `json:"beta" _hd_:"c"`
+}
+
+type ESystemID struct {
+	Name string `json:"name" _id_:"system.foo" _hd_:"c"`
+}
+
+type ESystemIDNoColl struct {
+	Name string `json:"name" _id_:"!system.foo" _hd_:"c"`
+}
+
+type EDollarID struct {
+	Name string `json:"name" _id_:"price$" _hd_:"c"`
+}
+
+type ESystemLikeID struct {
+	Name string `json:"name" _id_:"!systems" _hd_:"c"`
+}
+
+// Note is created, edited and (with an unknown 'd' token) tagged for deletion
+type Note struct {
+	ID   primitive.ObjectID `json:"-" bson:"_id" _id_:"note"`
+	Body string             `json:"body" _hd_:"cde"`
+}
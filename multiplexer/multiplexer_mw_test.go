@@ -2,14 +2,23 @@ package multiplexer
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity"
 	"github.com/navaz-alani/entity/entityErrors"
 	"github.com/navaz-alani/entity/multiplexer/muxContext"
+	"github.com/navaz-alani/entity/spec"
 )
 
 /*
@@ -51,6 +60,21 @@ var requestTests = [...]reqTest{
 		"project", DummyProjectJSON,
 		DummyProject,
 	},
+	{
+		[]interface{}{Profile{}},
+		"profile", DummyProfileJSON,
+		DummyProfile,
+	},
+	{
+		[]interface{}{OptionalTask{}, TaskDetails{}},
+		"optional-task", DummyOptionalTaskJSON,
+		DummyOptionalTask,
+	},
+	{
+		[]interface{}{PtrCollUser{}, Task{}, TaskDetails{}},
+		"user-ptr-coll", DummyPtrCollUserJSON,
+		DummyPtrCollUser,
+	},
 }
 
 func TestEntityMux_CreationMiddlewareNoCHandleFields(t *testing.T) {
@@ -66,13 +90,13 @@ func TestEntityMux_CreationMiddlewareNoCHandleFields(t *testing.T) {
 	}
 }
 
-func EntityMux_CreationMiddlewareRequestParseTestHelper(t *testing.T, rt *reqTest) {
+func EntityMux_CreationMiddlewareRequestParseTestHelper(t *testing.T, rt *reqTest, opts ...CreationOption) {
 	mux, err := Create(TestDB{}, rt.Definitions...)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	hd, err := mux.CreationMiddleware(rt.EntityID)
+	hd, err := mux.CreationMiddleware(rt.EntityID, opts...)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -119,3 +143,875 @@ func TestEntityMux_CreationMiddlewareRequestCollectionEmbed(t *testing.T) {
 func TestEntityMux_CreationMiddlewareRequestCollectionsEmbedDeep(t *testing.T) {
 	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &requestTests[3])
 }
+
+func TestEntityMux_CreationMiddlewareRequestPromotedFields(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &requestTests[4])
+}
+
+func TestEntityMux_CreationMiddlewareRequestPointerEmbed(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &requestTests[5])
+}
+
+func TestEntityMux_CreationMiddlewareRequestPointerCollection(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &requestTests[6])
+}
+
+func TestEntityMux_CreationMiddlewareRequestObjectID(t *testing.T) {
+	rt := &reqTest{
+		[]interface{}{ClientNote{}},
+		"client-note", DummyClientNoteJSON,
+		DummyClientNote,
+	}
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, rt)
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, rt, WithStreaming())
+
+	mux, err := Create(TestDB{}, ClientNote{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := mux.ValidateBatch("client-note", []map[string]interface{}{
+		{"id": "5e8f1f7b4f1a4e6d9c3b2a10", "text": "note"},
+		{"id": "not-a-hex-id", "text": "note"},
+	})
+	if len(results[0].Errors) != 0 {
+		t.Errorf("hex ID rejected: %v", results[0].Errors)
+	}
+	if len(results[1].Errors) != 1 || !errors.Is(results[1].Errors[0], entityErrors.InvalidDataType) {
+		t.Errorf("expected malformed ID to be rejected, got %v", results[1].Errors)
+	}
+}
+
+func TestEntityMux_CreationMiddlewareRequestTime(t *testing.T) {
+	rt := &reqTest{
+		[]interface{}{Meeting{}},
+		"meeting", DummyMeetingJSON,
+		DummyMeeting,
+	}
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, rt)
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, rt, WithStreaming())
+}
+
+func TestEntityMux_CreationMiddlewareValidation(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &reqTest{
+		[]interface{}{ValidatedUser{}},
+		"validated-user", DummyValidatedUserJSON,
+		DummyValidatedUser,
+	})
+}
+
+func TestEntityMux_CreationMiddlewareValidationFail(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("validated-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/", bytes.NewReader([]byte(DummyInvalidUserJSON)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(w http.ResponseWriter, r *http.Request) {
+		muxCtx, err := muxContext.IsolateCtx(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if muxCtx.Error() == nil {
+			t.Fatal("expected validation error")
+		}
+		if data := muxCtx.Retrieve("validated-user"); data != nil {
+			t.Fatal("invalid entity stored in context")
+		}
+	}
+
+	handler := hd(http.HandlerFunc(verify))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func EntityMux_RetrievalMiddlewareTestHelper(t *testing.T, url string, verify func(*muxContext.EMuxContext), opts ...RetrievalOption) {
+	mux, err := Create(TestDB{}, Member{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.RetrievalMiddleware("member", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	handler := hd(func(w http.ResponseWriter, r *http.Request) {
+		muxCtx, err := muxContext.IsolateCtx(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		called = true
+		verify(muxCtx)
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Fatal("handler not called")
+	}
+}
+
+func TestEntityMux_RetrievalMiddlewareQueryParse(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?age__gt=18&status=active&name=ignored",
+		func(muxCtx *muxContext.EMuxContext) {
+			expected := []spec.ESpec{
+				{Field: "status", Target: "active"},
+				{Field: "age", Target: int64(18), QueryOperator: "gt"},
+			}
+
+			if specs := muxCtx.Retrieve("member"); !reflect.DeepEqual(specs, expected) {
+				log.Print("got:      ", specs)
+				log.Print("expected: ", expected)
+				t.Fail()
+			}
+		})
+}
+
+func TestEntityMux_RetrievalMiddlewareQueryParseIn(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?age__in=18,21",
+		func(muxCtx *muxContext.EMuxContext) {
+			expected := []spec.ESpec{
+				{Field: "age", Target: []interface{}{int64(18), int64(21)}, QueryOperator: "in"},
+			}
+
+			if specs := muxCtx.Retrieve("member"); !reflect.DeepEqual(specs, expected) {
+				t.Fail()
+			}
+		})
+}
+
+func TestEntityMux_RetrievalMiddlewareQueryParseRegex(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?status__regex=(a%2B)%2B$",
+		func(muxCtx *muxContext.EMuxContext) {
+			specs := muxCtx.Retrieve("member").([]spec.ESpec)
+			expected := bson.M{"status": bson.M{"$regex": `\(a\+\)\+\$`, "$options": "i"}}
+
+			if len(specs) != 1 || !reflect.DeepEqual(specs[0].ToBSON(), expected) {
+				t.Errorf("expected escaped regex, got %v", specs)
+			}
+		})
+
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?status__regex=^act",
+		func(muxCtx *muxContext.EMuxContext) {
+			specs := muxCtx.Retrieve("member").([]spec.ESpec)
+			expected := bson.M{"status": bson.M{"$regex": "^act", "$options": "i"}}
+
+			if len(specs) != 1 || !reflect.DeepEqual(specs[0].ToBSON(), expected) {
+				t.Errorf("expected raw regex, got %v", specs)
+			}
+		}, WithRawRegex())
+}
+
+func TestEntityMux_RetrievalMiddlewareQueryParseFail(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?age__gt=eighteen",
+		func(muxCtx *muxContext.EMuxContext) {
+			if muxCtx.Error() == nil || muxCtx.Retrieve("member") != nil {
+				t.Fail()
+			}
+		})
+}
+
+func TestEntityMux_RetrievalMiddlewarePagination(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?limit=1000&page=3&sort=-createdAt,age",
+		func(muxCtx *muxContext.EMuxContext) {
+			expected := &Pagination{
+				Limit:  DefaultMaxLimit,
+				Offset: 2 * DefaultMaxLimit,
+				Sort:   bson.D{{Key: "created_at", Value: -1}, {Key: "age", Value: 1}},
+			}
+
+			if pagination := muxCtx.Retrieve(PaginationKey); !reflect.DeepEqual(pagination, expected) {
+				log.Print("got:      ", pagination)
+				log.Print("expected: ", expected)
+				t.Fail()
+			}
+		})
+}
+
+func TestEntityMux_RetrievalMiddlewarePaginationDefaults(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members",
+		func(muxCtx *muxContext.EMuxContext) {
+			expected := &Pagination{Limit: DefaultPageLimit, Sort: bson.D{}}
+
+			if pagination := muxCtx.Retrieve(PaginationKey); !reflect.DeepEqual(pagination, expected) {
+				t.Fail()
+			}
+		})
+}
+
+func TestEntityMux_RetrievalMiddlewarePaginationOverflow(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?limit=100&page=9223372036854775807",
+		func(muxCtx *muxContext.EMuxContext) {
+			if !errors.Is(muxCtx.Error(), entityErrors.InvalidDataType) || muxCtx.Retrieve(PaginationKey) != nil {
+				t.Errorf("expected overflowing page to be rejected, got %v", muxCtx.Error())
+			}
+		})
+}
+
+func TestEntityMux_RetrievalMiddlewareInvalidLimits(t *testing.T) {
+	mux, err := Create(TestDB{}, Member{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opt := range []RetrievalOption{WithMaxLimit(0), WithDefaultLimit(0), WithMaxLimit(-5)} {
+		if _, err := mux.RetrievalMiddleware("member", opt); err != entityErrors.InvalidDataType {
+			t.Errorf("expected invalid limit to be rejected, got %v", err)
+		}
+	}
+}
+
+func TestEntityMux_RetrievalMiddlewarePaginationInvalidSort(t *testing.T) {
+	EntityMux_RetrievalMiddlewareTestHelper(t, "/members?sort=-name",
+		func(muxCtx *muxContext.EMuxContext) {
+			if muxCtx.Error() == nil {
+				t.Fail()
+			}
+		})
+}
+
+func TestEntityMux_CreationMiddlewareBodyTooLarge(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user", WithMaxBodySize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(DummyUserDataJSON)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler called for oversized payload")
+	}).ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fail()
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(&limitedBody{r: strings.NewReader("abcd"), n: 4}); err != nil {
+		t.Errorf("expected payload at the limit to be read, got %v", err)
+	}
+
+	n, err := buf.ReadFrom(&limitedBody{r: strings.NewReader("abcde"), n: 4})
+	if !errors.Is(err, entityErrors.PayloadTooLarge) || n != 4 {
+		t.Errorf("expected PayloadTooLarge after 4 bytes, got %d, %v", n, err)
+	}
+}
+
+func TestCreationOptions(t *testing.T) {
+	optionTests := []struct {
+		Options  CreationOptions
+		Expected creationConfig
+	}{
+		{CreationOptions{}, creationConfig{maxBodySize: DefaultMaxBodySize}},
+		{CreationOptions{MaxBodySize: 16}, creationConfig{maxBodySize: 16}},
+		{CreationOptions{Preview: true}, creationConfig{maxBodySize: DefaultMaxBodySize, preview: true}},
+		{CreationOptions{StrictFields: true}, creationConfig{maxBodySize: DefaultMaxBodySize, strict: true}},
+		{CreationOptions{Streaming: true}, creationConfig{maxBodySize: DefaultMaxBodySize, streaming: true}},
+		{CreationOptions{EntityPool: true}, creationConfig{maxBodySize: DefaultMaxBodySize, pooled: true}},
+		{CreationOptions{ContextPool: true}, creationConfig{maxBodySize: DefaultMaxBodySize, pooledCtx: true}},
+		{CreationOptions{DecodeErrorPassthrough: true}, creationConfig{maxBodySize: DefaultMaxBodySize, decodePassthrough: true}},
+	}
+
+	for _, ot := range optionTests {
+		if cfg := newCreationConfig(ot.Options.options()); *cfg != ot.Expected {
+			t.Errorf("options %+v: expected %+v, got %+v", ot.Options, ot.Expected, *cfg)
+		}
+	}
+}
+
+func TestEntityMux_CreationMiddlewareWithOptions(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddlewareWithOptions("user", CreationOptions{MaxBodySize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(DummyUserDataJSON)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler called for oversized payload")
+	}).ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fail()
+	}
+}
+
+func TestEntityMux_CreationMiddlewareDefault(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &reqTest{
+		[]interface{}{Account{}},
+		"account", DummyAccountJSON,
+		DummyAccount,
+	})
+}
+
+func TestEntityMux_CreationMiddlewareDefaultOverride(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &reqTest{
+		[]interface{}{Account{}},
+		"account", DummyAccountStatusJSON,
+		DummyAccountStatus,
+	})
+}
+
+func TestEntityMux_CreationMiddlewareServerManaged(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &reqTest{
+		[]interface{}{Post{}},
+		"post", DummyPostJSON,
+		DummyPost,
+	})
+}
+
+func TestEntityMux_CreationMiddlewareRequestTag(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &reqTest{
+		[]interface{}{Subscriber{}},
+		"subscriber", DummySubscriberJSON,
+		DummySubscriber,
+	})
+}
+
+func TestEntityMux_CreationMiddlewareInlineEmbed(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &reqTest{
+		[]interface{}{Customer{}},
+		"customer", DummyCustomerJSON,
+		DummyCustomer,
+	})
+}
+
+func TestEntityMux_CreationMiddlewareComputedField(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mux.E("user").AddComputedField("Email", func(e interface{}) (interface{}, error) {
+		return "computed@user.com", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := mux.createEntity(mux.Entities["user"], map[string]interface{}{
+		"name":  DummyUserData.Name,
+		"email": DummyUserData.Email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(value.Interface(), TestUser{Name: DummyUserData.Name}) {
+		t.Fail()
+	}
+}
+
+func TestEntityMux_CreateEntityEmbeddedErrorCause(t *testing.T) {
+	mux, err := Create(TestDB{}, UserEmbed{}, Task{}, TaskDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mux.createEntity(mux.meta("user-embed"), map[string]interface{}{
+		"tasks": map[string]interface{}{
+			"name":    "test task",
+			"details": map[string]interface{}{"date": 5},
+		},
+	})
+
+	if !errors.Is(err, entityErrors.EmbeddedWriteDataInvalid) {
+		t.Errorf("expected EmbeddedWriteDataInvalid, got %v", err)
+	}
+	if !errors.Is(err, entityErrors.InvalidDataType) {
+		t.Errorf("cause of embedded write failure lost: %v", err)
+	}
+}
+
+func TestEntityMux_CreationMiddlewareRegisteredEntity(t *testing.T) {
+	mux, err := Create(TestDB{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ety, err := entity.NewEntity(reflect.TypeOf(TestUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ety.AddComputedField("Email", func(e interface{}) (interface{}, error) {
+		return "computed@user.com", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.RegisterEntity("hand-user", ety); err != nil {
+		t.Fatal(err)
+	}
+	if mux.E("hand-user") != ety || mux.TypeMap[reflect.TypeOf(TestUser{})] != "hand-user" {
+		t.Fatal("entity not registered")
+	}
+	if err := mux.RegisterEntity("hand-user", ety); err == nil {
+		t.Errorf("duplicate entityID registered")
+	}
+	if err := mux.RegisterEntity("nil-entity", nil); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected IncompatibleEntityType, got %v", err)
+	}
+
+	hd, err := mux.CreationMiddleware("hand-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte(DummyUserDataJSON)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		muxCtx, err := muxContext.IsolateCtx(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// computed fields of the hand-built entity are not read from the payload
+		if data := muxCtx.Retrieve("hand-user"); data != (TestUser{Name: DummyUserData.Name}) {
+			t.Errorf("unexpected entity: %v", data)
+		}
+	}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Fail()
+	}
+}
+
+func TestEntityMux_CreationMiddlewarePreview(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user", WithPreview())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/?preview=true", bytes.NewReader([]byte(DummyUserDataJSON)))
+	rec := httptest.NewRecorder()
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("next handler called for preview request")
+	}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+
+	var previewed TestUser
+	if err := json.NewDecoder(rec.Body).Decode(&previewed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(previewed, DummyUserData) {
+		t.Errorf("unexpected preview: %v", previewed)
+	}
+}
+
+func TestEntityMux_CreationMiddlewarePreviewDisabled(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(DummyUserDataJSON)))
+	req.Header.Set(PreviewHeader, "true")
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}).ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Fail()
+	}
+}
+
+func TestEntityMux_CreationMiddlewareStrictFields(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user", WithStrictFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name": "Dummy"}`)))
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		muxCtx, err := muxContext.IsolateCtx(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = muxCtx.Error()
+		if !errors.Is(err, entityErrors.BodyIncomplete) || !strings.Contains(err.Error(), "email") {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if muxCtx.Retrieve("user") != nil {
+			t.Errorf("incomplete entity stored")
+		}
+	}).ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestEntityMux_CreationMiddlewareStreaming(t *testing.T) {
+	for i := range requestTests {
+		EntityMux_CreationMiddlewareRequestParseTestHelper(t, &requestTests[i], WithStreaming())
+	}
+}
+
+func TestEntityMux_CreationMiddlewareStreamingDecodeFail(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user", WithStreaming())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name": "Dummy", `)))
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("next handler called for malformed payload")
+	}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status %d", rec.Code)
+	}
+}
+
+func TestEntityMux_CreationMiddlewareDecodeErrorTyped(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var responded error
+	mux.SetErrorResponder(func(w http.ResponseWriter, err error) {
+		responded = err
+		DefaultErrorResponder(w, err)
+	})
+
+	hd, err := mux.CreationMiddleware("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name": "Dummy", `)))
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("next handler called for malformed payload")
+	}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !errors.Is(responded, entityErrors.PayloadDecodeFailed) {
+		t.Errorf("expected PayloadDecodeFailed response, got %d: %v", rec.Code, responded)
+	}
+}
+
+func TestEntityMux_CreationMiddlewareDecodeErrorPassthrough(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		mux, err := Create(TestDB{}, TestUser{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hd, err := mux.CreationMiddlewareWithOptions("user", CreationOptions{
+			Streaming:              streaming,
+			DecodeErrorPassthrough: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		called := false
+		req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte(`{"name": }`)))
+		hd(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			muxCtx, err := muxContext.IsolateCtx(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var syntaxErr *json.SyntaxError
+			decodeErr := muxCtx.Error()
+			if !errors.Is(decodeErr, entityErrors.PayloadDecodeFailed) {
+				t.Errorf("streaming %t: expected PayloadDecodeFailed, got %v", streaming, decodeErr)
+			} else if !errors.As(decodeErr, &syntaxErr) {
+				t.Errorf("json error not wrapped: %v", decodeErr)
+			}
+		}).ServeHTTP(httptest.NewRecorder(), req)
+
+		if !called {
+			t.Errorf("streaming %t: next handler not called", streaming)
+		}
+	}
+}
+
+/*
+largeCollectionPayload returns the JSON payload of an EmbedCollUser
+with n tasks.
+*/
+func largeCollectionPayload(n int) []byte {
+	tasks := make([]string, n)
+	for i := range tasks {
+		tasks[i] = `{"name": "test task", "details": {"date": "ISO_DUMMY_DATE"}}`
+	}
+	return []byte(`{"tasks": [` + strings.Join(tasks, ",") + `]}`)
+}
+
+func benchmarkCreation(b *testing.B, streaming bool) {
+	mux, err := Create(TestDB{}, EmbedCollUser{}, Task{}, TaskDetails{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	meta := mux.Entities["user-embed-coll"]
+	payload := largeCollectionPayload(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if streaming {
+			_, err = mux.streamEntity(meta, json.NewDecoder(bytes.NewReader(payload)), false)
+		} else {
+			_, err = mux.decodeEntity(meta, bytes.NewReader(payload), &creationConfig{})
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreationDecode(b *testing.B) {
+	benchmarkCreation(b, false)
+}
+
+func BenchmarkCreationStream(b *testing.B) {
+	benchmarkCreation(b, true)
+}
+
+func TestEntityMux_CreationPayloadPoolCleared(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := mux.Entities["user"]
+
+	for _, payload := range []string{DummyUserDataJSON, `{"name": "Other"}`} {
+		value, err := mux.decodeEntity(meta, strings.NewReader(payload), &creationConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if payload != DummyUserDataJSON && value.Interface() != (TestUser{Name: "Other"}) {
+			t.Errorf("pooled payload leaked values: %v", value.Interface())
+		}
+	}
+}
+
+func BenchmarkCreationDecodeSmall(b *testing.B) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	meta := mux.Entities["user"]
+	payload := []byte(DummyUserDataJSON)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mux.decodeEntity(meta, bytes.NewReader(payload), &creationConfig{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEntityMux_CreationMiddlewareEntityPool(t *testing.T) {
+	mux, err := Create(TestDB{}, EmbedCollUser{}, Task{}, TaskDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user-embed-coll", WithEntityPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("task-%d", n)
+			payload := fmt.Sprintf(`{"tasks": [{"name": "%s", "details": {"date": "%s"}}]}`, name, name)
+			req := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+
+			hd(func(w http.ResponseWriter, r *http.Request) {
+				muxCtx, err := muxContext.IsolateCtx(r)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				user, _ := muxCtx.Retrieve("user-embed-coll").(EmbedCollUser)
+				if len(user.Tasks) != 1 || user.Tasks[0].Name != name || user.Tasks[0].Details.Date != name {
+					t.Errorf("request %d: fields bled between requests: %v", n, user)
+				}
+			}).ServeHTTP(httptest.NewRecorder(), req)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEntityMux_CreationMiddlewareContextPool(t *testing.T) {
+	EntityMux_CreationMiddlewareRequestParseTestHelper(t, &requestTests[0], WithContextPool())
+}
+
+func TestEntityMux_CreateEntityKeyFallback(t *testing.T) {
+	mux, err := Create(TestDB{}, Shipment{}, ShippingAddress{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := map[string]interface{}{
+		"label": "parcel",
+		"address": map[string]interface{}{
+			"street_name": "King St",
+			"City":        "Toronto",
+		},
+	}
+
+	value, err := mux.createEntity(mux.meta("shipment"), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Interface().(Shipment).Address != (ShippingAddress{}) {
+		t.Errorf("fallback keys used without SetKeyFallback: %v", value.Interface())
+	}
+
+	mux.SetKeyFallback(true)
+	value, err = mux.createEntity(mux.meta("shipment"), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Shipment{Label: "parcel", Address: ShippingAddress{Street: "King St", City: "Toronto"}}
+	if !reflect.DeepEqual(value.Interface(), expected) {
+		t.Errorf("expected %v, got %v", expected, value.Interface())
+	}
+
+	body := strings.NewReader(`{"label": "parcel", "address": {"street_name": "King St", "street": "Queen St"}}`)
+	streamed, err := mux.streamEntity(mux.meta("shipment"), json.NewDecoder(body), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if street := streamed.Interface().(Shipment).Address.Street; street != "Queen St" {
+		t.Errorf("expected RequestID to take precedence, got %q", street)
+	}
+}
+
+func TestEntityMux_CreateEntityCatchAll(t *testing.T) {
+	mux, err := Create(TestDB{}, Extensible{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Extensible{
+		Name:  "jane",
+		Age:   30,
+		Extra: map[string]interface{}{"theme": "dark", "beta": true},
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(DummyExtensibleJSON), &payload); err != nil {
+		t.Fatal(err)
+	}
+	value, err := mux.createEntity(mux.meta("extensible"), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(value.Interface(), expected) {
+		t.Errorf("expected %v, got %v", expected, value.Interface())
+	}
+
+	streamed, err := mux.streamEntity(mux.meta("extensible"), json.NewDecoder(strings.NewReader(DummyExtensibleJSON)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(streamed.Interface(), expected) {
+		t.Errorf("expected %v, got %v", expected, streamed.Interface())
+	}
+}
+
+func TestEntityMux_CreateDuplicateCatchAll(t *testing.T) {
+	if _, err := Create(TestDB{}, EDuplicateCatchAll{}); err == nil {
+		t.Errorf("expected error for more than one catch-all field")
+	}
+}
+
+func TestEntityMux_CreateEntityNormalize(t *testing.T) {
+	mux, err := Create(TestDB{}, Mailbox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := mux.createEntity(mux.meta("mailbox"), map[string]interface{}{"email": "Jane@Example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email := value.Interface().(Mailbox).Email; email != "jane@example.com" {
+		t.Errorf("expected lowercased email, got %q", email)
+	}
+
+	filter, err := mux.AxisFilter("mailbox", map[string]interface{}{"email": "JANE@example.COM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filter, bson.M{"email": "jane@example.com"}) {
+		t.Errorf("unexpected filter: %v", filter)
+	}
+}
@@ -1,12 +1,25 @@
 package multiplexer
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
 
+	"github.com/navaz-alani/entity"
 	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/multiplexer/muxContext"
 )
 
 var db = &mongo.Database{}
@@ -48,6 +61,363 @@ func (db TestDB) Collection(name string, opts ...*options.CollectionOptions) *mo
 	return &mongo.Collection{}
 }
 
+// database type recording the options given for each collection
+type OptionsDB struct {
+	opts map[string][]*options.CollectionOptions
+}
+
+func (db OptionsDB) Collection(name string, opts ...*options.CollectionOptions) *mongo.Collection {
+	db.opts[name] = opts
+	return &mongo.Collection{}
+}
+
+func TestCreateDefinitionOptions(t *testing.T) {
+	db := OptionsDB{opts: make(map[string][]*options.CollectionOptions)}
+	collOpts := options.Collection().SetReadConcern(readconcern.Majority())
+
+	mux, err := Create(db, Definition{
+		Type:    EDupID1{},
+		Options: []*options.CollectionOptions{collOpts},
+	}, ENoDBColl{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts := db.opts["<id>"]; len(opts) != 1 || opts[0] != collOpts {
+		t.Errorf("collection options not passed: %v", opts)
+	}
+	if mux.E("<id>").SchemaDefinition != reflect.TypeOf(EDupID1{}) {
+		t.Errorf("wrapped definition not registered")
+	}
+}
+
+// database type which records disconnections
+type DisconnectDB struct {
+	TestDB
+	disconnects *int
+}
+
+func (db DisconnectDB) Disconnect(ctx context.Context) error {
+	*db.disconnects++
+	return nil
+}
+
+func TestEMuxClose(t *testing.T) {
+	mux, err := Create(TestDB{}, EDupID1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if mux.E("<id>") != nil {
+		t.Errorf("entities not released")
+	}
+}
+
+func TestEMuxCloseDisconnects(t *testing.T) {
+	disconnects := 0
+	mux, err := Create(DisconnectDB{disconnects: &disconnects}, EDupID1{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = mux.Close(context.Background())
+	_ = mux.Close(context.Background())
+	if disconnects != 1 {
+		t.Errorf("expected 1 disconnect, got %d", disconnects)
+	}
+}
+
+func TestEMuxRegisterConcurrent(t *testing.T) {
+	mux, err := Create(TestDB{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	definitions := []interface{}{TestUser{}, Member{}, Account{}, ValidatedUser{}, Post{}, Profile{}}
+	errs := make(chan error, len(definitions))
+
+	var wg sync.WaitGroup
+	for _, def := range definitions {
+		wg.Add(1)
+		go func(def interface{}) {
+			defer wg.Done()
+			errs <- mux.Register(def)
+		}(def)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mux.Entities) != len(definitions) || len(mux.TypeMap) != len(definitions) {
+		t.Errorf("expected %d entities, got %d", len(definitions), len(mux.Entities))
+	}
+}
+
+func TestEMuxRegisterWhileServing(t *testing.T) {
+	mux, err := Create(TestDB{}, UserEmbed{}, Task{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hd, err := mux.CreationMiddleware("user-embed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := hd(func(w http.ResponseWriter, r *http.Request) {})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			req := httptest.NewRequest("POST", "/", strings.NewReader(dummyEmbedDataJSON))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}()
+
+	for _, def := range []interface{}{TaskDetails{}, Member{}, Account{}, ValidatedUser{}} {
+		if err := mux.Register(def); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if err := mux.Deregister("member", false); err != nil {
+			t.Fatal(err)
+		}
+		if err := mux.Register(Member{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// links to Entities registered later are used by existing middleware
+	var embedded interface{}
+	handler = hd(func(w http.ResponseWriter, r *http.Request) {
+		muxCtx, _ := muxContext.IsolateCtx(r)
+		embedded = muxCtx.Retrieve("user-embed")
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(dummyEmbedDataJSON)))
+	if !reflect.DeepEqual(embedded, DummyUserEmbed) {
+		t.Errorf("expected %v, got %v", DummyUserEmbed, embedded)
+	}
+}
+
+func TestEMuxLinkCopyOnWrite(t *testing.T) {
+	mux, err := Create(TestDB{}, UserEmbed{}, Task{}, TaskDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// metadata in use by middleware must not change under it
+	before := mux.meta("user-embed")
+	tasks := before.FieldClassifications[CreationFieldsToken][0]
+	linked := tasks.EmbeddedEntity.Meta
+	if linked == nil {
+		t.Fatal("embedded entity not linked")
+	}
+
+	if err := mux.Deregister("task", true); err != nil {
+		t.Fatal(err)
+	}
+	if tasks.EmbeddedEntity.Meta != linked {
+		t.Errorf("link of existing metadata changed")
+	}
+	if after := mux.meta("user-embed"); after.FieldClassifications[CreationFieldsToken][0].EmbeddedEntity.Meta != nil {
+		t.Errorf("link to removed entity not cleared")
+	}
+}
+
+func TestEMuxRegisterDuplicateType(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ety, err := entity.NewEntity(reflect.TypeOf(TestUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mux.RegisterEntity("other-user", ety); err == nil {
+		t.Errorf("expected duplicate type to be rejected")
+	}
+	if mux.E("other-user") != nil {
+		t.Errorf("duplicate type registered")
+	}
+}
+
+func TestEMuxRegisterDuplicate(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Register(TestUser{}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestEMuxDeregister(t *testing.T) {
+	mux, err := Create(TestDB{}, UserEmbed{}, Task{}, TaskDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Deregister("task", false); err == nil {
+		t.Errorf("embedded entity removed without force")
+	}
+	if mux.E("task") == nil {
+		t.Fatal("rejected deregistration removed entity")
+	}
+
+	if err := mux.Deregister("unknown", false); err != entityErrors.InvalidEntityID {
+		t.Errorf("unknown entity deregistered")
+	}
+}
+
+func TestEMuxDeregisterForce(t *testing.T) {
+	mux, err := Create(TestDB{}, UserEmbed{}, Task{}, TaskDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Deregister("task", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if mux.E("task") != nil || mux.TypeMap[reflect.TypeOf(Task{})] != "" {
+		t.Errorf("entity not removed")
+	}
+	for _, field := range mux.Entities["user-embed"].FieldClassifications[CreationFieldsToken] {
+		if field.EmbeddedEntity.Meta != nil {
+			t.Errorf("dangling link to removed entity")
+		}
+	}
+}
+
+// tenant-sharded entity
+type TenantUser struct {
+	Name  string `json:"name" _id_:"users_{tenant}" _hd_:"c"`
+	Email string `json:"email" _ax_:"true"`
+}
+
+func TestEMuxCollectionFor(t *testing.T) {
+	db := OptionsDB{opts: make(map[string][]*options.CollectionOptions)}
+	mux, err := Create(db, TenantUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.opts) != 0 {
+		t.Errorf("templated collection created at registration")
+	}
+
+	acme := mux.CollectionFor("users_{tenant}", map[string]string{"tenant": "acme"})
+	globex := mux.CollectionFor("users_{tenant}", map[string]string{"tenant": "globex"})
+	if acme == nil || globex == nil || acme == globex {
+		t.Fatal("expected distinct tenant collections")
+	}
+
+	for _, name := range []string{"users_acme", "users_globex"} {
+		if _, ok := db.opts[name]; !ok {
+			t.Errorf("collection '%s' not created", name)
+		}
+	}
+
+	if mux.CollectionFor("users_{tenant}", nil) != nil {
+		t.Errorf("collection returned for unresolved template")
+	}
+	if ety := mux.EFor("users_{tenant}", map[string]string{"tenant": "acme"}); ety == nil || ety.PStorage != acme {
+		t.Errorf("entity not routed to tenant collection")
+	}
+}
+
+func TestEMuxCollectionForInvalidTenant(t *testing.T) {
+	db := OptionsDB{opts: make(map[string][]*options.CollectionOptions)}
+	mux, err := Create(db, TenantUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenant := range []string{"", "a$b", "a\x00b", "{tenant}"} {
+		if mux.CollectionFor("users_{tenant}", map[string]string{"tenant": tenant}) != nil {
+			t.Errorf("collection returned for tenant %q", tenant)
+		}
+	}
+	if len(db.opts) != 0 {
+		t.Errorf("collections created for invalid tenants: %v", db.opts)
+	}
+}
+
+func TestEMuxCollectionGuard(t *testing.T) {
+	db := OptionsDB{opts: make(map[string][]*options.CollectionOptions)}
+	known := map[string]bool{"acme": true}
+	mux, err := Create(db, TenantUser{}, WithCollectionGuard(func(entityID string, params map[string]string) bool {
+		return known[params[TenantKey]]
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mux.CollectionFor("users_{tenant}", map[string]string{TenantKey: "acme"}) == nil {
+		t.Errorf("allowed tenant rejected")
+	}
+	if mux.CollectionFor("users_{tenant}", map[string]string{TenantKey: "initech"}) != nil {
+		t.Errorf("unknown tenant allowed")
+	}
+	if _, ok := db.opts["users_initech"]; ok {
+		t.Errorf("collection created for rejected tenant")
+	}
+}
+
+// json and (absent) bson names diverge
+type EDivergedNames struct {
+	Email string `json:"e_mail" _id_:"diverged"`
+}
+
+func TestCreateStorageNames(t *testing.T) {
+	if err := checkStorageNames(reflect.TypeOf(EDivergedNames{})); err == nil {
+		t.Errorf("diverging names not detected")
+	}
+	if err := checkStorageNames(reflect.TypeOf(TestUser{})); err != nil {
+		t.Errorf("matching names rejected: %s", err)
+	}
+
+	if _, err := Create(TestDB{}, EDivergedNames{}); err != nil {
+		t.Errorf("non-strict definition rejected: %s", err)
+	}
+
+	var warnings []error
+	_, err := Create(TestDB{}, EDivergedNames{}, WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "EDivergedNames") {
+		t.Errorf("expected storage name warning, got %v", warnings)
+	}
+
+	if _, err := Create(TestDB{}, Definition{Type: EDivergedNames{}, StrictNames: true}); err == nil {
+		t.Errorf("strict definition with diverging names accepted")
+	}
+}
+
 func TestCreateDBUninitialized(t *testing.T) {
 	_, err := Create(nil)
 	if err != entityErrors.DBUninitialized {
@@ -76,6 +446,218 @@ func TestCreateNoDupID(t *testing.T) {
 	}
 }
 
+func TestEMuxValidate(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Validate(DummyValidatedUser); err != nil {
+		t.Errorf("valid user rejected: %s", err)
+	}
+	if err := mux.Validate(&DummyValidatedUser); err != nil {
+		t.Errorf("valid user pointer rejected: %s", err)
+	}
+	if err := mux.Validate(DummyInvalidUser); err == nil {
+		t.Errorf("invalid user accepted")
+	}
+	if err := mux.Validate(EDupID1{}); err != entityErrors.UnregisteredEntityType {
+		t.Errorf("unregistered type accepted")
+	}
+}
+
+func TestCreatePromotedID(t *testing.T) {
+	mux, err := Create(TestDB{}, Profile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := mux.Entities["profile"]
+	if meta == nil {
+		t.Fatal("entity with promoted ID not registered")
+	}
+
+	creationFields := meta.FieldClassifications[CreationFieldsToken]
+	if len(creationFields) != 2 || !reflect.DeepEqual(creationFields[0].Index, []int{0, 1}) {
+		t.Errorf("promoted field not classified with index path")
+	}
+}
+
+func TestEMuxValidateBatch(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := mux.ValidateBatch("validated-user", []map[string]interface{}{
+		{"name": DummyValidatedUser.Name, "email": DummyValidatedUser.Email},
+		{"name": DummyInvalidUser.Name, "email": DummyInvalidUser.Email},
+		{"name": 5},
+	})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+
+	if results[0].Entity != DummyValidatedUser || len(results[0].Errors) != 0 {
+		t.Errorf("valid row rejected: %v", results[0].Errors)
+	}
+
+	var failure *entityErrors.ValidationFailure
+	if results[1].Index != 1 || len(results[1].Errors) != 1 ||
+		!errors.As(results[1].Errors[0], &failure) || failure.Field != "email" {
+		t.Errorf("invalid row not reported by field: %v", results[1].Errors)
+	}
+
+	if results[2].Entity != nil || len(results[2].Errors) != 1 {
+		t.Errorf("malformed row accepted")
+	}
+}
+
+func TestEMuxValidateBatchEmbedded(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{}, ValidatedTeam{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := mux.ValidateBatch("validated-team", []map[string]interface{}{
+		{
+			"lead":  map[string]interface{}{"name": "lead", "email": "not-an-email"},
+			"email": "team@host.com",
+		},
+	})
+
+	// the valid email following the invalid lead is not reported
+	var failure *entityErrors.ValidationFailure
+	if len(results[0].Errors) != 1 || !errors.As(results[0].Errors[0], &failure) || failure.Field != "email" {
+		t.Errorf("expected only the lead's email to be reported, got %v", results[0].Errors)
+	}
+	if results[0].Entity != nil {
+		t.Errorf("invalid item accepted")
+	}
+}
+
+func TestEMuxFieldsFor(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{}, AxisUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := mux.FieldsFor("user", CreationFieldsToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, []string{"name", "email"}) {
+		t.Errorf("unexpected creation fields: %v", fields)
+	}
+
+	if fields, _ := mux.FieldsFor("user", AxisFieldToken); len(fields) != 0 {
+		t.Errorf("unexpected axis fields: %v", fields)
+	}
+	if fields, _ := mux.FieldsFor("axis-user", AxisFieldToken); !reflect.DeepEqual(fields, []string{"email"}) {
+		t.Errorf("unexpected axis fields: %v", fields)
+	}
+
+	if _, err := mux.FieldsFor("account", CreationFieldsToken); err != entityErrors.InvalidEntityID {
+		t.Errorf("expected InvalidEntityID, got %v", err)
+	}
+	if _, err := mux.FieldsFor("user", EntityIDToken); err == nil {
+		t.Errorf("expected error for token outside HandleTokens")
+	}
+}
+
+func TestResolveReference(t *testing.T) {
+	mux, err := Create(TestDB{}, Ticket{}, TicketHolder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticketID := primitive.NewObjectID()
+	lookup := func(meta *metaEntity, filter bson.M) (primitive.ObjectID, error) {
+		if meta.EntityID != "ticket" {
+			t.Errorf("reference resolved against '%s'", meta.EntityID)
+		}
+		if filter["code"] != "T-1" {
+			return primitive.NilObjectID, entityErrors.UnresolvedReference(meta.EntityID)
+		}
+		return ticketID, nil
+	}
+
+	cf := mux.meta("ticket-holder").FieldClassifications[CreationFieldsToken][1]
+	id, err := resolveReference(cf, map[string]interface{}{"code": "T-1"}, lookup)
+	if err != nil || id != ticketID {
+		t.Errorf("reference not resolved: %v", err)
+	}
+
+	if _, err := resolveReference(cf, map[string]interface{}{"code": "T-2"}, lookup); err == nil {
+		t.Errorf("unmatched reference resolved")
+	}
+	if _, err := resolveReference(cf, map[string]interface{}{}, lookup); err != entityErrors.UndefinedAxis {
+		t.Errorf("expected UndefinedAxis, got %v", err)
+	}
+}
+
+func TestCreateEntityReferenceID(t *testing.T) {
+	mux, err := Create(TestDB{}, Ticket{}, TicketHolder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ticketID := primitive.NewObjectID()
+	holder, err := mux.createEntity(mux.meta("ticket-holder"), map[string]interface{}{
+		"name":   "holder",
+		"ticket": ticketID.Hex(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder.Interface().(TicketHolder).Ticket != ticketID {
+		t.Errorf("reference ID not written")
+	}
+}
+
+func TestStreamEntityReference(t *testing.T) {
+	mux, err := Create(TestDB{}, Ticket{}, TicketHolder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := mux.meta("ticket-holder")
+
+	ticketID := primitive.NewObjectID()
+	holder, err := mux.streamEntity(meta, json.NewDecoder(strings.NewReader(
+		`{"name": "holder", "ticket": "`+ticketID.Hex()+`"}`)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder.Interface().(TicketHolder).Ticket != ticketID {
+		t.Errorf("reference ID not written")
+	}
+
+	// axis references are resolved as by the default decoder
+	mux.E("ticket").PStorage = nil
+	payload := `{"name": "holder", "ticket": {"code": "T-1"}}`
+	_, streamErr := mux.streamEntity(meta, json.NewDecoder(strings.NewReader(payload)), false)
+
+	var data map[string]interface{}
+	_ = json.Unmarshal([]byte(payload), &data)
+	_, err = mux.createEntity(meta, data)
+	if err == nil || streamErr == nil || streamErr.Error() != err.Error() {
+		t.Errorf("expected '%v' when streaming, got '%v'", err, streamErr)
+	}
+}
+
+func TestCreateBadReference(t *testing.T) {
+	if _, err := Create(TestDB{}, Ticket{}, EBadReference{}); err == nil {
+		t.Errorf("expected reference to non-ObjectID field to fail")
+	}
+}
+
+func TestCreateNonStructDefinition(t *testing.T) {
+	_, err := Create(TestDB{}, "not-a-struct")
+	if err != entityErrors.IncompatibleEntityType {
+		t.Fail()
+	}
+}
+
 func TestCreateNoCollection(t *testing.T) {
 	mux, err := Create(TestDB{}, ENoDBColl{})
 	if err != nil {
@@ -86,3 +668,204 @@ func TestCreateNoCollection(t *testing.T) {
 		t.Fail()
 	}
 }
+
+// malformed validation tag
+type EBadValidation struct {
+	F1 string `json:"f1" _id_:"bad-validation" _va_:"rep/unknown/"`
+}
+
+func TestCreateBadValidationTag(t *testing.T) {
+	_, err := Create(TestDB{}, EBadValidation{})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestCreateCompilesValidators(t *testing.T) {
+	mux, err := Create(TestDB{}, ValidatedUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ety := mux.E("validated-user")
+	if err := ety.Validate(DummyValidatedUser); err != nil {
+		t.Fatal(err)
+	}
+	if err := ety.Validate(DummyInvalidUser); err == nil {
+		t.Fail()
+	}
+}
+
+func TestEMux_AxisFilter(t *testing.T) {
+	mux, err := Create(TestDB{}, AxisUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := mux.AxisFilter("axis-user", map[string]interface{}{
+		"name":  DummyUserData.Name,
+		"email": DummyUserData.Email,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(filter, bson.M{"email": DummyUserData.Email}) {
+		t.Fail()
+	}
+}
+
+func TestEMux_AxisFilterUndefined(t *testing.T) {
+	mux, err := Create(TestDB{}, AxisUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mux.AxisFilter("axis-user", map[string]interface{}{"name": DummyUserData.Name})
+	if err != entityErrors.UndefinedAxis {
+		t.Fail()
+	}
+
+	if _, err = mux.AxisFilter("<unknown>", nil); err != entityErrors.InvalidEntityID {
+		t.Fail()
+	}
+}
+
+func TestCreateBadDefault(t *testing.T) {
+	if _, err := Create(TestDB{}, EBadDefault{}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCreateDeterministicOrder(t *testing.T) {
+	var previous []string
+	for i := 0; i < 5; i++ {
+		mux, err := Create(TestDB{}, Task{}, TaskDetails{}, Schedule{}, UserEmbed{}, Ordered{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields, err := mux.FieldsFor("ordered", CreationFieldsToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fields, []string{"zeta", "alpha", "mid", "beta"}) {
+			t.Errorf("creation fields not in declaration order: %v", fields)
+		}
+
+		models, err := mux.E("ordered").IndexModels()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := models[0].Keys.(bson.D)
+		if keys[0].Key != "zeta" || keys[1].Key != "alpha" || keys[2].Key != "mid" {
+			t.Errorf("index keys not in declaration order: %v", keys)
+		}
+
+		_, err = mux.decodeEntity(mux.meta("ordered"), strings.NewReader("{}"),
+			newCreationConfig([]CreationOption{WithStrictFields()}))
+		messages := []string{err.Error(), mux.Deregister("task-details", false).Error()}
+		if previous != nil && !reflect.DeepEqual(messages, previous) {
+			t.Errorf("error output differs between Create calls: %v, %v", previous, messages)
+		}
+		previous = messages
+	}
+
+	if previous[1] != entityErrors.EntityEmbedded("task-details", "schedule").Error() {
+		t.Errorf("expected first embedding entity by EntityID, got %s", previous[1])
+	}
+}
+
+func TestCreatePointerDefinition(t *testing.T) {
+	byValue, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &TestUser{}
+	for _, definition := range []interface{}{user, &user, Definition{Type: user}} {
+		byPointer, err := Create(TestDB{}, definition)
+		if err != nil {
+			t.Fatalf("%T: %v", definition, err)
+		}
+
+		if !reflect.DeepEqual(byPointer.TypeMap, byValue.TypeMap) {
+			t.Errorf("%T: expected TypeMap %v, got %v", definition, byValue.TypeMap, byPointer.TypeMap)
+		}
+		if !reflect.DeepEqual(byPointer.meta("user").FieldClassifications, byValue.meta("user").FieldClassifications) {
+			t.Errorf("%T: field classifications differ", definition)
+		}
+		if err := byPointer.Validate(DummyUserData); err != nil {
+			t.Errorf("%T: %v", definition, err)
+		}
+	}
+
+	var nilUser *TestUser
+	if _, err := Create(TestDB{}, nilUser); err != nil {
+		t.Errorf("nil pointer definition rejected: %v", err)
+	}
+	if _, err := Create(TestDB{}, nil); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected IncompatibleEntityType for nil definition, got %v", err)
+	}
+}
+
+func TestCreateIllegalEntityID(t *testing.T) {
+	for _, definition := range []interface{}{ESystemID{}, ESystemIDNoColl{}, EDollarID{}} {
+		_, err := Create(TestDB{}, definition)
+		if err == nil || !strings.Contains(err.Error(), "not a valid collection name") {
+			t.Errorf("%T: expected illegal EntityID error, got %v", definition, err)
+		}
+	}
+
+	if _, err := Create(TestDB{}, ESystemLikeID{}); err != nil {
+		t.Errorf("legal EntityID rejected: %v", err)
+	}
+
+	mux, err := Create(TestDB{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ety, _ := entity.NewEntity(entity.TypeOf(TestUser{}), nil)
+	if err := mux.RegisterEntity("system.users", ety); err == nil {
+		t.Errorf("expected RegisterEntity to reject reserved EntityID")
+	}
+}
+
+func TestClassifyFieldsExactTokens(t *testing.T) {
+	classes := classifyFields(reflect.TypeOf(Note{}))
+
+	membership := map[rune]bool{
+		CreationFieldsToken:  true,
+		EditFieldsToken:      true,
+		RetrievalFieldsToken: false,
+		AxisFieldToken:       false,
+	}
+	for tok, member := range membership {
+		found := false
+		for _, cf := range classes[tok] {
+			found = found || cf.Name == "Body"
+		}
+		if found != member {
+			t.Errorf("expected membership of '%c' to be %v", tok, member)
+		}
+	}
+	if _, ok := classes['d']; ok {
+		t.Errorf("unknown token classified")
+	}
+}
+
+func TestCreateUnknownHandleTokens(t *testing.T) {
+	var warnings []error
+	_, err := Create(TestDB{}, Note{}, TestUser{}, WithWarningHandler(func(err error) {
+		warnings = append(warnings, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only Note's 'd' token is unknown
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "Note") ||
+		!strings.Contains(warnings[0].Error(), "'d'") {
+		t.Errorf("expected unknown token warning, got %v", warnings)
+	}
+}
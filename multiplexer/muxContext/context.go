@@ -30,6 +30,11 @@ type EMuxContext struct {
 		payloads is internally used to map keys to payloads.
 	*/
 	payloads map[string]interface{}
+	/*
+		err is used to store an error which occurred while
+		pre-processing the request.
+	*/
+	err error
 	/*
 		mutex is used to internally ensure that concurrent
 		read/write operations do not compromise payload data.
@@ -49,6 +54,52 @@ func Create() *EMuxContext {
 	}
 }
 
+/*
+pool stores EMuxContexts for reuse. See Acquire and Release.
+*/
+var pool = sync.Pool{
+	New: func() interface{} {
+		return Create()
+	},
+}
+
+/*
+Acquire returns an empty EMuxContext from a package-level pool.
+It should be returned to the pool using Release once it is no
+longer used.
+*/
+func Acquire() *EMuxContext {
+	return pool.Get().(*EMuxContext)
+}
+
+/*
+Release resets the given EMuxContext and returns it to the pool
+used by Acquire.
+
+The EMuxContext must not be used after it has been released. In
+particular, it must not be released while a handler of the request
+it is embedded in (which may run asynchronously) still holds it,
+since it may be handed to another request at any time.
+*/
+func Release(emc *EMuxContext) {
+	emc.Reset()
+	pool.Put(emc)
+}
+
+/*
+Reset removes all payloads and the error stored in the
+EMuxContext *emc.
+*/
+func (emc *EMuxContext) Reset() {
+	emc.mutex.Lock()
+	defer emc.mutex.Unlock()
+
+	for key := range emc.payloads {
+		delete(emc.payloads, key)
+	}
+	emc.err = nil
+}
+
 /*
 Set stores the given payload in the EMuxContext *emc
 under the given keyStr.
@@ -72,6 +123,29 @@ func (emc *EMuxContext) Retrieve(key string) interface{} {
 	return emc.payloads[key]
 }
 
+/*
+SetError stores the given error in the EMuxContext *emc.
+This is used to make pre-processing failures available for
+inspection by downstream handlers.
+*/
+func (emc *EMuxContext) SetError(err error) {
+	emc.mutex.Lock()
+	defer emc.mutex.Unlock()
+
+	emc.err = err
+}
+
+/*
+Error returns the error stored in the EMuxContext *emc, or
+nil if no error has been stored.
+*/
+func (emc *EMuxContext) Error() error {
+	emc.mutex.Lock()
+	defer emc.mutex.Unlock()
+
+	return emc.err
+}
+
 /*
 EmbedCtx returns the given request, with its context modified
 to include the given emc.
@@ -106,3 +106,36 @@ func TestEMuxContext_EmbedCtx(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestEMuxContext_Error(t *testing.T) {
+	ctx := Create()
+	if ctx.Error() != nil {
+		t.Fail()
+	}
+
+	ctx.SetError(entityErrors.MuxCtxCorrupt)
+	if ctx.Error() != entityErrors.MuxCtxCorrupt {
+		t.Fail()
+	}
+}
+
+func TestEMuxContext_Reset(t *testing.T) {
+	emc := Create()
+	_ = emc.Set(keyStr, valStr)
+	emc.SetError(entityErrors.MuxCtxNotFound)
+
+	emc.Reset()
+	if emc.Retrieve(keyStr) != nil || emc.Error() != nil {
+		t.Fail()
+	}
+}
+
+func TestAcquireRelease(t *testing.T) {
+	emc := Acquire()
+	_ = emc.Set(keyStr, valStr)
+	Release(emc)
+
+	if Acquire().Retrieve(keyStr) != nil {
+		t.Errorf("acquired context has leftover payloads")
+	}
+}
@@ -5,6 +5,8 @@ database behaviour required by the multiplexer.
 package muxHandle
 
 import (
+	"context"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -24,3 +26,22 @@ type DBHandler interface {
 	*/
 	Collection(name string, opts ...*options.CollectionOptions) *mongo.Collection
 }
+
+/*
+Disconnector is an optional interface which a DBHandler
+can implement when the multiplexer owns the underlying
+connection. The multiplexer calls Disconnect when it is
+closed.
+*/
+type Disconnector interface {
+	Disconnect(ctx context.Context) error
+}
+
+/*
+Pinger is an optional interface which a DBHandler can
+implement to report whether the underlying database is
+reachable. It is used by the multiplexer's health check.
+*/
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
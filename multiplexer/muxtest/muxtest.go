@@ -0,0 +1,30 @@
+/*
+Package muxtest provides utilities for testing handlers which
+expect requests pre-processed by the multiplexer's middleware.
+*/
+package muxtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/navaz-alani/entity/multiplexer/muxContext"
+)
+
+/*
+RequestWithEntity returns a new http.Request with the given method
+and url, whose muxContext.EMuxContext stores the given entity under
+the given entityID, as if the request had been pre-processed by the
+multiplexer's creation middleware. For example:
+
+	req := muxtest.RequestWithEntity("POST", "/users", "user", User{Name: "Jane"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+*/
+func RequestWithEntity(method, url string, entityID string, entity interface{}) *http.Request {
+	req := httptest.NewRequest(method, url, nil)
+
+	muxCtx := muxContext.Create()
+	_ = muxCtx.Set(entityID, entity)
+	return muxCtx.EmbedCtx(req, context.Background())
+}
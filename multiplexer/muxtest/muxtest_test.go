@@ -0,0 +1,29 @@
+package muxtest_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/navaz-alani/entity/multiplexer/muxContext"
+	"github.com/navaz-alani/entity/multiplexer/muxtest"
+)
+
+type User struct {
+	Name string `json:"name"`
+}
+
+func TestRequestWithEntity(t *testing.T) {
+	req := muxtest.RequestWithEntity(http.MethodPost, "/users", "user", User{Name: "Jane"})
+	if req.Method != http.MethodPost || req.URL.Path != "/users" {
+		t.Errorf("unexpected request: %s %s", req.Method, req.URL)
+	}
+
+	muxCtx, err := muxContext.IsolateCtx(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user, ok := muxCtx.Retrieve("user").(User); !ok || user.Name != "Jane" {
+		t.Errorf("entity not embedded: %v", muxCtx.Retrieve("user"))
+	}
+}
@@ -0,0 +1,92 @@
+package multiplexer
+
+import (
+	"strings"
+	"unicode"
+)
+
+/*
+MuxOption is a function used to configure an EMux. MuxOptions are
+given to Create along with the definitions.
+*/
+type MuxOption func(*EMux)
+
+/*
+NamingStrategy is a function which derives the name of an Entity's
+database collection from its EntityID.
+*/
+type NamingStrategy func(entityID string) string
+
+/*
+WithNamingStrategy makes the EMux name the collections of its Entities
+using the given NamingStrategy, rather than by their EntityIDs. The
+EntityIDs themselves are unchanged: Entities are still looked up and
+linked by them. For example, to store the "user" Entity in a "users"
+collection:
+
+	eMux, err := multiplexer.Create(dbPtr,
+		multiplexer.WithNamingStrategy(multiplexer.Pluralize), User{})
+
+The strategy is not applied to templated EntityIDs (see CollectionFor),
+which spell out their collection names.
+*/
+func WithNamingStrategy(strategy NamingStrategy) MuxOption {
+	return func(em *EMux) {
+		em.naming = strategy
+	}
+}
+
+/*
+collectionName returns the name of the collection for the given
+EntityID, using the EMux's NamingStrategy if one is set.
+*/
+func (em *EMux) collectionName(entityID string) string {
+	if em.naming == nil {
+		return entityID
+	}
+	return em.naming(entityID)
+}
+
+/*
+SnakeCase is a NamingStrategy which converts an EntityID to snake case,
+for example "UserProfile" and "user-profile" to "user_profile".
+*/
+func SnakeCase(entityID string) string {
+	runes := []rune(entityID)
+	var b strings.Builder
+
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			b.WriteRune('_')
+		case unicode.IsUpper(r):
+			if i > 0 && runes[i-1] != '_' && runes[i-1] != '-' && runes[i-1] != ' ' &&
+				(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+/*
+Pluralize is a NamingStrategy which (naively) pluralizes an EntityID,
+for example "user" to "users", "box" to "boxes" and "category" to
+"categories". Irregular plurals are not handled.
+*/
+func Pluralize(entityID string) string {
+	lower := strings.ToLower(entityID)
+	switch {
+	case entityID == "":
+		return entityID
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return entityID + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return entityID[:len(entityID)-1] + "ies"
+	}
+	return entityID + "s"
+}
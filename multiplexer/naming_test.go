@@ -0,0 +1,79 @@
+package multiplexer
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// database type returning (unconnected) collections with their names
+type NamedDB struct {
+	db *mongo.Database
+}
+
+func (db NamedDB) Collection(name string, opts ...*options.CollectionOptions) *mongo.Collection {
+	return db.db.Collection(name, opts...)
+}
+
+func TestCreateWithNamingStrategy(t *testing.T) {
+	db := OptionsDB{opts: make(map[string][]*options.CollectionOptions)}
+	mux, err := Create(db, WithNamingStrategy(Pluralize), TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := db.opts["users"]; !ok {
+		t.Errorf("collection 'users' not created: %v", db.opts)
+	}
+	if _, ok := db.opts["user"]; ok {
+		t.Errorf("collection created under the EntityID")
+	}
+	if mux.E("user") == nil || mux.Collection("user") == nil {
+		t.Errorf("entity not addressable by its EntityID")
+	}
+}
+
+func TestNamingStrategyReferences(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux, err := Create(NamedDB{client.Database("test")}, WithNamingStrategy(Pluralize),
+		Ticket{}, TicketHolder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// references are resolved by EntityID to the named collection
+	ref := mux.E("ticket-holder").ResolveReference("ticket")
+	if ref == nil || ref.PStorage.Name() != "tickets" {
+		t.Errorf("reference not resolved to the 'tickets' collection: %v", ref)
+	}
+	if mux.E("ticket-holder").ResolveReference("unknown") != nil {
+		t.Errorf("unknown reference resolved")
+	}
+}
+
+func TestNamingStrategies(t *testing.T) {
+	namingTests := []struct {
+		Strategy NamingStrategy
+		ID       string
+		Expected string
+	}{
+		{SnakeCase, "UserProfile", "user_profile"},
+		{SnakeCase, "user-profile", "user_profile"},
+		{SnakeCase, "HTTPRequest", "http_request"},
+		{SnakeCase, "user", "user"},
+		{Pluralize, "user", "users"},
+		{Pluralize, "box", "boxes"},
+		{Pluralize, "category", "categories"},
+		{Pluralize, "day", "days"},
+	}
+
+	for _, nt := range namingTests {
+		if name := nt.Strategy(nt.ID); name != nt.Expected {
+			t.Errorf("expected '%s' for '%s', got '%s'", nt.Expected, nt.ID, name)
+		}
+	}
+}
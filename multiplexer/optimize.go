@@ -0,0 +1,48 @@
+package multiplexer
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+OptimizeAll creates the indexes (see entity.Entity.Optimize) of every
+Entity which has a collection, issuing a single CreateMany request per
+collection. This can be used, for example, to build indexes at startup
+for Entities registered without them (see RegisterEntity).
+
+Entities continue to be optimized if others fail; the failures are
+returned as an entityErrors.IndexCreationFailed error naming every
+failing Entity.
+*/
+func (em *EMux) OptimizeAll(ctx context.Context) error {
+	opts := options.CreateIndexes().SetMaxTime(3 * time.Second)
+	return em.optimizeAll(func(meta *metaEntity, models []mongo.IndexModel) error {
+		_, err := meta.Entity.PStorage.Indexes().CreateMany(ctx, models, opts)
+		return err
+	})
+}
+
+/*
+optimizeAll gathers the IndexModels of each Entity which has a collection
+and uses the given createMany function to create them.
+*/
+func (em *EMux) optimizeAll(createMany func(meta *metaEntity, models []mongo.IndexModel) error) error {
+	failures := em.eachCollection(func(meta *metaEntity) error {
+		models, err := meta.Entity.IndexModels()
+		if err != nil || len(models) == 0 {
+			return err
+		}
+		return createMany(meta, models)
+	})
+
+	if len(failures) != 0 {
+		return entityErrors.IndexCreationFailed(failures)
+	}
+	return nil
+}
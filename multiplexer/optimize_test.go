@@ -0,0 +1,63 @@
+package multiplexer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type IndexedAuthor struct {
+	Email string `json:"email" _id_:"!indexed-author" _ax_:"true" _ix_:"true" _hd_:"c"`
+}
+
+type IndexedBook struct {
+	ISBN  string `json:"isbn" _id_:"!indexed-book" _ax_:"true" _ix_:"1" _hd_:"c"`
+	Title string `json:"title" _ax_:"nonunique" _ix_:"text" _hd_:"c"`
+}
+
+func TestEMuxOptimizeAll(t *testing.T) {
+	mux, err := Create(TestDB{}, IndexedAuthor{}, IndexedBook{}, ENoDBColl{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.E("indexed-author").PStorage = &mongo.Collection{}
+	mux.E("indexed-book").PStorage = &mongo.Collection{}
+
+	calls := make(map[string]int)
+	err = mux.optimizeAll(func(meta *metaEntity, models []mongo.IndexModel) error {
+		calls[meta.EntityID]++
+		if len(models) == 0 {
+			t.Errorf("no index models for '%s'", meta.EntityID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 2 || calls["indexed-author"] != 1 || calls["indexed-book"] != 1 {
+		t.Errorf("expected one CreateMany per collection, got %v", calls)
+	}
+}
+
+func TestEMuxOptimizeAllFail(t *testing.T) {
+	mux, err := Create(TestDB{}, IndexedAuthor{}, IndexedBook{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.E("indexed-author").PStorage = &mongo.Collection{}
+	mux.E("indexed-book").PStorage = &mongo.Collection{}
+
+	err = mux.optimizeAll(func(meta *metaEntity, models []mongo.IndexModel) error {
+		if meta.EntityID == "indexed-book" {
+			return errors.New("index build interrupted")
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "indexed-book: index build interrupted") ||
+		strings.Contains(err.Error(), "indexed-author") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package multiplexer
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+ETagFunc is a function which computes the entity tag (ETag)
+of an Entity instance for conditional requests. The returned
+value should be a quoted string, as required by the ETag
+header.
+*/
+type ETagFunc func(entity interface{}) (string, error)
+
+/*
+DefaultETag is an ETagFunc which returns a strong ETag derived
+from a SHA-1 hash of the JSON encoding of the given entity.
+Since all (JSON encoded) fields, including any version fields,
+contribute to the hash, any change to the entity changes its ETag.
+*/
+func DefaultETag(entity interface{}) (string, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%x"`, sha1.Sum(data)), nil
+}
+
+/*
+TenantKey is the placeholder, "{tenant}", of a templated EntityID
+which is substituted with the tenant resolved for a request (see
+WithTenantResolver).
+*/
+const TenantKey = "tenant"
+
+/*
+TenantResolver is a function which determines the tenant that
+a request is made for, from a JWT claim or subdomain for example.
+*/
+type TenantResolver func(r *http.Request) string
+
+/*
+readConfig stores the configuration of a read handler.
+*/
+type readConfig struct {
+	tenantResolver TenantResolver
+}
+
+/*
+ReadOption is a function used to configure the handler returned
+by ReadHandler.
+*/
+type ReadOption func(*readConfig)
+
+/*
+WithTenantResolver sets the TenantResolver used to select the
+collection of an Entity whose EntityID is a collection-name
+template, such as "users_{tenant}". For each request, the
+resolved tenant is substituted for the TenantKey placeholder
+(see CollectionFor).
+*/
+func WithTenantResolver(resolver TenantResolver) ReadOption {
+	return func(cfg *readConfig) {
+		cfg.tenantResolver = resolver
+	}
+}
+
+/*
+storage returns the collection which the handler for the given
+metaEntity should use for the given request. The resolved tenant
+is validated as described by CollectionFor; an error is returned
+if it is empty, does not yield a valid collection name or is
+rejected by the EMux's CollectionGuard.
+*/
+func (cfg *readConfig) storage(em *EMux, meta *metaEntity, r *http.Request) (*mongo.Collection, error) {
+	if cfg.tenantResolver == nil {
+		return meta.Entity.PStorage, nil
+	}
+	return em.collectionFor(meta.EntityID, map[string]string{TenantKey: cfg.tenantResolver(r)})
+}
+
+/*
+ReadHandler returns an http.HandlerFunc which retrieves an instance of
+the Entity corresponding to the given entityID and responds with its
+JSON encoding.
+
+The instance is found using the AxisFilter built from the request's
+URL query parameters, where each axis field's value is given under its
+RequestID, for example "?email=jane.doe@example.com".
+
+The handler supports conditional requests: the ETag of the instance is
+computed using the given ETagFunc (DefaultETag if nil) and sent in the
+ETag header. If the request's If-None-Match header matches the ETag, a
+"304 Not Modified" response without a body is sent instead.
+
+For Entities with a templated EntityID, a TenantResolver must be given
+using WithTenantResolver. Requests whose tenant is empty, does not yield a
+valid collection name or is rejected by the EMux's CollectionGuard (see
+WithCollectionGuard) receive a "400 Bad Request" response.
+
+Error responses are written using the EMux's ErrorResponder.
+*/
+func (em *EMux) ReadHandler(entityID string, etag ETagFunc, opts ...ReadOption) (http.HandlerFunc, error) {
+	cfg := &readConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	meta := em.meta(entityID)
+	if meta == nil || meta.EntityID == "" {
+		return nil, entityErrors.IncompleteEntityMetadata
+	} else if meta.Entity.PStorage == nil && cfg.tenantResolver == nil {
+		return nil, entityErrors.DBUninitialized
+	}
+
+	if etag == nil {
+		etag = DefaultETag
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		metrics := em.recorder()
+		timer := metrics.StartTimer(meta.EntityID, OperationRead)
+		defer timer.ObserveDuration()
+
+		payload := make(map[string]interface{})
+		query := r.URL.Query()
+		for _, af := range meta.FieldClassifications[AxisFieldToken] {
+			if raw := query.Get(af.RequestID); raw != "" {
+				value, err := eField.ParseValue(raw, af.Type)
+				if err != nil {
+					metrics.IncRead(meta.EntityID, true)
+					em.respondError(w, http.StatusBadRequest, err)
+					return
+				}
+				payload[af.RequestID] = value
+			}
+		}
+
+		filter, err := em.AxisFilter(entityID, payload)
+		if err != nil {
+			metrics.IncRead(meta.EntityID, true)
+			em.respondError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		storage, err := cfg.storage(em, meta, r)
+		if err != nil {
+			metrics.IncRead(meta.EntityID, true)
+			em.respondError(w, http.StatusBadRequest, err)
+			return
+		} else if storage == nil {
+			metrics.IncRead(meta.EntityID, true)
+			em.respondError(w, http.StatusBadRequest, entityErrors.DBUninitialized)
+			return
+		}
+
+		result := reflect.New(meta.Entity.SchemaDefinition)
+		err = storage.FindOne(r.Context(), filter).Decode(result.Interface())
+		if err == mongo.ErrNoDocuments {
+			metrics.IncRead(meta.EntityID, true)
+			em.respondError(w, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			metrics.IncRead(meta.EntityID, true)
+			em.respondError(w, http.StatusInternalServerError, entityErrors.DBDecodeFail)
+			return
+		}
+
+		read, err := meta.Entity.Decrypt(result.Elem().Interface())
+		if err != nil {
+			metrics.IncRead(meta.EntityID, true)
+			em.respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		metrics.IncRead(meta.EntityID, false)
+		em.writeConditional(w, r, read, etag)
+	}, nil
+}
+
+/*
+writeConditional writes the JSON encoding of the given entity to w,
+along with its ETag. If the ETag matches the If-None-Match header of
+the request, only a "304 Not Modified" status is written.
+*/
+func (em *EMux) writeConditional(w http.ResponseWriter, r *http.Request, entity interface{}, etag ETagFunc) {
+	tag, err := etag(entity)
+	if err != nil {
+		em.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("ETag", tag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(entity)
+}
+
+/*
+etagMatches reports whether the given If-None-Match header value
+matches the given ETag, using weak comparison.
+*/
+func etagMatches(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
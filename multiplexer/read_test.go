@@ -0,0 +1,120 @@
+package multiplexer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func conditionalReadTestHelper(t *testing.T, ifNoneMatch string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/users?email=dummy@user.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	w := httptest.NewRecorder()
+	(&EMux{}).writeConditional(w, req, DummyUserData, DefaultETag)
+	return w
+}
+
+func TestWriteConditionalMatch(t *testing.T) {
+	tag, err := DefaultETag(DummyUserData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := conditionalReadTestHelper(t, tag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fail()
+	}
+}
+
+func TestWriteConditionalNoMatch(t *testing.T) {
+	w := conditionalReadTestHelper(t, `"stale"`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), DummyUserData.Email) {
+		t.Fail()
+	}
+
+	if tag, _ := DefaultETag(DummyUserData); w.Header().Get("ETag") != tag {
+		t.Fail()
+	}
+}
+
+func TestReadHandlerNoCollection(t *testing.T) {
+	mux, err := Create(TestDB{}, ENoDBColl{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mux.ReadHandler("no-coll", nil); err == nil {
+		t.Fail()
+	}
+}
+
+func TestReadTenantStorage(t *testing.T) {
+	mux, err := Create(TestDB{}, TenantUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mux.ReadHandler("users_{tenant}", nil); err == nil {
+		t.Errorf("templated entity read without tenant resolver")
+	}
+
+	cfg := &readConfig{}
+	WithTenantResolver(func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	})(cfg)
+
+	acmeReq := httptest.NewRequest("GET", "/users", nil)
+	acmeReq.Header.Set("X-Tenant", "acme")
+	globexReq := httptest.NewRequest("GET", "/users", nil)
+	globexReq.Header.Set("X-Tenant", "globex")
+
+	meta := mux.Entities["users_{tenant}"]
+	acme, _ := cfg.storage(mux, meta, acmeReq)
+	globex, _ := cfg.storage(mux, meta, globexReq)
+	if acme == nil || globex == nil || acme == globex {
+		t.Errorf("expected distinct tenant collections")
+	}
+	if acme != mux.CollectionFor("users_{tenant}", map[string]string{TenantKey: "acme"}) {
+		t.Errorf("tenant collection not reused")
+	}
+}
+
+func TestReadHandlerRejectedTenant(t *testing.T) {
+	db := OptionsDB{opts: make(map[string][]*options.CollectionOptions)}
+	mux, err := Create(db, TenantUser{}, WithCollectionGuard(func(entityID string, params map[string]string) bool {
+		return params[TenantKey] != "initech"
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := mux.ReadHandler("users_{tenant}", nil, WithTenantResolver(func(r *http.Request) string {
+		return r.Header.Get("X-Tenant")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenant := range []string{"", "a$b", "initech"} {
+		req := httptest.NewRequest("GET", "/users?email=dummy@user.com", nil)
+		req.Header.Set("X-Tenant", tenant)
+
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not a valid collection name") {
+			t.Errorf("tenant %q: expected rejection, got %d %s", tenant, w.Code, w.Body.String())
+		}
+	}
+	if len(db.opts) != 0 {
+		t.Errorf("collections created for rejected tenants: %v", db.opts)
+	}
+}
@@ -0,0 +1,70 @@
+package multiplexer
+
+import (
+	"errors"
+	"net/http"
+)
+
+/*
+HandlerError is an error produced by the EMux generated middleware
+or handlers while responding to a request. It carries the HTTP status
+code which the response should have.
+*/
+type HandlerError struct {
+	// Status is the HTTP status code for the response.
+	Status int
+	// Err is the underlying error.
+	Err error
+}
+
+func (he *HandlerError) Error() string {
+	return he.Err.Error()
+}
+
+func (he *HandlerError) Unwrap() error {
+	return he.Err
+}
+
+/*
+ErrorResponder is a function used to write an error response for a
+request which could not be handled by an EMux generated middleware or
+handler. The given error is a *HandlerError (see errors.As).
+*/
+type ErrorResponder func(w http.ResponseWriter, err error)
+
+/*
+DefaultErrorResponder is the ErrorResponder used when none has been
+set. It writes the error message as plain text, using the status code
+of the *HandlerError, or "500 Internal Server Error" for other errors.
+*/
+func DefaultErrorResponder(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	var he *HandlerError
+	if errors.As(err, &he) {
+		status = he.Status
+	}
+	http.Error(w, err.Error(), status)
+}
+
+/*
+SetErrorResponder sets the ErrorResponder used by the middleware and
+handlers generated by em. This can be used, for example, to write
+structured JSON error bodies. Setting nil restores the
+DefaultErrorResponder.
+*/
+func (em *EMux) SetErrorResponder(responder ErrorResponder) {
+	em.errorResponder = responder
+}
+
+/*
+respondError writes an error response for the given status and error
+using the ErrorResponder of em.
+*/
+func (em *EMux) respondError(w http.ResponseWriter, status int, err error) {
+	responder := em.errorResponder
+	if responder == nil {
+		responder = DefaultErrorResponder
+	}
+	responder(w, &HandlerError{Status: status, Err: err})
+}
@@ -0,0 +1,56 @@
+package multiplexer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEMux_SetErrorResponder(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received error
+	mux.SetErrorResponder(func(w http.ResponseWriter, err error) {
+		received = err
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	})
+
+	hd, err := mux.CreationMiddleware("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/", bytes.NewReader([]byte("{")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	hd(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler called for undecodable payload")
+	}).ServeHTTP(w, req)
+
+	var he *HandlerError
+	if !errors.As(received, &he) || he.Status != http.StatusBadRequest {
+		t.Fatal("responder did not receive decode error")
+	}
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fail()
+	}
+}
+
+func TestDefaultErrorResponder(t *testing.T) {
+	w := httptest.NewRecorder()
+	DefaultErrorResponder(w, &HandlerError{Status: http.StatusBadRequest, Err: errors.New("bad")})
+
+	if w.Code != http.StatusBadRequest || w.Body.String() != "bad\n" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,343 @@
+package multiplexer
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/multiplexer/muxContext"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+QueryOperatorSeparator separates a field's RequestID from a
+query operator in the URL query parameters parsed by the
+retrieval middleware, for example "age__gt=18".
+*/
+const QueryOperatorSeparator = "__"
+
+/*
+queryOperators is the set of query operators which can be used
+as suffixes in URL query parameters, in the order in which they
+are parsed.
+*/
+var queryOperators = []string{"gt", "gte", "lt", "lte", "ne", "in", "nin", "regex"}
+
+/*
+These are the defaults used by the retrieval middleware when
+parsing pagination parameters.
+*/
+const (
+	DefaultPageLimit int64 = 20
+	DefaultMaxLimit  int64 = 100
+)
+
+/*
+PaginationKey is the key under which the retrieval middleware
+stores the parsed Pagination in the request's muxContext.
+*/
+const PaginationKey = "_pagination_"
+
+/*
+Pagination is a normalized representation of the pagination
+and sorting parameters of a retrieval request.
+*/
+type Pagination struct {
+	// Limit is the maximum number of Entities to retrieve.
+	Limit int64
+	// Offset is the number of Entities to skip.
+	Offset int64
+	/*
+		Sort specifies the sort order, mapping the fields'
+		BSON/JSON/field names to 1 (ascending) or -1
+		(descending).
+	*/
+	Sort bson.D
+}
+
+/*
+FindOptions returns options which can be used to apply the
+Pagination p to a find operation on a mongo.Collection.
+*/
+func (p *Pagination) FindOptions() *options.FindOptions {
+	opts := options.Find().SetLimit(p.Limit).SetSkip(p.Offset)
+	if len(p.Sort) > 0 {
+		opts.SetSort(p.Sort)
+	}
+	return opts
+}
+
+/*
+retrievalConfig stores the configuration of a retrieval
+middleware.
+*/
+type retrievalConfig struct {
+	defaultLimit int64
+	maxLimit     int64
+	// rawRegex is set by WithRawRegex.
+	rawRegex bool
+}
+
+/*
+RetrievalOption is a function used to configure the middleware
+returned by RetrievalMiddleware.
+*/
+type RetrievalOption func(*retrievalConfig)
+
+/*
+WithDefaultLimit sets the limit used when a retrieval request
+does not specify one. It defaults to DefaultPageLimit and
+must be positive (see RetrievalMiddleware).
+*/
+func WithDefaultLimit(limit int64) RetrievalOption {
+	return func(cfg *retrievalConfig) {
+		cfg.defaultLimit = limit
+	}
+}
+
+/*
+WithMaxLimit sets the largest limit a retrieval request may
+specify; larger limits are clamped. It defaults to
+DefaultMaxLimit and must be positive, since MongoDB treats
+a limit of 0 as no limit at all.
+*/
+func WithMaxLimit(limit int64) RetrievalOption {
+	return func(cfg *retrievalConfig) {
+		cfg.maxLimit = limit
+	}
+}
+
+/*
+WithRawRegex makes the retrieval middleware use the values of "regex"
+query parameters as regular expressions. By default, they are escaped
+(see spec.ESpec's QuoteMeta) and matched literally, since untrusted
+expressions can be costly to evaluate. Only use this option when the
+clients of the middleware are trusted.
+*/
+func WithRawRegex() RetrievalOption {
+	return func(cfg *retrievalConfig) {
+		cfg.rawRegex = true
+	}
+}
+
+/*
+RetrievalMiddleware returns middleware which can be used to derive
+query filters for an Entity from the URL query parameters of an API
+request.
+
+The retrieval fields (RetrievalFieldsToken) of the Entity corresponding
+to the given entityID can be constrained using their RequestID as the
+query parameter. A query operator can be specified by suffixing the
+RequestID with the QueryOperatorSeparator and the operator, for example
+"?status=active&age__gt=18". The "in" and "nin" operators accept comma
+separated values. Values are parsed into the field's type using
+eField.ParseValue; "regex" values are escaped and matched literally (and
+case-insensitively) as a substring of the field, unless the WithRawRegex
+option is given.
+
+The resulting []spec.ESpec (using the fields' BSON/JSON/field names) is
+stored in the request's muxContext under the EntityID. Query parameters
+which do not correspond to retrieval fields are ignored.
+
+The "limit", "offset", "page" and "sort" query parameters are parsed into
+a Pagination which is stored under the PaginationKey. The limit defaults
+to DefaultPageLimit and is clamped to DefaultMaxLimit; these can be changed
+using the WithDefaultLimit and WithMaxLimit options, whose values must be
+positive: otherwise, an entityErrors.InvalidDataType error is returned.
+An "offset" takes precedence over a (1-based) "page"; a page whose offset
+would overflow is rejected. The "sort" parameter is a comma
+separated list of retrieval field RequestIDs, each optionally prefixed by
+a "-" for descending order, for example "sort=-createdAt,name".
+
+If parsing fails, nothing is stored and the error is recorded in the
+muxContext instead.
+*/
+func (em *EMux) RetrievalMiddleware(entityID string, opts ...RetrievalOption) (func(next http.HandlerFunc) http.HandlerFunc, error) {
+	var meta *metaEntity
+	if m := em.meta(entityID); m == nil || m.EntityID == "" {
+		return nil, entityErrors.IncompleteEntityMetadata
+	} else {
+		meta = m
+	}
+
+	if len(meta.FieldClassifications[RetrievalFieldsToken]) == 0 {
+		return nil, entityErrors.NoClassificationFields
+	}
+
+	cfg := &retrievalConfig{
+		defaultLimit: DefaultPageLimit,
+		maxLimit:     DefaultMaxLimit,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.defaultLimit < 1 || cfg.maxLimit < 1 {
+		return nil, entityErrors.InvalidDataType
+	}
+
+	handle := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			metrics := em.recorder()
+			timer := metrics.StartTimer(meta.EntityID, OperationRetrieve)
+			defer timer.ObserveDuration()
+
+			muxCtx := muxContext.Create()
+			query := r.URL.Query()
+
+			specs, err := querySpecs(meta, query, cfg)
+			if err != nil {
+				muxCtx.SetError(err)
+			} else if pagination, err := queryPagination(meta, query, cfg); err != nil {
+				muxCtx.SetError(err)
+			} else {
+				_ = muxCtx.Set(meta.EntityID, specs)
+				_ = muxCtx.Set(PaginationKey, pagination)
+			}
+			metrics.IncRetrieve(meta.EntityID, muxCtx.Error() != nil)
+
+			reqWithCtx := muxCtx.EmbedCtx(r, context.Background())
+			next.ServeHTTP(w, reqWithCtx)
+		}
+	}
+
+	return handle, nil
+}
+
+/*
+querySpecs parses the given URL query parameters into ESpecs
+for the retrieval fields of the given metaEntity.
+*/
+func querySpecs(meta *metaEntity, query url.Values, cfg *retrievalConfig) ([]spec.ESpec, error) {
+	specs := make([]spec.ESpec, 0)
+
+	for _, rf := range meta.FieldClassifications[RetrievalFieldsToken] {
+		field := meta.Entity.SchemaDefinition.FieldByIndex(rf.Index)
+		storageName := eField.NameByPriority(field, eField.PriorityBsonJson)
+
+		if values, ok := query[rf.RequestID]; ok && len(values) > 0 {
+			target, err := eField.ParseValue(values[0], rf.Type)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec.ESpec{Field: storageName, Target: target})
+		}
+
+		for _, op := range queryOperators {
+			values, ok := query[rf.RequestID+QueryOperatorSeparator+op]
+			if !ok || len(values) == 0 {
+				continue
+			}
+
+			s := spec.ESpec{Field: storageName, QueryOperator: op}
+			switch op {
+			default:
+				parsed, err := eField.ParseValue(values[0], rf.Type)
+				if err != nil {
+					return nil, err
+				}
+				s.Target = parsed
+			case "regex":
+				s.Target = values[0]
+				s.QuoteMeta = !cfg.rawRegex
+			case "in", "nin":
+				items := make([]interface{}, 0)
+				for _, value := range values {
+					for _, item := range strings.Split(value, ",") {
+						parsed, err := eField.ParseValue(item, rf.Type)
+						if err != nil {
+							return nil, err
+						}
+						items = append(items, parsed)
+					}
+				}
+				s.Target = items
+			}
+
+			specs = append(specs, s)
+		}
+	}
+
+	return specs, nil
+}
+
+/*
+queryPagination parses the pagination parameters in the given URL
+query parameters for the given metaEntity.
+*/
+func queryPagination(meta *metaEntity, query url.Values, cfg *retrievalConfig) (*Pagination, error) {
+	pagination := &Pagination{Limit: cfg.defaultLimit, Sort: bson.D{}}
+
+	parse := func(key string, min int64) (int64, bool, error) {
+		raw := query.Get(key)
+		if raw == "" {
+			return 0, false, nil
+		}
+
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return 0, false, entityErrors.Wrap(entityErrors.InvalidDataType, err)
+		} else if value < min {
+			return 0, false, entityErrors.InvalidDataType
+		}
+		return value, true, nil
+	}
+
+	if limit, ok, err := parse("limit", 1); err != nil {
+		return nil, err
+	} else if ok {
+		pagination.Limit = limit
+	}
+	if pagination.Limit > cfg.maxLimit {
+		pagination.Limit = cfg.maxLimit
+	}
+
+	if offset, ok, err := parse("offset", 0); err != nil {
+		return nil, err
+	} else if ok {
+		pagination.Offset = offset
+	} else if page, ok, err := parse("page", 1); err != nil {
+		return nil, err
+	} else if ok {
+		if page-1 > math.MaxInt64/pagination.Limit {
+			return nil, entityErrors.InvalidDataType
+		}
+		pagination.Offset = (page - 1) * pagination.Limit
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		for _, key := range strings.Split(sort, ",") {
+			order := 1
+			if strings.HasPrefix(key, "-") {
+				order = -1
+				key = key[1:]
+			}
+
+			var sortField *condensedField
+			for _, rf := range meta.FieldClassifications[RetrievalFieldsToken] {
+				if rf.RequestID == key {
+					sortField = rf
+					break
+				}
+			}
+			if sortField == nil {
+				return nil, entityErrors.UndefinedPath(key)
+			}
+
+			field := meta.Entity.SchemaDefinition.FieldByIndex(sortField.Index)
+			pagination.Sort = append(pagination.Sort, bson.E{
+				Key:   eField.NameByPriority(field, eField.PriorityBsonJson),
+				Value: order,
+			})
+		}
+	}
+
+	return pagination, nil
+}
@@ -0,0 +1,107 @@
+package multiplexer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+changeStream is the part of a *mongo.ChangeStream used by the
+handlers generated by SSEHandler.
+*/
+type changeStream interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Close(ctx context.Context) error
+}
+
+/*
+sseOperations are the change stream operation types streamed to
+clients by the handlers generated by SSEHandler.
+*/
+var sseOperations = []string{"insert", "update", "replace", "delete"}
+
+/*
+SSEHandler returns an http.HandlerFunc which streams the changes to the
+Entity corresponding to the given entityID to the client as server-sent
+events. For each insert, update, replace or delete operation, a "data:"
+frame holding the JSON encoding of the entity.ChangeEvent (see
+entity.Entity.DecodeChange) is written and flushed.
+
+The change stream (see entity.Entity.Watch) is opened per request and is
+closed when the client disconnects. Change streams require a replica set;
+if the stream cannot be opened, an error response is written using the
+EMux's ErrorResponder.
+*/
+func (em *EMux) SSEHandler(entityID string) (http.HandlerFunc, error) {
+	meta := em.meta(entityID)
+	if meta == nil || meta.EntityID == "" {
+		return nil, entityErrors.IncompleteEntityMetadata
+	} else if meta.Entity.PStorage == nil {
+		return nil, entityErrors.DBUninitialized
+	}
+
+	return em.sseHandler(meta, func(ctx context.Context, pipeline spec.Pipeline) (changeStream, error) {
+		return meta.Entity.Watch(ctx, pipeline)
+	}), nil
+}
+
+/*
+sseHandler returns the handler described by SSEHandler for the given
+metaEntity, using the given watch function to open change streams.
+*/
+func (em *EMux) sseHandler(meta *metaEntity,
+	watch func(ctx context.Context, pipeline spec.Pipeline) (changeStream, error)) http.HandlerFunc {
+	pipeline := spec.Pipeline{{Field: "operationType", Target: sseOperations, QueryOperator: "in"}}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			em.respondError(w, http.StatusInternalServerError, entityErrors.StreamingUnsupported)
+			return
+		}
+
+		// the stream's context ends when the client disconnects
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		stream, err := watch(ctx, pipeline)
+		if err != nil {
+			em.respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+		defer func() { _ = stream.Close(context.Background()) }()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for stream.Next(ctx) {
+			var raw bson.Raw
+			if err := stream.Decode(&raw); err != nil {
+				continue
+			}
+			event, err := meta.Entity.DecodeChange(raw)
+			if err != nil {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+
+			if _, err := w.Write(append(append([]byte("data: "), data...), '\n', '\n')); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package multiplexer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+fakeStream is a changeStream yielding the given events.
+*/
+type fakeStream struct {
+	events []bson.Raw
+	closed bool
+}
+
+func (fs *fakeStream) Next(ctx context.Context) bool {
+	if len(fs.events) == 0 || ctx.Err() != nil {
+		return false
+	}
+	return true
+}
+
+func (fs *fakeStream) Decode(val interface{}) error {
+	err := bson.Unmarshal(fs.events[0], val)
+	fs.events = fs.events[1:]
+	return err
+}
+
+func (fs *fakeStream) Close(ctx context.Context) error {
+	fs.closed = true
+	return nil
+}
+
+func TestEntityMux_SSEHandler(t *testing.T) {
+	mux, err := Create(TestDB{}, TestUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := bson.Marshal(bson.M{
+		"operationType": "insert",
+		"documentKey":   bson.M{"_id": primitive.NilObjectID},
+		"fullDocument":  bson.M{"name": DummyUserData.Name, "email": DummyUserData.Email},
+	})
+	stream := &fakeStream{events: []bson.Raw{raw}}
+
+	var pipeline spec.Pipeline
+	handler := mux.sseHandler(mux.meta("user"), func(ctx context.Context, p spec.Pipeline) (changeStream, error) {
+		pipeline = p
+		return stream, nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected Content-Type '%s'", ct)
+	}
+	expected := `data: {"operationType":"insert","documentKey":"000000000000000000000000","entity":` +
+		`{"name":"` + DummyUserData.Name + `","email":"` + DummyUserData.Email + `"}}` + "\n\n"
+	if body := rec.Body.String(); !strings.Contains(body, expected) {
+		t.Errorf("expected frame %q, got %q", expected, body)
+	}
+	if !stream.closed {
+		t.Errorf("change stream not closed")
+	}
+	if len(pipeline) != 1 || !reflect.DeepEqual(pipeline[0].Target, sseOperations) {
+		t.Errorf("unexpected pipeline: %+v", pipeline)
+	}
+}
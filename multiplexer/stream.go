@@ -0,0 +1,236 @@
+package multiplexer
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+WithStreaming makes the creation middleware decode payloads as a
+stream of JSON tokens, writing each value into the Entity as it is
+read, instead of first decoding the whole payload into a map. The
+elements of embedded collections are therefore processed one at a
+time, bounding the memory used for large payloads.
+
+Streaming pre-processing otherwise behaves like the default: defaults,
+computed and server-managed fields, references (entity.RefTag), strict
+fields and validation are handled in the same way.
+*/
+func WithStreaming() CreationOption {
+	return func(cfg *creationConfig) {
+		cfg.streaming = true
+	}
+}
+
+/*
+decodeError is an error which occurred while reading the JSON
+payload itself, as opposed to pre-processing its values.
+*/
+type decodeError struct {
+	err error
+}
+
+func (de *decodeError) Error() string {
+	return entityErrors.Wrap(entityErrors.PayloadDecodeFailed, de.err).Error()
+}
+
+func (de *decodeError) Unwrap() error {
+	return de.err
+}
+
+/*
+streamEntity reads a JSON object from the given decoder and returns
+the Entity corresponding to the given metaEntity, pre-processed from
+that object. Errors in reading the payload are returned as a
+*decodeError.
+*/
+func (em *EMux) streamEntity(meta *metaEntity, dec *json.Decoder, strict bool) (reflect.Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return reflect.Value{}, &decodeError{err}
+	} else if tok != json.Delim('{') {
+		return reflect.Value{}, &decodeError{entityErrors.InvalidDataType}
+	}
+	return em.streamObject(meta, dec, strict)
+}
+
+/*
+streamObject pre-processes the members of a JSON object, whose
+opening delimiter has already been read from the decoder, into
+an instance of the Entity corresponding to the given metaEntity.
+*/
+func (em *EMux) streamObject(meta *metaEntity, dec *json.Decoder, strict bool) (reflect.Value, error) {
+	if meta == nil {
+		return reflect.Value{}, entityErrors.InvalidEntityID
+	}
+
+	preProcessedEntity := reflect.New(meta.Entity.SchemaDefinition).Elem()
+	catchAll := catchAllField(meta)
+	extra := make(map[string]interface{})
+	creationFields := make(map[string]*condensedField)
+	for _, cf := range meta.FieldClassifications[CreationFieldsToken] {
+		if !cf.CatchAll {
+			creationFields[cf.RequestID] = cf
+		}
+	}
+	if em.keyFallback {
+		for _, cf := range meta.FieldClassifications[CreationFieldsToken] {
+			for _, id := range cf.FallbackIDs {
+				if creationFields[id] == nil {
+					creationFields[id] = cf
+				}
+			}
+		}
+	}
+
+	written := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return preProcessedEntity, &decodeError{err}
+		}
+		key, _ := tok.(string)
+
+		// collect unmatched values into the catch-all field
+		cf := creationFields[key]
+		if cf == nil && catchAll != nil {
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return preProcessedEntity, &decodeError{err}
+			}
+			extra[key] = value
+			continue
+		}
+
+		// skip values which cannot be set by the payload
+		if cf == nil || cf.ServerManaged || meta.Entity.IsComputed(cf.Name) ||
+			(key != cf.RequestID && written[cf.RequestID]) {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return preProcessedEntity, &decodeError{err}
+			}
+			continue
+		}
+
+		ok, err := em.streamField(cf, preProcessedEntity.FieldByIndex(cf.Index), dec, strict)
+		if err != nil {
+			return preProcessedEntity, err
+		}
+		written[cf.RequestID] = written[cf.RequestID] || ok
+	}
+
+	// consume closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return preProcessedEntity, &decodeError{err}
+	}
+
+	if len(extra) != 0 {
+		preProcessedEntity.FieldByIndex(catchAll.Index).Set(reflect.ValueOf(extra))
+	}
+	eField.NormalizeStruct(preProcessedEntity)
+
+	// write defaults and check for missing fields
+	missing := make([]string, 0)
+	for _, cf := range meta.FieldClassifications[CreationFieldsToken] {
+		if written[cf.RequestID] || cf.CatchAll || meta.Entity.IsComputed(cf.Name) {
+			continue
+		} else if cf.Default != nil {
+			preProcessedEntity.FieldByIndex(cf.Index).Set(reflect.ValueOf(cf.Default))
+		} else if !cf.ServerManaged {
+			missing = append(missing, cf.RequestID)
+		}
+	}
+	if strict && len(missing) != 0 {
+		return preProcessedEntity, entityErrors.MissingFields(missing)
+	}
+
+	// validate populated entity
+	if err := meta.Entity.Validate(preProcessedEntity.Interface()); err != nil {
+		return preProcessedEntity, err
+	}
+
+	return preProcessedEntity, nil
+}
+
+/*
+streamField reads the next JSON value from the decoder into the given
+field, and returns whether a (non-null) value was written.
+*/
+func (em *EMux) streamField(cf *condensedField, fieldToWrite reflect.Value, dec *json.Decoder, strict bool) (bool, error) {
+	if !cf.EmbeddedEntity.CFlag && !cf.EmbeddedEntity.SFlag {
+		var fieldData interface{}
+		if err := dec.Decode(&fieldData); err != nil {
+			return false, &decodeError{err}
+		} else if fieldData == nil {
+			return false, nil
+		}
+
+		// resolve referenced instance to its ID
+		if refData, ok := fieldData.(map[string]interface{}); ok && cf.Reference != "" {
+			id, err := resolveReference(cf, refData, em.lookupID)
+			if err != nil {
+				return false, err
+			}
+			fieldData = id
+		}
+		return true, eField.WriteToField(&fieldToWrite, fieldData)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return false, &decodeError{err}
+	} else if tok == nil {
+		return false, nil
+	}
+
+	if cf.EmbeddedEntity.CFlag {
+		if cf.EmbeddedEntity.Meta == nil {
+			return false, entityErrors.InvalidEntityLink
+		} else if tok != json.Delim('[') {
+			return false, entityErrors.EmbeddedWriteDataInvalid
+		}
+
+		// write each item individually, as it is read
+		for dec.More() {
+			if tok, err := dec.Token(); err != nil {
+				return false, &decodeError{err}
+			} else if tok != json.Delim('{') {
+				return false, entityErrors.EmbeddedWriteDataInvalid
+			}
+
+			writeValue, err := em.streamObject(cf.EmbeddedEntity.Meta, dec, strict)
+			if err != nil {
+				return false, err
+			}
+
+			// store address of new value for collections of pointers
+			if fieldToWrite.Type().Elem().Kind() == reflect.Ptr {
+				ptr := reflect.New(writeValue.Type())
+				ptr.Elem().Set(writeValue)
+				writeValue = ptr
+			}
+			fieldToWrite.Set(reflect.Append(fieldToWrite, writeValue))
+		}
+
+		// consume closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return false, &decodeError{err}
+		}
+		return true, nil
+	}
+
+	if tok != json.Delim('{') {
+		return false, entityErrors.EmbeddedWriteDataInvalid
+	}
+
+	embedValue, err := em.streamObject(cf.EmbeddedEntity.Meta, dec, strict)
+	if _, ok := err.(*decodeError); ok {
+		return false, err
+	} else if err != nil {
+		return false, entityErrors.Wrap(entityErrors.EmbeddedWriteDataInvalid, err)
+	}
+	return true, eField.WriteToField(&fieldToWrite, embedValue.Interface())
+}
@@ -0,0 +1,114 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+patchOperation is a single operation of an RFC 6902 JSON
+Patch document.
+*/
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+/*
+ApplyJSONPatch parses the given RFC 6902 JSON Patch document and
+returns the equivalent update ESpecs for the Entity e.
+
+The "add", "replace" and "remove" operations are supported. The
+path of each operation is a JSON pointer which is resolved against
+the JSON/BSON/field names (in that priority) of the Entity's fields
+and mapped to the corresponding (dotted) BSON field name. Adding to
+the end of a slice field (a path ending with "/-") produces a "push"
+ESpec; "/-" on a field of any other kind is rejected.
+
+As with Merge, only fields whose eField.HandleTag contains the edit
+token ("e") can be changed: an operation whose path starts at any
+other field results in an entityErrors.FieldNotEditable error.
+Operations on unknown fields, on fields hidden from JSON (JSON tag
+"-") or on the "_id" field are rejected, as are other operation
+types.
+*/
+func (e *Entity) ApplyJSONPatch(patch []byte) ([]spec.ESpec, error) {
+	var operations []patchOperation
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, err
+	}
+
+	specs := make([]spec.ESpec, 0, len(operations))
+	for _, op := range operations {
+		segments, err := pointerSegments(op.Path)
+		if err != nil {
+			return nil, entityErrors.PatchOperationInvalid(op.Op, op.Path)
+		}
+
+		appendItem := op.Op == "add" && segments[len(segments)-1] == "-"
+		if appendItem {
+			segments = segments[:len(segments)-1]
+			if len(segments) == 0 {
+				return nil, entityErrors.PatchOperationInvalid(op.Op, op.Path)
+			}
+		}
+
+		index, names, leaf, err := e.resolve(segments, eField.PriorityJsonBson)
+		if err != nil {
+			return nil, entityErrors.UndefinedPath(op.Path)
+		}
+		if names[0] == "_id" {
+			return nil, entityErrors.PatchOperationInvalid(op.Op, op.Path)
+		}
+		if tag := e.SchemaDefinition.Field(index[0]).Tag.Get(eField.HandleTag); !strings.Contains(tag, editToken) {
+			return nil, entityErrors.FieldNotEditable(op.Path)
+		}
+		if appendItem && leaf.Kind() != reflect.Slice {
+			return nil, entityErrors.PatchOperationInvalid(op.Op, op.Path)
+		}
+		field := strings.Join(names, ".")
+
+		switch op.Op {
+		default:
+			return nil, entityErrors.PatchOperationInvalid(op.Op, op.Path)
+		case "add", "replace":
+			if appendItem {
+				specs = append(specs, spec.ESpec{Field: field, Target: op.Value, UpdateOperator: "push"})
+			} else {
+				specs = append(specs, spec.Set(field, op.Value))
+			}
+		case "remove":
+			// $unset on an array element leaves a null in its place
+			if _, err := strconv.Atoi(segments[len(segments)-1]); err == nil {
+				return nil, entityErrors.PatchOperationInvalid(op.Op, op.Path)
+			}
+			specs = append(specs, spec.Unset(field))
+		}
+	}
+
+	return specs, nil
+}
+
+/*
+pointerSegments splits the given JSON pointer into its
+unescaped reference tokens.
+*/
+func pointerSegments(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") || len(pointer) == 1 {
+		return nil, entityErrors.UndefinedPath(pointer)
+	}
+
+	segments := strings.Split(pointer[1:], "/")
+	for i := range segments {
+		segments[i] = strings.Replace(segments[i], "~1", "/", -1)
+		segments[i] = strings.Replace(segments[i], "~0", "~", -1)
+	}
+	return segments, nil
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+type EditableSuite struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Owner string   `json:"owner"`
+}
+
+type EditableProject struct {
+	ID      string          `json:"id" bson:"_id" _hd_:"e"`
+	Name    string          `json:"name" _hd_:"ce"`
+	Suites  []EditableSuite `json:"suites" _hd_:"e"`
+	Lead    EditableSuite   `json:"lead" _hd_:"e"`
+	Created string          `json:"created" _hd_:"c"`
+}
+
+var editableProjectEntity = &Entity{SchemaDefinition: TypeOf(EditableProject{})}
+
+func TestEntity_ApplyJSONPatch(t *testing.T) {
+	patch := []byte(`[
+		{"op": "replace", "path": "/suites/0/name", "value": "s2"},
+		{"op": "remove", "path": "/name"},
+		{"op": "add", "path": "/suites/-", "value": {"name": "s3"}},
+		{"op": "add", "path": "/lead/tags/-", "value": "core"}
+	]`)
+
+	specs, err := editableProjectEntity.ApplyJSONPatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []spec.ESpec{
+		spec.Set("suites.0.name", "s2"),
+		spec.Unset("name"),
+		{Field: "suites", Target: map[string]interface{}{"name": "s3"}, UpdateOperator: "push"},
+		{Field: "lead.tags", Target: "core", UpdateOperator: "push"},
+	}
+	if !reflect.DeepEqual(specs, expected) {
+		t.Errorf("unexpected specs: %v", specs)
+	}
+}
+
+func TestEntity_ApplyJSONPatchRejected(t *testing.T) {
+	patches := []string{
+		`[{"op": "replace", "path": "/nmae", "value": "p2"}]`,
+		`[{"op": "replace", "path": "/id", "value": "p2"}]`,
+		`[{"op": "move", "from": "/name", "path": "/suites/0/name"}]`,
+		`[{"op": "remove", "path": "/suites/0"}]`,
+		`[{"op": "add", "path": "/name/-", "value": "p2"}]`,
+		`[{"op": "add", "path": "/lead/-", "value": "p2"}]`,
+		`[{"op":"add","path":"/-","value":1}]`,
+	}
+
+	for _, patch := range patches {
+		if _, err := editableProjectEntity.ApplyJSONPatch([]byte(patch)); err == nil {
+			t.Errorf("%s: expected error", patch)
+		}
+	}
+}
+
+func TestEntity_ApplyJSONPatchNotEditable(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/created", "value": "today"}]`)
+	if _, err := editableProjectEntity.ApplyJSONPatch(patch); err == nil || err.Error() != entityErrors.FieldNotEditable("/created").Error() {
+		t.Errorf("expected not editable error, got %v", err)
+	}
+
+	// fields without any HandleTag are not editable
+	patch = []byte(`[{"op": "replace", "path": "/suites/0/name", "value": "s2"}]`)
+	if _, err := projectEntity.ApplyJSONPatch(patch); err == nil || err.Error() != entityErrors.FieldNotEditable("/suites/0/name").Error() {
+		t.Errorf("expected not editable error, got %v", err)
+	}
+}
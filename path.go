@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+ResolvePath walks the SchemaDefinition of the Entity e along
+the given dotted path, for example "suites.tests.name", and
+returns the index of the matched field at each level of
+nesting along with the type of the final field.
+
+Each segment of the path is matched against the BSON/JSON/field
+name (in that priority) of a field, since this is the name under
+which the field is stored. Fields with the BSON tag "-" are not
+stored and therefore cannot be resolved. Collection fields (slices, arrays)
+are traversed through their element type and numeric segments
+following them are treated as positional and skipped.
+
+If a segment cannot be matched, an entityErrors.UndefinedPath
+error is returned.
+*/
+func (e *Entity) ResolvePath(dotted string) ([]int, reflect.Type, error) {
+	index, _, leaf, err := e.resolve(strings.Split(dotted, "."), eField.PriorityBsonJson)
+	if err != nil {
+		return nil, nil, entityErrors.UndefinedPath(dotted)
+	}
+	return index, leaf, nil
+}
+
+/*
+resolve walks the SchemaDefinition of the Entity e along the
+given path segments, matching each one against field names
+chosen using the given priority. Fields whose tag for the first
+of the priority's tags is "-" are skipped.
+
+It returns the index of the matched field at each level of
+nesting, the storage (BSON/JSON/field) name of each segment
+(positional segments are kept as-is) and the type of the
+final field.
+*/
+func (e *Entity) resolve(segments []string, p eField.Priority) ([]int, []string, reflect.Type, error) {
+	current := e.SchemaDefinition
+	index := make([]int, 0)
+	names := make([]string, 0, len(segments))
+
+	for _, segment := range segments {
+		if current.Kind() == reflect.Ptr {
+			current = current.Elem()
+		}
+		if current.Kind() == reflect.Slice || current.Kind() == reflect.Array {
+			current = current.Elem()
+			if _, err := strconv.Atoi(segment); err == nil {
+				names = append(names, segment)
+				continue
+			}
+		}
+		if current.Kind() == reflect.Ptr {
+			current = current.Elem()
+		}
+
+		if current.Kind() != reflect.Struct {
+			return nil, nil, nil, entityErrors.UndefinedPath(segment)
+		}
+
+		matched := false
+		for i := 0; i < current.NumField(); i++ {
+			field := current.Field(i)
+			// fields hidden under the priority's preferred tag cannot be addressed
+			if len(p.Tags) > 0 && field.Tag.Get(p.Tags[0]) == "-" {
+				continue
+			}
+
+			if eField.NameByPriority(field, p) == segment {
+				index = append(index, i)
+				names = append(names, eField.NameByPriority(field, eField.PriorityBsonJson))
+				current = field.Type
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil, nil, nil, entityErrors.UndefinedPath(segment)
+		}
+	}
+
+	return index, names, current, nil
+}
+
+/*
+BuildFilter combines the given query ESpecs into a single BSON
+filter using spec.CombineSpecs, after checking that the Field of
+each ESpec resolves to a field of the Entity e.
+
+This prevents a mistyped (nested) field name from silently
+matching nothing in the database.
+*/
+func (e *Entity) BuildFilter(specs []spec.ESpec) (bson.M, error) {
+	for i := range specs {
+		if _, _, err := e.ResolvePath(specs[i].Field); err != nil {
+			return nil, err
+		}
+	}
+
+	return spec.CombineSpecs(specs), nil
+}
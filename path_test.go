@@ -0,0 +1,82 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/spec"
+)
+
+type TestCase struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type TestSuite struct {
+	ID    string     `json:"id"`
+	Name  string     `json:"name"`
+	Tests []TestCase `json:"tests"`
+}
+
+type Project struct {
+	ID     string      `json:"id" bson:"_id"`
+	Name   string      `json:"name"`
+	Suites []TestSuite `json:"suites"`
+}
+
+var projectEntity = &Entity{SchemaDefinition: TypeOf(Project{})}
+
+func TestEntity_ResolvePath(t *testing.T) {
+	index, leaf, err := projectEntity.ResolvePath("suites.tests.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(index, []int{2, 2, 1}) {
+		t.Errorf("unexpected index: %v", index)
+	}
+	if leaf.Kind() != reflect.String {
+		t.Errorf("unexpected leaf type: %v", leaf)
+	}
+}
+
+func TestEntity_ResolvePathPositional(t *testing.T) {
+	index, leaf, err := projectEntity.ResolvePath("suites.0.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(index, []int{2, 1}) || leaf.Kind() != reflect.String {
+		t.Fail()
+	}
+}
+
+func TestEntity_ResolvePathUndefined(t *testing.T) {
+	for _, path := range []string{"suites.nmae", "name.first", "ID", ""} {
+		if _, _, err := projectEntity.ResolvePath(path); err == nil {
+			t.Errorf("%s: expected error", path)
+		}
+	}
+}
+
+func TestEntity_BuildFilter(t *testing.T) {
+	specs, err := spec.Where().Eq("suites.name", "s1").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := projectEntity.BuildFilter(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filter, bson.M{"suites.name": "s1"}) {
+		t.Fail()
+	}
+
+	specs[0].Field = "suite.name"
+	if _, err := projectEntity.BuildFilter(specs); err == nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,197 @@
+package entity
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+PopulatedKey is the key under which the pipeline built by
+ReadPopulated stores the referenced documents, so that the
+reference fields themselves keep their IDs.
+*/
+const PopulatedKey = "_populated_"
+
+/*
+Populated is the result of ReadPopulated.
+*/
+type Populated struct {
+	// Entity is the instance of the SchemaDefinition read.
+	Entity interface{}
+	/*
+		References maps the storage names of the populated
+		reference fields to the instances (of the referenced
+		Entities' SchemaDefinitions) that they reference.
+		References which do not match any document are left
+		out.
+	*/
+	References map[string]interface{}
+}
+
+/*
+ReadPopulated reads the document matching the filter produced by
+the given entity (see Filter) from the Entity e's collection, along
+with the documents referenced by the reference fields named by
+populate.
+
+Reference fields are primitive.ObjectID fields with an
+eField.RefTag, whose value is the EntityID of the referenced
+Entity. They are named by their storage name (see
+eField.StorageName). The referenced Entities are found using
+the Entity's ResolveReference function, and the referenced
+documents are read from their collections; an
+entityErrors.TagUndefined error is returned for a reference
+which cannot be resolved, or whose Entity has no collection.
+
+If no document matches the filter, mongo.ErrNoDocuments is
+returned.
+*/
+func (e *Entity) ReadPopulated(ctx context.Context, entity interface{}, populate ...string) (*Populated, error) {
+	return e.readPopulated(entity, populate, func(pipeline mongo.Pipeline) (bson.Raw, error) {
+		cursor, err := e.PStorage.Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		if !cursor.Next(ctx) {
+			if err := cursor.Err(); err != nil {
+				return nil, err
+			}
+			return nil, mongo.ErrNoDocuments
+		}
+		return cursor.Current, nil
+	})
+}
+
+/*
+readPopulated builds the pipeline for ReadPopulated, uses the given
+aggregate function to read the resulting document and decodes it.
+*/
+func (e *Entity) readPopulated(entity interface{}, populate []string,
+	aggregate func(pipeline mongo.Pipeline) (bson.Raw, error)) (*Populated, error) {
+	if !e.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	filter := Filter(entity)
+	if filter == nil {
+		return nil, entityErrors.UndefinedAxis
+	}
+
+	refs, err := e.references(populate)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := aggregate(populatePipeline(filter, populate, refs))
+	if err != nil {
+		return nil, err
+	}
+
+	read, err := decodeInstance(e, raw)
+	if err != nil {
+		return nil, err
+	}
+	populated := &Populated{Entity: read, References: make(map[string]interface{})}
+
+	for i, name := range populate {
+		refRaw, ok := raw.Lookup(PopulatedKey, name).DocumentOK()
+		if !ok {
+			continue
+		}
+
+		ref, err := decodeInstance(refs[i], refRaw)
+		if err != nil {
+			return nil, err
+		}
+		populated.References[name] = ref
+	}
+
+	return populated, nil
+}
+
+/*
+references returns the referenced Entities of the named reference
+fields, in order.
+*/
+func (e *Entity) references(populate []string) ([]*Entity, error) {
+	refs := make([]*Entity, len(populate))
+
+	for i, name := range populate {
+		entityID := ""
+		for j := 0; j < e.SchemaDefinition.NumField(); j++ {
+			field := e.SchemaDefinition.Field(j)
+			if eField.StorageName(field) == name {
+				entityID = field.Tag.Get(eField.RefTag)
+				break
+			}
+		}
+		if entityID == "" {
+			return nil, entityErrors.NoTag(eField.RefTag, name)
+		}
+
+		var ref *Entity
+		if e.ResolveReference != nil {
+			ref = e.ResolveReference(entityID)
+		}
+		if ref == nil || ref.PStorage == nil {
+			return nil, entityErrors.TagUndefined(eField.RefTag, entityID)
+		}
+		refs[i] = ref
+	}
+
+	return refs, nil
+}
+
+/*
+populatePipeline returns the aggregation pipeline used by ReadPopulated
+to read the document matching the given filter, with a $lookup stage for
+each of the named reference fields, from the collections of the given
+referenced Entities.
+*/
+func populatePipeline(filter bson.M, populate []string, refs []*Entity) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$limit", Value: 1}},
+	}
+
+	for i, name := range populate {
+		as := PopulatedKey + "." + name
+		pipeline = append(pipeline,
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: refs[i].PStorage.Name()},
+				{Key: "localField", Value: name},
+				{Key: "foreignField", Value: "_id"},
+				{Key: "as", Value: as},
+			}}},
+			bson.D{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$" + as},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+		)
+	}
+
+	return pipeline
+}
+
+/*
+decodeInstance decodes the given document into an instance of the
+SchemaDefinition of the Entity e, decrypting its encrypted fields.
+*/
+func decodeInstance(e *Entity, raw bson.Raw) (interface{}, error) {
+	instance := reflect.New(e.SchemaDefinition)
+	if err := bson.Unmarshal(raw, instance.Interface()); err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	if err := e.decrypt(instance.Elem()); err != nil {
+		return nil, err
+	}
+	return instance.Elem().Interface(), nil
+}
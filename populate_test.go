@@ -0,0 +1,174 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RefTask struct {
+	ID   primitive.ObjectID `json:"-" bson:"_id"`
+	Name string             `json:"name" bson:"name"`
+}
+
+type RefUser struct {
+	Email string             `json:"email" bson:"email" _ax_:"true"`
+	Task  primitive.ObjectID `json:"task" bson:"task" _ref_:"task"`
+}
+
+// refEntities returns a "user" Entity referencing a "task" Entity stored in a "tasks" collection
+func refEntities(t *testing.T) (*Entity, *Entity) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("test")
+
+	tasks, err := NewEntity(reflect.TypeOf(RefTask{}), db.Collection("tasks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := NewEntity(reflect.TypeOf(RefUser{}), db.Collection("users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.ResolveReference = func(entityID string) *Entity {
+		if entityID == "task" {
+			return tasks
+		}
+		return nil
+	}
+	return users, tasks
+}
+
+func TestPopulatePipeline(t *testing.T) {
+	users, tasks := refEntities(t)
+
+	filter := bson.M{"email": "user@host.com"}
+	pipeline := populatePipeline(filter, []string{"task"}, []*Entity{tasks})
+
+	if len(pipeline) != 4 {
+		t.Fatalf("expected 4 stages, got %d", len(pipeline))
+	}
+
+	// the collection comes from the referenced Entity, not the tag
+	lookup := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "tasks"},
+		{Key: "localField", Value: "task"},
+		{Key: "foreignField", Value: "_id"},
+		{Key: "as", Value: PopulatedKey + ".task"},
+	}}}
+	if !reflect.DeepEqual(pipeline[2], lookup) {
+		t.Errorf("unexpected lookup stage: %v", pipeline[2])
+	}
+
+	// missing referenced documents are left out rather than dropping the user
+	unwind := pipeline[3][0].Value.(bson.D)
+	if unwind[1].Value != true {
+		t.Errorf("unwind does not preserve unmatched references: %v", unwind)
+	}
+
+	if refs, err := users.references([]string{"task"}); err != nil || refs[0] != tasks {
+		t.Errorf("reference not resolved: %v, %v", refs, err)
+	}
+}
+
+func TestReadPopulated(t *testing.T) {
+	users, _ := refEntities(t)
+	taskID := primitive.NewObjectID()
+
+	var pipeline mongo.Pipeline
+	raw, _ := bson.Marshal(bson.M{
+		"email": "user@host.com",
+		"task":  taskID,
+		PopulatedKey: bson.M{
+			"task": bson.M{"_id": taskID, "name": "write tests"},
+		},
+	})
+	populated, err := users.readPopulated(RefUser{Email: "user@host.com"}, []string{"task"},
+		func(p mongo.Pipeline) (bson.Raw, error) {
+			pipeline = p
+			return raw, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pipeline) != 4 {
+		t.Errorf("expected 4 stages, got %d", len(pipeline))
+	}
+	if user, ok := populated.Entity.(RefUser); !ok || user.Task != taskID || user.Email != "user@host.com" {
+		t.Errorf("entity not decoded into its SchemaDefinition: %#v", populated.Entity)
+	}
+	if task, ok := populated.References["task"].(RefTask); !ok || task.ID != taskID || task.Name != "write tests" {
+		t.Errorf("reference not decoded into its SchemaDefinition: %#v", populated.References["task"])
+	}
+}
+
+func TestReadPopulatedMissingReference(t *testing.T) {
+	users, _ := refEntities(t)
+	taskID := primitive.NewObjectID()
+
+	// the $unwind stage leaves out the populated key if nothing matched
+	raw, _ := bson.Marshal(bson.M{"email": "user@host.com", "task": taskID})
+	populated, err := users.readPopulated(RefUser{Email: "user@host.com"}, []string{"task"},
+		func(p mongo.Pipeline) (bson.Raw, error) {
+			return raw, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user := populated.Entity.(RefUser); user.Task != taskID {
+		t.Errorf("reference ID not kept: %v", user.Task)
+	}
+	if len(populated.References) != 0 {
+		t.Errorf("expected missing reference to be left out: %v", populated.References)
+	}
+}
+
+func TestReadPopulatedNoDocuments(t *testing.T) {
+	users, _ := refEntities(t)
+
+	_, err := users.readPopulated(RefUser{Email: "user@host.com"}, []string{"task"},
+		func(p mongo.Pipeline) (bson.Raw, error) {
+			return nil, mongo.ErrNoDocuments
+		})
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		t.Errorf("expected mongo.ErrNoDocuments, got %v", err)
+	}
+}
+
+func TestReadPopulatedUnresolved(t *testing.T) {
+	users, _ := refEntities(t)
+	read := func(populate ...string) error {
+		_, err := users.readPopulated(RefUser{Email: "user@host.com"}, populate,
+			func(p mongo.Pipeline) (bson.Raw, error) {
+				t.Errorf("aggregation run for unresolved reference")
+				return nil, nil
+			})
+		return err
+	}
+
+	if err := read("email"); err == nil {
+		t.Errorf("expected non-reference field to be rejected")
+	}
+
+	// referenced Entities without a collection, such as templated ones
+	users.ResolveReference = func(entityID string) *Entity {
+		return &Entity{SchemaDefinition: reflect.TypeOf(RefTask{})}
+	}
+	if err := read("task"); err == nil {
+		t.Errorf("expected reference without collection to be rejected")
+	}
+
+	users.ResolveReference = nil
+	if err := read("task"); err == nil {
+		t.Errorf("expected reference to be unresolved without ResolveReference")
+	}
+}
@@ -0,0 +1,144 @@
+package entity
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+documentCursor is the part of a *mongo.Cursor used by ReadAll.
+*/
+type documentCursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+	Close(ctx context.Context) error
+}
+
+/*
+ReadAll decodes the documents in the underlying database collection
+pointed at by e which match the given query ESpecs (see BuildFilter)
+into dest, which must be a pointer to a slice of the Entity's
+SchemaDefinition type. For example:
+
+	var users []User
+	err := userEntity.ReadAll(ctx, []spec.ESpec{{Field: "role", Target: "admin"}}, &users)
+
+The documents are decoded directly into the elements of the slice,
+so they need no type assertions; encrypted fields are decrypted.
+If dest is of any other type, entityErrors.IncompatibleEntityType
+is returned and dest is left unchanged.
+*/
+func (e *Entity) ReadAll(ctx context.Context, filter []spec.ESpec, dest interface{}) error {
+	return e.readAll(ctx, filter, dest, func(filter bson.M) (documentCursor, error) {
+		return e.PStorage.Find(ctx, filter)
+	})
+}
+
+/*
+readAll checks dest and builds the filter for the given specs, before
+using the given find function to open a cursor over the matching
+documents, which are decoded into dest. The given context is used to
+iterate over (and close) the cursor.
+*/
+func (e *Entity) readAll(ctx context.Context, specs []spec.ESpec, dest interface{},
+	find func(filter bson.M) (documentCursor, error)) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice ||
+		v.Elem().Type().Elem() != e.SchemaDefinition {
+		return entityErrors.IncompatibleEntityType
+	}
+
+	filter, err := e.BuildFilter(specs)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := find(filter)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cursor.Close(ctx) }()
+
+	results := reflect.MakeSlice(v.Elem().Type(), 0, 0)
+	for cursor.Next(ctx) {
+		entity := reflect.New(e.SchemaDefinition)
+		if err := cursor.Decode(entity.Interface()); err != nil {
+			return entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+		}
+		if err := e.decrypt(entity.Elem()); err != nil {
+			return err
+		}
+		results = reflect.Append(results, entity.Elem())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	v.Elem().Set(results)
+	return nil
+}
+
+/*
+ReadInto finds the document matching the filter produced by the given
+entity (see Filter) in the underlying database collection pointed at
+by e and decodes it into dest, which must be a pointer to an instance
+of the Entity's SchemaDefinition. For example:
+
+	var user User
+	err := userEntity.ReadInto(ctx, User{Email: "jane@example.com"}, &user)
+
+Encrypted fields are decrypted. If dest is of any other type (or the
+given entity is not of the SchemaDefinition type),
+entityErrors.IncompatibleEntityType is returned; if no document
+matches, entityErrors.NotFound is returned.
+*/
+func (e *Entity) ReadInto(ctx context.Context, entity, dest interface{}) error {
+	return e.readInto(entity, dest, func(filter bson.M) (bson.Raw, error) {
+		return e.PStorage.FindOne(ctx, filter).DecodeBytes()
+	})
+}
+
+/*
+readInto checks the given entity and dest, before using the given
+find function to find the document matching the entity's filter,
+which is decoded into dest.
+*/
+func (e *Entity) readInto(entity, dest interface{}, find func(filter bson.M) (bson.Raw, error)) error {
+	v := reflect.ValueOf(dest)
+	if !e.typeCheck(entity) || v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != e.SchemaDefinition {
+		return entityErrors.IncompatibleEntityType
+	}
+
+	filter := Filter(entity)
+	if filter == nil {
+		return entityErrors.UndefinedAxis
+	}
+
+	raw, err := find(filter)
+	if err == mongo.ErrNoDocuments {
+		return entityErrors.NotFound
+	} else if err != nil {
+		return err
+	}
+
+	read := reflect.New(e.SchemaDefinition)
+	if err := bson.Unmarshal(raw, read.Interface()); err != nil {
+		return entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	if err := e.decrypt(read.Elem()); err != nil {
+		return err
+	}
+
+	v.Elem().Set(read.Elem())
+	return nil
+}
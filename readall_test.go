@@ -0,0 +1,137 @@
+package entity
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+type TestUser struct {
+	Name string `json:"name" bson:"name" _ax_:"true"`
+	Role string `json:"role" bson:"role"`
+}
+
+// sliceCursor is a documentCursor over a slice of documents, which stops once its context is done
+type sliceCursor struct {
+	docs []interface{}
+	i    int
+}
+
+func (c *sliceCursor) Next(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	c.i++
+	return c.i <= len(c.docs)
+}
+
+func (c *sliceCursor) Decode(val interface{}) error {
+	raw, err := bson.Marshal(c.docs[c.i-1])
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, val)
+}
+
+func (c *sliceCursor) Err() error { return nil }
+
+func (c *sliceCursor) Close(ctx context.Context) error { return nil }
+
+func TestEntity_ReadAll(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(TestUser{})}
+	expected := []TestUser{{Name: "Jane", Role: "admin"}, {Name: "John", Role: "admin"}}
+
+	var filter bson.M
+	var users []TestUser
+	err := ety.readAll(context.Background(), []spec.ESpec{{Field: "role", Target: "admin"}}, &users,
+		func(f bson.M) (documentCursor, error) {
+			filter = f
+			return &sliceCursor{docs: []interface{}{expected[0], expected[1]}}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(filter, bson.M{"role": "admin"}) {
+		t.Errorf("unexpected filter: %v", filter)
+	}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %v, got %v", expected, users)
+	}
+}
+
+func TestEntity_ReadAllCanceled(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(TestUser{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var users []TestUser
+	err := ety.readAll(ctx, nil, &users, func(f bson.M) (documentCursor, error) {
+		return &sliceCursor{docs: []interface{}{TestUser{Name: "Jane"}}}, nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if users != nil {
+		t.Errorf("dest changed by canceled read: %v", users)
+	}
+}
+
+func TestEntity_ReadAllIncompatible(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(TestUser{})}
+	find := func(f bson.M) (documentCursor, error) {
+		t.Errorf("unexpected query")
+		return &sliceCursor{}, nil
+	}
+
+	var jobs []Job
+	var users []TestUser
+	for _, dest := range []interface{}{&jobs, users, nil} {
+		if err := ety.readAll(context.Background(), nil, dest, find); err != entityErrors.IncompatibleEntityType {
+			t.Errorf("expected IncompatibleEntityType for %T, got %v", dest, err)
+		}
+	}
+}
+
+func TestEntity_ReadInto(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(TestUser{})}
+	stored := TestUser{Name: "Jane", Role: "admin"}
+
+	var filter bson.M
+	find := func(f bson.M) (bson.Raw, error) {
+		filter = f
+		return bson.Marshal(stored)
+	}
+
+	var user TestUser
+	if err := ety.readInto(TestUser{Name: "Jane"}, &user, find); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filter, bson.M{"name": "Jane"}) {
+		t.Errorf("unexpected filter: %v", filter)
+	}
+	if user != stored {
+		t.Errorf("expected %v, got %v", stored, user)
+	}
+
+	var job Job
+	if err := ety.readInto(TestUser{Name: "Jane"}, &job, find); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected IncompatibleEntityType, got %v", err)
+	}
+	if err := ety.readInto(TestUser{Name: "Jane"}, user, find); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected IncompatibleEntityType for non-pointer, got %v", err)
+	}
+
+	err := ety.readInto(TestUser{Name: "Jane"}, &user, func(f bson.M) (bson.Raw, error) {
+		return nil, mongo.ErrNoDocuments
+	})
+	if err != entityErrors.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package entity
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/navaz-alani/entity/eField"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+/*
+JSONSchema generates a MongoDB "$jsonSchema" collection validator
+for the Entity e's SchemaDefinition. This allows validation to be
+enforced by the database itself, for example by running the
+"collMod" command with the returned document as the "validator".
+
+Properties are named by their BSON/JSON/field name (in that
+priority) and fields are described as follows:
+  - fields with RequireTag set to "true" are required,
+  - the "bsonType" is derived from the field's kind,
+  - the "pattern" is taken from the field's ValidateTag, if
+    it uses the RegexPrefix or PresetPrefix.
+*/
+func (e *Entity) JSONSchema() (bson.M, error) {
+	schema, err := objectSchema(e.SchemaDefinition)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$jsonSchema": schema}, nil
+}
+
+/*
+objectSchema returns the JSON schema describing the given
+struct type.
+*/
+func objectSchema(t reflect.Type) (bson.M, error) {
+	properties := bson.M{}
+	required := bson.A{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get(eField.BSONTag) == "-" {
+			continue
+		}
+
+		name := eField.NameByPriority(field, eField.PriorityBsonJson)
+		property, err := fieldSchema(field.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if tag := field.Tag.Get(eField.ValidateTag); tag != "" && tag != "-" {
+			pattern, err := validationPattern(tag)
+			if err != nil {
+				return nil, err
+			}
+			property["pattern"] = pattern
+		}
+
+		properties[name] = property
+		if field.Tag.Get(eField.RequireTag) == "true" {
+			required = append(required, name)
+		}
+	}
+
+	schema := bson.M{"bsonType": "object", "properties": properties}
+	if len(required) != 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+/*
+fieldSchema returns the JSON schema describing a field of the
+given type.
+*/
+func fieldSchema(t reflect.Type) (bson.M, error) {
+	switch t {
+	case timeType:
+		return bson.M{"bsonType": "date"}, nil
+	case objectIDType:
+		return bson.M{"bsonType": "objectId"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema, err := fieldSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if bsonType, ok := schema["bsonType"]; ok {
+			schema["bsonType"] = bson.A{bsonType, "null"}
+		}
+		return schema, nil
+	case reflect.String:
+		return bson.M{"bsonType": "string"}, nil
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint8, reflect.Uint16:
+		return bson.M{"bsonType": bson.A{"int", "long"}}, nil
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return bson.M{"bsonType": "long"}, nil
+	case reflect.Float32, reflect.Float64:
+		return bson.M{"bsonType": "double"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bson.M{"bsonType": "binData"}, nil
+		}
+		items, err := fieldSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return bson.M{"bsonType": "array", "items": items}, nil
+	case reflect.Map:
+		return bson.M{"bsonType": "object"}, nil
+	case reflect.Struct:
+		return objectSchema(t)
+	}
+
+	// interfaces and other kinds are left unconstrained
+	return bson.M{}, nil
+}
+
+/*
+validationPattern returns the regular expression used by the
+given ValidateTag value.
+*/
+func validationPattern(tag string) (string, error) {
+	if _, err := StringValidator(tag); err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(tag, PresetPrefix) {
+		return validationPresets[tag[len(PresetPrefix):len(tag)-1]].String(), nil
+	}
+	return tag[len(RegexPrefix) : len(tag)-1], nil
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type SchemaUser struct {
+	Name    string   `json:"name" bson:"name" _rq_:"true" _va_:"re/^[a-z]+$/"`
+	Email   string   `json:"email" _va_:"rep/email/"`
+	Age     int64    `json:"age" bson:"age"`
+	Tags    []string `json:"tags" bson:"tags"`
+	Ignored string   `bson:"-"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	ety := &Entity{SchemaDefinition: reflect.TypeOf(SchemaUser{})}
+
+	validator, err := ety.JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bson.M{"$jsonSchema": bson.M{
+		"bsonType": "object",
+		"required": bson.A{"name"},
+		"properties": bson.M{
+			"name":  bson.M{"bsonType": "string", "pattern": "^[a-z]+$"},
+			"email": bson.M{"bsonType": "string", "pattern": validationPresets["email"].String()},
+			"age":   bson.M{"bsonType": "long"},
+			"tags":  bson.M{"bsonType": "array", "items": bson.M{"bsonType": "string"}},
+		},
+	}}
+
+	if !reflect.DeepEqual(validator, expected) {
+		t.Errorf("unexpected schema: %v", validator)
+	}
+}
+
+func TestJSONSchemaBadPattern(t *testing.T) {
+	type BadPattern struct {
+		F1 string `json:"f1" _va_:"rep/unknown/"`
+	}
+
+	ety := &Entity{SchemaDefinition: reflect.TypeOf(BadPattern{})}
+	if _, err := ety.JSONSchema(); err == nil {
+		t.Fail()
+	}
+}
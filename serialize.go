@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+ToBSONMap encodes the given entity, which is expected to be of the
+Entity e's SchemaDefinition type, into a BSON map as the mongo driver
+would store it. BSON tags are honored: fields are named by their BSON
+tags (see eField.StorageName), "inline" and "omitempty" options apply
+and fields tagged "-" are left out.
+
+Unlike the package level ToBSON, the "_id" field is included, so that
+FromBSONMap can restore the entity exactly; this is useful for storing
+entities in a cache.
+*/
+func (e *Entity) ToBSONMap(entity interface{}) (bson.M, error) {
+	if !e.typeCheck(entity) {
+		return nil, entityErrors.IncompatibleEntityType
+	}
+
+	raw, err := bson.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	m := bson.M{}
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	return m, nil
+}
+
+/*
+FromBSONMap decodes the given BSON map, such as one returned by
+ToBSONMap, into an instance of the Entity e's SchemaDefinition.
+An error wrapping entityErrors.DBDecodeFail is returned if the map
+does not have the shape of the Entity.
+*/
+func (e *Entity) FromBSONMap(m bson.M) (interface{}, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+
+	entity := reflect.New(e.SchemaDefinition)
+	if err := bson.Unmarshal(raw, entity.Interface()); err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+	return entity.Elem().Interface(), nil
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+type CachedUser struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Email    string             `json:"email" bson:"email" _ax_:"true"`
+	Age      int64              `bson:"age"`
+	Tags     []string           `bson:"tags"`
+	Joined   time.Time          `bson:"joined"`
+	Password string             `bson:"-"`
+}
+
+func TestEntity_BSONMapRoundTrip(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(CachedUser{})}
+	user := CachedUser{
+		ID:       primitive.NewObjectID(),
+		Email:    "jane.doe@example.com",
+		Age:      30,
+		Tags:     []string{"admin"},
+		Joined:   time.Unix(1580000000, 0).UTC(),
+		Password: "secret",
+	}
+
+	m, err := ety.ToBSONMap(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["_id"] != user.ID || m["email"] != user.Email {
+		t.Errorf("unexpected map: %v", m)
+	}
+	if _, ok := m["password"]; ok {
+		t.Errorf("ignored field encoded: %v", m)
+	}
+
+	decoded, err := ety.FromBSONMap(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Password = ""
+	if !reflect.DeepEqual(decoded, user) {
+		t.Errorf("expected %v, got %v", user, decoded)
+	}
+}
+
+func TestEntity_BSONMapErrors(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(CachedUser{})}
+
+	if _, err := ety.ToBSONMap(AxisUser{}); err != entityErrors.IncompatibleEntityType {
+		t.Errorf("expected IncompatibleEntityType, got %v", err)
+	}
+	if _, err := ety.FromBSONMap(bson.M{"age": "thirty"}); !errors.Is(err, entityErrors.DBDecodeFail) {
+		t.Errorf("expected DBDecodeFail, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package spec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+FromBSON parses the given query filter into ESpecs; it is the inverse
+of ToBSON (and CombineSpecs) and can be used, for example, to load
+filters which were stored as BSON.
+
+The filter may map fields to values ({field: value}) or to documents
+of query operators ({field: {$op: value}}), in which case an ESpec is
+returned for each operator. {$not: {$op: value}} is parsed as a
+negated operator (except for $near, which cannot be negated) and
+$regex is parsed along with its $options (a $regex without $options
+is given NoRegexOptions, so that it is still matched case-sensitively
+when converted back to BSON). Note that
+{$ne: value} is parsed as the "ne" QueryOperator rather than as a
+negated equality, since both produce the same filter.
+
+$and wrappers are flattened, as ESpecs are combined using $and. Since
+a set of ESpecs cannot express a disjunction, $or is only accepted
+with a single clause. An error is returned for any other shape.
+*/
+func FromBSON(m bson.M) ([]ESpec, error) {
+	specs := make([]ESpec, 0, len(m))
+
+	for _, field := range sortedKeys(m) {
+		value := m[field]
+
+		switch field {
+		case "$and", "$or":
+			clauses, ok := toArray(value)
+			if !ok {
+				return nil, fmt.Errorf("spec: '%s' requires an array of filters", field)
+			}
+			if field == "$or" && len(clauses) != 1 {
+				return nil, fmt.Errorf("spec: '$or' with %d clauses cannot be represented", len(clauses))
+			}
+
+			for _, clause := range clauses {
+				doc, ok := toDoc(clause)
+				if !ok {
+					return nil, fmt.Errorf("spec: '%s' requires an array of filters", field)
+				}
+				clauseSpecs, err := FromBSON(doc)
+				if err != nil {
+					return nil, err
+				}
+				specs = append(specs, clauseSpecs...)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("spec: unsupported top-level operator '%s'", field)
+		}
+
+		ops, ok := toDoc(value)
+		if !ok || !isOperatorDoc(ops) {
+			specs = append(specs, ESpec{Field: field, Target: value})
+			continue
+		}
+
+		fieldSpecs, err := operatorSpecs(field, ops)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, fieldSpecs...)
+	}
+
+	return specs, nil
+}
+
+/*
+operatorSpecs parses the given document of query operators on the
+given field into ESpecs.
+*/
+func operatorSpecs(field string, ops bson.M) ([]ESpec, error) {
+	specs := make([]ESpec, 0, len(ops))
+
+	if pattern, ok := ops["$regex"]; ok {
+		s := ESpec{Field: field, Target: pattern, QueryOperator: "regex", Options: NoRegexOptions}
+		if options, ok := ops["$options"]; ok {
+			if s.Options, ok = options.(string); !ok {
+				return nil, fmt.Errorf("spec: '$options' on '%s' must be a string", field)
+			}
+			if s.Options == "" {
+				s.Options = NoRegexOptions
+			}
+		}
+		specs = append(specs, s)
+	} else if _, ok := ops["$options"]; ok {
+		return nil, fmt.Errorf("spec: '$options' on '%s' without '$regex'", field)
+	}
+
+	for _, op := range sortedKeys(ops) {
+		switch op {
+		case "$regex", "$options":
+			continue
+		case "$not":
+			negated, ok := toDoc(ops[op])
+			if !ok || !isOperatorDoc(negated) {
+				return nil, fmt.Errorf("spec: '$not' on '%s' requires an operator document", field)
+			}
+			negatedSpecs, err := operatorSpecs(field, negated)
+			if err != nil {
+				return nil, err
+			}
+			for i := range negatedSpecs {
+				negatedSpecs[i].Negate = true
+				if negatedSpecs[i].QueryOperator == "near" {
+					return nil, fmt.Errorf("spec: '$near' on '%s' cannot be negated", field)
+				}
+			}
+			specs = append(specs, negatedSpecs...)
+		default:
+			specs = append(specs, ESpec{Field: field, Target: ops[op], QueryOperator: op[1:]})
+		}
+	}
+
+	return specs, nil
+}
+
+/*
+isOperatorDoc returns whether all the keys of the given (non-empty)
+document are query operators.
+*/
+func isOperatorDoc(doc bson.M) bool {
+	_, ok := operatorDoc(doc)
+	return ok
+}
+
+/*
+toDoc returns the given value as a BSON map, if it is a document.
+*/
+func toDoc(value interface{}) (bson.M, bool) {
+	switch doc := value.(type) {
+	case bson.M:
+		return doc, true
+	case map[string]interface{}:
+		return bson.M(doc), true
+	case bson.D:
+		return doc.Map(), true
+	}
+	return nil, false
+}
+
+/*
+toArray returns the given value as a BSON array, if it is one.
+*/
+func toArray(value interface{}) (bson.A, bool) {
+	switch arr := value.(type) {
+	case bson.A:
+		return arr, true
+	case []interface{}:
+		return bson.A(arr), true
+	}
+	return nil, false
+}
+
+/*
+sortedKeys returns the keys of the given map in sorted order, so that
+the parsed ESpecs are deterministic.
+*/
+func sortedKeys(m bson.M) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
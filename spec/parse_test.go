@@ -0,0 +1,81 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFromBSON_RoundTrip(t *testing.T) {
+	roundTrips := []ESpec{
+		{Field: "status", Target: "active"},
+		{Field: "age", Target: 18, QueryOperator: "gt"},
+		{Field: "role", Target: []string{"a", "b"}, QueryOperator: "in"},
+		{Field: "age", Target: 65, QueryOperator: "lt", Negate: true},
+		{Field: "name", Target: "^ja", QueryOperator: "regex", Options: "i"},
+		{Field: "name", Target: "^ja", QueryOperator: "regex", Options: NoRegexOptions, Negate: true},
+		{Field: "nickname", Target: false, QueryOperator: "exists"},
+		{Field: "suites", Target: 3, QueryOperator: "size"},
+	}
+
+	for _, s := range roundTrips {
+		specs, err := FromBSON(s.ToBSON())
+		if err != nil {
+			t.Errorf("%+v: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(specs, []ESpec{s}) {
+			t.Errorf("expected %+v, got %+v", s, specs)
+		}
+	}
+}
+
+func TestFromBSON_Combined(t *testing.T) {
+	specs := []ESpec{
+		{Field: "age", Target: 18, QueryOperator: "gt"},
+		{Field: "age", Target: 65, QueryOperator: "lt"},
+		{Field: "status", Target: "active"},
+		{Field: "status", Target: "archived"},
+	}
+
+	// conflicting equality constraints are combined using $and
+	combined := CombineSpecs(specs)
+	parsed, err := FromBSON(combined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(CombineSpecs(parsed), combined) {
+		t.Errorf("expected %v, got %v", combined, CombineSpecs(parsed))
+	}
+
+	parsed, err = FromBSON(CombineSpecs(specs[:2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, specs[:2]) {
+		t.Errorf("merged operators not split: %+v", parsed)
+	}
+}
+
+func TestFromBSON_Unsupported(t *testing.T) {
+	unsupported := []bson.M{
+		{"$or": bson.A{bson.M{"a": 1}, bson.M{"b": 2}}},
+		{"$and": "a"},
+		{"$where": "this.a > 1"},
+		{"name": bson.M{"$options": "i"}},
+		{"age": bson.M{"$not": 5}},
+		{"location": bson.M{"$not": bson.M{"$near": bson.M{"$geometry": bson.M{}}}}},
+	}
+
+	for _, m := range unsupported {
+		if _, err := FromBSON(m); err == nil {
+			t.Errorf("%v: expected error", m)
+		}
+	}
+
+	specs, err := FromBSON(bson.M{"$or": bson.A{bson.M{"a": 1}}})
+	if err != nil || !reflect.DeepEqual(specs, []ESpec{{Field: "a", Target: 1}}) {
+		t.Errorf("single-clause $or not parsed: %v, %v", specs, err)
+	}
+}
@@ -0,0 +1,42 @@
+package spec
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+Pipeline is a set of query ESpecs used to filter the events of a
+change stream (see entity.Entity.Watch). The ESpecs constrain the
+fields of the change events, for example:
+
+	pipeline := spec.Pipeline{
+		{Field: "operationType", Target: "insert"},
+		{Field: "fullDocument.status", Target: "active"},
+	}
+*/
+type Pipeline []ESpec
+
+/*
+Stages returns the aggregation pipeline for the Pipeline: a single
+$match stage combining its ESpecs (see CombineSpecs), or no stages
+if the Pipeline is empty.
+*/
+func (p Pipeline) Stages() []bson.M {
+	if len(p) == 0 {
+		return []bson.M{}
+	}
+	return []bson.M{{"$match": CombineSpecs(p)}}
+}
+
+/*
+Check verifies that the Target of each ESpec in the Pipeline can be
+used with its QueryOperator (see ESpec.Check).
+*/
+func (p Pipeline) Check() error {
+	for i := range p {
+		if err := p[i].Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
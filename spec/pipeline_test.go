@@ -0,0 +1,37 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPipeline_Stages(t *testing.T) {
+	if stages := (Pipeline{}).Stages(); len(stages) != 0 {
+		t.Errorf("expected no stages for empty pipeline, got %v", stages)
+	}
+
+	pipeline := Pipeline{
+		{Field: "operationType", Target: "insert"},
+		{Field: "fullDocument.age", Target: 18, QueryOperator: "gt"},
+	}
+	expected := []bson.M{{"$match": bson.M{
+		"operationType":    "insert",
+		"fullDocument.age": bson.M{"$gt": 18},
+	}}}
+
+	if !reflect.DeepEqual(pipeline.Stages(), expected) {
+		t.Errorf("unexpected stages: %v", pipeline.Stages())
+	}
+	if err := pipeline.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPipeline_CheckInvalid(t *testing.T) {
+	pipeline := Pipeline{{Field: "fullDocument.tags", Target: 3, QueryOperator: "in"}}
+	if err := pipeline.Check(); err == nil {
+		t.Errorf("expected error for invalid pipeline")
+	}
+}
@@ -7,8 +7,13 @@ package spec
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
 
 	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/navaz-alani/entity/eField"
 )
 
 /*
@@ -42,23 +47,192 @@ type ESpec struct {
 		this ESpec
 	*/
 	QueryOperator string `json:"queryOperator"`
+	/*
+		Negate specifies whether the query constraint of
+		this ESpec should be negated
+	*/
+	Negate bool `json:"negate"`
+	/*
+		Options specifies the $options for a "regex"
+		QueryOperator. It defaults to DefaultRegexOptions
+		(a case-insensitive match); NoRegexOptions emits
+		no $options at all
+	*/
+	Options string `json:"options"`
+	/*
+		QuoteMeta specifies whether the Target of a "regex"
+		QueryOperator should be escaped so that it is matched
+		literally
+	*/
+	QuoteMeta bool `json:"quoteMeta"`
+	/*
+		OmitEmpty specifies whether this ESpec should be left
+		out of an update document if its Target is the zero
+		value (see eField.IsZero), like the BSON "omitempty"
+		option. This prevents partial data from clearing
+		fields the client did not mean to change.
+	*/
+	OmitEmpty bool `json:"omitEmpty"`
+}
+
+/*
+These are the special values of the Options of an ESpec
+with a "regex" QueryOperator.
+*/
+const (
+	// DefaultRegexOptions are used when Options is empty.
+	DefaultRegexOptions = "i"
+	/*
+		NoRegexOptions omits $options from the expression,
+		for a case-sensitive match.
+	*/
+	NoRegexOptions = "-"
+)
+
+/*
+Set returns an ESpec which sets the given field to the
+given value in an update operation.
+*/
+func Set(field string, value interface{}) ESpec {
+	return ESpec{Field: field, Target: value, UpdateOperator: "set"}
+}
+
+/*
+Unset returns an ESpec which removes the given field in an
+update operation.
+*/
+func Unset(field string) ESpec {
+	return ESpec{Field: field, Target: "", UpdateOperator: "unset"}
+}
+
+/*
+Near returns an ESpec which matches documents whose given field,
+a GeoJSON point, is near the point at the given longitude and
+latitude, ordered by distance. If maxMeters is positive, matches
+are limited to that distance.
+
+The Target is the body of the $near expression, for example
+{$geometry: {type: "Point", coordinates: [lng, lat]}, $maxDistance: 500}.
+MongoDB requires a geospatial index on the field; see the
+"2dsphere" IndexTag value (entity.Optimize). The ESpec must not
+be negated, since MongoDB rejects $near within $not; Check (and
+FromBSON) report negated "near" ESpecs.
+*/
+func Near(field string, lng, lat, maxMeters float64) ESpec {
+	target := bson.M{
+		"$geometry": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{lng, lat},
+		},
+	}
+	if maxMeters > 0 {
+		target["$maxDistance"] = maxMeters
+	}
+	return ESpec{Field: field, Target: target, QueryOperator: "near"}
 }
 
 /*
 ToBSON encodes the ESpec as BSON map which can be
 used as a query filter.
 For now, only use this with MongoDB comparison
-operators as they have a consistent syntax.
+operators (including "ne" and "nin") as they have a
+consistent syntax. The "regex" operator is also
+supported; see the Options and QuoteMeta fields, as
+is the "size" operator for matching array lengths,
+the "exists" operator, whose Target is coerced to a bool
+(see Check), and the "near" operator (see Near).
+
+If Negate is set, the operator expression is wrapped
+using $not: {field: {$not: {$op: target}}}. A negated
+equality (no QueryOperator) becomes {field: {$ne: target}}.
 */
 func (s *ESpec) ToBSON() bson.M {
 	if s.QueryOperator == "" {
+		if s.Negate {
+			return bson.M{s.Field: bson.M{"$ne": s.Target}}
+		}
 		return bson.M{s.Field: s.Target}
 	}
-	return bson.M{
-		s.Field: bson.M{
+
+	var expr bson.M
+	if s.QueryOperator == "regex" {
+		expr = s.regexExpr()
+	} else if exists, ok := existsTarget(s.Target); ok && s.QueryOperator == "exists" {
+		expr = bson.M{"$exists": exists}
+	} else {
+		expr = bson.M{
 			fmt.Sprintf("$%s", s.QueryOperator): s.Target,
-		},
+		}
 	}
+	if s.Negate {
+		expr = bson.M{"$not": expr}
+	}
+	return bson.M{s.Field: expr}
+}
+
+/*
+Check verifies that the ESpec's Target can be used with its
+QueryOperator, as is done for the ESpecs constructed by a
+WhereBuilder. For example, the "size" operator requires an
+integer Target, and the "exists" operator requires a bool, or
+a string which can be parsed into one (such as "false"). The
+"near" operator cannot be negated (see Near).
+*/
+func (s *ESpec) Check() error {
+	if s.Negate && s.QueryOperator == "near" {
+		return fmt.Errorf("spec: '$near' on '%s' cannot be negated", s.Field)
+	}
+	return checkTarget(s.Field, s.QueryOperator, s.Target)
+}
+
+/*
+existsTarget coerces the given target of an "exists" operator
+to a bool, reporting whether this was possible.
+*/
+func existsTarget(target interface{}) (bool, bool) {
+	v := reflect.ValueOf(target)
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.String:
+		exists, err := strconv.ParseBool(v.String())
+		return exists, err == nil
+	}
+	return false, false
+}
+
+/*
+regexExpr returns the $regex expression for the ESpec, with
+its Options (see DefaultRegexOptions and NoRegexOptions).
+
+The Target is used as the pattern. Callers must sanitize
+untrusted patterns, or set QuoteMeta so that a string Target
+is escaped and matched literally (useful for substring
+search on user input).
+*/
+func (s *ESpec) regexExpr() bson.M {
+	pattern := s.Target
+	if str, ok := s.Target.(string); ok && s.QuoteMeta {
+		pattern = regexp.QuoteMeta(str)
+	}
+
+	expr := bson.M{"$regex": pattern}
+	switch s.Options {
+	case "":
+		expr["$options"] = DefaultRegexOptions
+	case NoRegexOptions:
+	default:
+		expr["$options"] = s.Options
+	}
+	return expr
+}
+
+/*
+Omitted returns whether the ESpec is left out of update documents
+because its OmitEmpty is set and its Target is the zero value.
+*/
+func (s *ESpec) Omitted() bool {
+	return s.OmitEmpty && eField.IsZero(reflect.ValueOf(s.Target))
 }
 
 /*
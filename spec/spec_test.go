@@ -38,6 +38,83 @@ func TestESpec_ToBsonWithQueryOp(t *testing.T) {
 	}
 }
 
+var (
+	negatedSpec1 = ESpec{
+		Field:  "ns1-eField",
+		Target: "ns1",
+		Negate: true,
+	}
+
+	negatedSpec2 = ESpec{
+		Field:         "ns2-eField",
+		Target:        []string{"ns2"},
+		QueryOperator: "in",
+		Negate:        true,
+	}
+)
+
+func TestESpec_ToBsonNegateNoQueryOp(t *testing.T) {
+	expected := bson.M{"ns1-eField": bson.M{"$ne": "ns1"}}
+	res := negatedSpec1.ToBSON()
+
+	if !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
+
+func TestESpec_ToBsonNegateWithQueryOp(t *testing.T) {
+	expected := bson.M{"ns2-eField": bson.M{"$not": bson.M{"$in": []string{"ns2"}}}}
+	res := negatedSpec2.ToBSON()
+
+	if !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
+
+var (
+	regexSpec1 = ESpec{
+		Field:         "rs1-eField",
+		Target:        "^rs1",
+		QueryOperator: "regex",
+	}
+
+	regexSpec2 = ESpec{
+		Field:         "rs2-eField",
+		Target:        "rs2.*",
+		QueryOperator: "regex",
+		Options:       "i",
+		QuoteMeta:     true,
+	}
+)
+
+func TestESpec_ToBsonRegexDefaultOptions(t *testing.T) {
+	expected := bson.M{"rs1-eField": bson.M{"$regex": "^rs1", "$options": "i"}}
+	res := regexSpec1.ToBSON()
+
+	if !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
+
+func TestESpec_ToBsonRegexNoOptions(t *testing.T) {
+	s := regexSpec1
+	s.Options = NoRegexOptions
+	expected := bson.M{"rs1-eField": bson.M{"$regex": "^rs1"}}
+
+	if res := s.ToBSON(); !reflect.DeepEqual(expected, res) {
+		t.Errorf("unexpected filter: %v", res)
+	}
+}
+
+func TestESpec_ToBsonRegexWithOptions(t *testing.T) {
+	expected := bson.M{"rs2-eField": bson.M{"$regex": `rs2\.\*`, "$options": "i"}}
+	res := regexSpec2.ToBSON()
+
+	if !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
+
 var (
 	updateSpec1 = ESpec{
 		Field:  "us1-eField",
@@ -51,6 +128,123 @@ var (
 	}
 )
 
+func TestESpec_ToBsonSize(t *testing.T) {
+	s := ESpec{Field: "suites", QueryOperator: "size", Target: 3}
+	expected := bson.M{"suites": bson.M{"$size": 3}}
+
+	if !reflect.DeepEqual(expected, s.ToBSON()) {
+		t.Fail()
+	}
+	if err := s.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestESpec_CheckSizeNonInteger(t *testing.T) {
+	for _, target := range []interface{}{3.5, "3", nil} {
+		s := ESpec{Field: "suites", QueryOperator: "size", Target: target}
+		if err := s.Check(); err == nil {
+			t.Errorf("expected error for $size target %#v", target)
+		}
+	}
+}
+
+func TestESpec_ToBsonExists(t *testing.T) {
+	existsTests := []struct {
+		Target   interface{}
+		Expected bool
+	}{
+		{true, true},
+		{false, false},
+		{"false", false},
+	}
+
+	for _, et := range existsTests {
+		s := ESpec{Field: "nickname", QueryOperator: "exists", Target: et.Target}
+		expected := bson.M{"nickname": bson.M{"$exists": et.Expected}}
+
+		if !reflect.DeepEqual(expected, s.ToBSON()) {
+			t.Errorf("unexpected filter for target %#v: %v", et.Target, s.ToBSON())
+		}
+		if err := s.Check(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestESpec_CheckExistsNonBool(t *testing.T) {
+	for _, target := range []interface{}{1, "maybe", nil} {
+		s := ESpec{Field: "nickname", QueryOperator: "exists", Target: target}
+		if err := s.Check(); err == nil {
+			t.Errorf("expected error for $exists target %#v", target)
+		}
+	}
+}
+
+func TestNear(t *testing.T) {
+	s := Near("location", -79.38, 43.65, 500)
+	expected := bson.M{"location": bson.M{"$near": bson.M{
+		"$geometry": bson.M{
+			"type":        "Point",
+			"coordinates": bson.A{-79.38, 43.65},
+		},
+		"$maxDistance": 500.0,
+	}}}
+
+	if !reflect.DeepEqual(expected, s.ToBSON()) {
+		t.Errorf("unexpected filter: %v", s.ToBSON())
+	}
+	if err := s.Check(); err != nil {
+		t.Error(err)
+	}
+
+	unbounded := Near("location", -79.38, 43.65, 0)
+	if _, ok := unbounded.ToBSON()["location"].(bson.M)["$near"].(bson.M)["$maxDistance"]; ok {
+		t.Errorf("expected no $maxDistance for unbounded query")
+	}
+}
+
+func TestESpec_CheckNearNegated(t *testing.T) {
+	s := Near("location", -79.38, 43.65, 500)
+	s.Negate = true
+	if err := s.Check(); err == nil {
+		t.Errorf("expected negated $near to be rejected")
+	}
+
+	if err := (Pipeline{s}).Check(); err == nil {
+		t.Errorf("expected pipeline with negated $near to be rejected")
+	}
+}
+
+func TestESpec_CheckNearNoGeometry(t *testing.T) {
+	for _, target := range []interface{}{bson.M{"$maxDistance": 5}, "here", nil} {
+		s := ESpec{Field: "location", QueryOperator: "near", Target: target}
+		if err := s.Check(); err == nil {
+			t.Errorf("expected error for $near target %#v", target)
+		}
+	}
+}
+
+func TestESpec_Omitted(t *testing.T) {
+	omitTests := []struct {
+		Spec    ESpec
+		Omitted bool
+	}{
+		{ESpec{Field: "name", Target: "", OmitEmpty: true}, true},
+		{ESpec{Field: "age", Target: 0, OmitEmpty: true}, true},
+		{ESpec{Field: "tags", Target: []string{}, OmitEmpty: true}, true},
+		{ESpec{Field: "name", Target: nil, OmitEmpty: true}, true},
+		{ESpec{Field: "name", Target: "jane", OmitEmpty: true}, false},
+		{ESpec{Field: "name", Target: ""}, false},
+	}
+
+	for _, ot := range omitTests {
+		if ot.Spec.Omitted() != ot.Omitted {
+			t.Errorf("%+v: expected omitted to be %t", ot.Spec, ot.Omitted)
+		}
+	}
+}
+
 func TestESpec_ToUpdateSpecNoUpdateOp(t *testing.T) {
 	expected := bson.M{"$set": bson.M{"us1-eField": "us1"}}
 	res := updateSpec1.ToUpdateSpec()
@@ -68,3 +262,14 @@ func TestESpec_ToUpdateSpecWithUpdateOp(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestSetUnset(t *testing.T) {
+	set, unset := Set("s-eField", "s"), Unset("u-eField")
+
+	if res := set.ToUpdateSpec(); !reflect.DeepEqual(res, bson.M{"$set": bson.M{"s-eField": "s"}}) {
+		t.Fail()
+	}
+	if res := unset.ToUpdateSpec(); !reflect.DeepEqual(res, bson.M{"$unset": bson.M{"u-eField": ""}}) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,261 @@
+package spec
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+WhereBuilder is used to fluently construct a set of
+query ESpecs. For example:
+
+	specs, err := spec.Where().
+		Eq("status", "active").
+		Gt("age", 18).
+		In("role", []string{"admin", "editor"}).
+		Build()
+
+Each method checks that its target can be used with
+its operator. The first invalid pairing is reported
+by Build.
+*/
+type WhereBuilder struct {
+	specs []ESpec
+	err   error
+}
+
+/*
+Where returns an empty WhereBuilder.
+*/
+func Where() *WhereBuilder {
+	return &WhereBuilder{specs: make([]ESpec, 0)}
+}
+
+/*
+add appends an ESpec for the given field, operator and target
+to the builder, if the builder has not yet failed and the
+target is valid for the operator.
+*/
+func (wb *WhereBuilder) add(field, operator string, target interface{}) *WhereBuilder {
+	if wb.err != nil {
+		return wb
+	}
+
+	if err := checkTarget(field, operator, target); err != nil {
+		wb.err = err
+		return wb
+	}
+
+	wb.specs = append(wb.specs, ESpec{
+		Field:         field,
+		Target:        target,
+		QueryOperator: operator,
+	})
+	return wb
+}
+
+// Eq constrains the field to be equal to the target.
+func (wb *WhereBuilder) Eq(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "", target)
+}
+
+// Ne constrains the field to not be equal to the target.
+func (wb *WhereBuilder) Ne(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "ne", target)
+}
+
+// Gt constrains the field to be greater than the target.
+func (wb *WhereBuilder) Gt(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "gt", target)
+}
+
+// Gte constrains the field to be greater than or equal to the target.
+func (wb *WhereBuilder) Gte(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "gte", target)
+}
+
+// Lt constrains the field to be less than the target.
+func (wb *WhereBuilder) Lt(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "lt", target)
+}
+
+// Lte constrains the field to be less than or equal to the target.
+func (wb *WhereBuilder) Lte(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "lte", target)
+}
+
+// In constrains the field to be one of the values in the target slice.
+func (wb *WhereBuilder) In(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "in", target)
+}
+
+// Nin constrains the field to not be any of the values in the target slice.
+func (wb *WhereBuilder) Nin(field string, target interface{}) *WhereBuilder {
+	return wb.add(field, "nin", target)
+}
+
+// Size constrains the field, an array, to have exactly n elements.
+func (wb *WhereBuilder) Size(field string, n int) *WhereBuilder {
+	return wb.add(field, "size", n)
+}
+
+// Exists constrains whether the field is present in the document.
+func (wb *WhereBuilder) Exists(field string, exists bool) *WhereBuilder {
+	return wb.add(field, "exists", exists)
+}
+
+/*
+IsNull constrains the field to be null or missing. Combine it
+with Exists(field, true) to match only fields explicitly set
+to null.
+*/
+func (wb *WhereBuilder) IsNull(field string) *WhereBuilder {
+	return wb.add(field, "", nil)
+}
+
+/*
+Build returns the ESpecs constructed by the builder, or
+the error caused by the first invalid method call.
+*/
+func (wb *WhereBuilder) Build() ([]ESpec, error) {
+	if wb.err != nil {
+		return nil, wb.err
+	}
+	return wb.specs, nil
+}
+
+/*
+checkTarget verifies that the given target can be used
+with the given query operator.
+*/
+func checkTarget(field, operator string, target interface{}) error {
+	if field == "" {
+		return fmt.Errorf("spec: empty field for operator '%s'", operator)
+	}
+
+	switch operator {
+	case "in", "nin":
+		if target == nil {
+			return fmt.Errorf("spec: '$%s' on '%s' requires a slice target", operator, field)
+		}
+		if kind := reflect.TypeOf(target).Kind(); kind != reflect.Slice && kind != reflect.Array {
+			return fmt.Errorf("spec: '$%s' on '%s' requires a slice target", operator, field)
+		}
+	case "size":
+		if target == nil {
+			return fmt.Errorf("spec: '$size' on '%s' requires an integer target", field)
+		}
+		switch reflect.TypeOf(target).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return fmt.Errorf("spec: '$size' on '%s' requires an integer target", field)
+		}
+	case "exists":
+		if _, ok := existsTarget(target); !ok {
+			return fmt.Errorf("spec: '$exists' on '%s' requires a bool target", field)
+		}
+	case "near":
+		doc, ok := target.(bson.M)
+		if !ok {
+			return fmt.Errorf("spec: '$near' on '%s' requires a $geometry document target", field)
+		}
+		if _, ok := doc["$geometry"]; !ok {
+			return fmt.Errorf("spec: '$near' on '%s' requires a $geometry document target", field)
+		}
+	case "gt", "gte", "lt", "lte":
+		if target == nil {
+			return fmt.Errorf("spec: '$%s' on '%s' requires a comparable target", operator, field)
+		}
+		switch reflect.TypeOf(target).Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.Bool:
+			return fmt.Errorf("spec: '$%s' on '%s' requires a comparable target", operator, field)
+		}
+	}
+
+	return nil
+}
+
+/*
+CombineSpecs folds the given ESpecs into a single BSON map
+which can be used as a query filter.
+
+Operator constraints on the same field are merged into one
+sub-document, for example {age: {$gt: 18, $lt: 65}}. If
+specs on the same field cannot be merged (such as two
+equality constraints), the filters are combined using $and.
+*/
+func CombineSpecs(specs []ESpec) bson.M {
+	combined := bson.M{}
+
+	for i := range specs {
+		for field, value := range specs[i].ToBSON() {
+			existing, exists := combined[field]
+			if !exists {
+				combined[field] = value
+				continue
+			}
+
+			existingOps, ok1 := operatorDoc(existing)
+			valueOps, ok2 := operatorDoc(value)
+			if !(ok1 && ok2) || hasKeyCollision(existingOps, valueOps) {
+				return andSpecs(specs)
+			}
+
+			merged := bson.M{}
+			for op, target := range existingOps {
+				merged[op] = target
+			}
+			for op, target := range valueOps {
+				merged[op] = target
+			}
+			combined[field] = merged
+		}
+	}
+
+	return combined
+}
+
+/*
+andSpecs combines the filters of the given specs using $and.
+*/
+func andSpecs(specs []ESpec) bson.M {
+	filters := make(bson.A, 0, len(specs))
+	for i := range specs {
+		filters = append(filters, specs[i].ToBSON())
+	}
+	return bson.M{"$and": filters}
+}
+
+/*
+operatorDoc returns the given value as a BSON map and whether
+it is a document of query operators.
+*/
+func operatorDoc(value interface{}) (bson.M, bool) {
+	doc, ok := value.(bson.M)
+	if !ok || len(doc) == 0 {
+		return nil, false
+	}
+
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+/*
+hasKeyCollision returns whether the two maps share a key.
+*/
+func hasKeyCollision(m1, m2 bson.M) bool {
+	for key := range m1 {
+		if _, ok := m2[key]; ok {
+			return true
+		}
+	}
+	return false
+}
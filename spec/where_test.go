@@ -0,0 +1,86 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestWhereBuilder_Build(t *testing.T) {
+	roles := []string{"a", "b"}
+	expected := []ESpec{
+		{Field: "status", Target: "active"},
+		{Field: "age", Target: 18, QueryOperator: "gt"},
+		{Field: "role", Target: roles, QueryOperator: "in"},
+	}
+
+	res, err := Where().Eq("status", "active").Gt("age", 18).In("role", roles).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
+
+func TestWhereBuilder_BuildMissing(t *testing.T) {
+	res, err := Where().Exists("nickname", false).IsNull("deletedAt").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bson.M{
+		"nickname":  bson.M{"$exists": false},
+		"deletedAt": nil,
+	}
+	if !reflect.DeepEqual(expected, CombineSpecs(res)) {
+		t.Errorf("unexpected filter: %v", CombineSpecs(res))
+	}
+}
+
+func TestWhereBuilder_BuildInvalidTarget(t *testing.T) {
+	if _, err := Where().In("role", "a").Build(); err == nil {
+		t.Error("expected error for non-slice $in target")
+	}
+	if _, err := Where().Gt("age", nil).Eq("status", "active").Build(); err == nil {
+		t.Error("expected error for nil $gt target")
+	}
+	if _, err := Where().Eq("suites", 1).add("suites", "size", 1.5).Build(); err == nil {
+		t.Error("expected error for non-integer $size target")
+	}
+	if _, err := Where().Eq("", "active").Build(); err == nil {
+		t.Error("expected error for empty field")
+	}
+}
+
+func TestCombineSpecs(t *testing.T) {
+	specs, err := Where().Eq("status", "active").Gt("age", 18).Lt("age", 65).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bson.M{
+		"status": "active",
+		"age":    bson.M{"$gt": 18, "$lt": 65},
+	}
+	if res := CombineSpecs(specs); !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
+
+func TestCombineSpecsConflict(t *testing.T) {
+	specs, err := Where().Eq("status", "active").Eq("status", "pending").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bson.M{"$and": bson.A{
+		bson.M{"status": "active"},
+		bson.M{"status": "pending"},
+	}}
+	if res := CombineSpecs(specs); !reflect.DeepEqual(expected, res) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,304 @@
+package entity
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/navaz-alani/entity/eField"
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+/*
+Validator is a function which reports whether the given
+field value satisfies a constraint.
+*/
+type Validator func(value reflect.Value) bool
+
+/*
+These are the prefixes used in the eField.ValidateTag to
+specify the kind of constraint on a field.
+*/
+const (
+	// RegexPrefix prefixes a raw regular expression: "re/<expr>/"
+	RegexPrefix = "re/"
+	// PresetPrefix prefixes a preset name: "rep/<name>/"
+	PresetPrefix = "rep/"
+)
+
+/*
+validationPresets maps preset names, usable with the
+PresetPrefix, to their compiled expressions.
+*/
+var validationPresets = map[string]*regexp.Regexp{
+	"email":        regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`),
+	"alpha":        regexp.MustCompile(`^[a-zA-Z]+$`),
+	"alphanumeric": regexp.MustCompile(`^[a-zA-Z0-9]+$`),
+	"numeric":      regexp.MustCompile(`^[0-9]+$`),
+	"hex":          regexp.MustCompile(`^[0-9a-fA-F]+$`),
+}
+
+/*
+regexCache stores compiled user expressions keyed by their
+source so that definitions sharing an expression compile
+it only once.
+*/
+var regexCache = struct {
+	sync.Mutex
+	expressions map[string]*regexp.Regexp
+}{expressions: make(map[string]*regexp.Regexp)}
+
+/*
+compileRegex returns the compiled form of expr, using the
+regexCache where possible.
+*/
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+
+	if re := regexCache.expressions[expr]; re != nil {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.expressions[expr] = re
+	return re, nil
+}
+
+/*
+StringValidator returns a Validator for string fields from
+the given eField.ValidateTag value.
+
+The value can either specify a raw regular expression using
+the RegexPrefix, for example "re/^[a-z]+$/", or a preset
+using the PresetPrefix, for example "rep/email/".
+An error is returned if the value uses neither form, names
+an unknown preset or contains an invalid expression.
+*/
+func StringValidator(tag string) (Validator, error) {
+	var re *regexp.Regexp
+
+	switch {
+	case strings.HasPrefix(tag, PresetPrefix) && strings.HasSuffix(tag, "/"):
+		preset := tag[len(PresetPrefix) : len(tag)-1]
+		if re = validationPresets[preset]; re == nil {
+			return nil, entityErrors.TagUndefined(eField.ValidateTag, tag)
+		}
+	case strings.HasPrefix(tag, RegexPrefix) && strings.HasSuffix(tag, "/"):
+		compiled, err := compileRegex(tag[len(RegexPrefix) : len(tag)-1])
+		if err != nil {
+			return nil, entityErrors.TagUndefined(eField.ValidateTag, tag)
+		}
+		re = compiled
+	default:
+		return nil, entityErrors.TagUndefined(eField.ValidateTag, tag)
+	}
+
+	return func(value reflect.Value) bool {
+		return value.Kind() == reflect.String && re.MatchString(value.String())
+	}, nil
+}
+
+/*
+CompileValidators parses the eField.ValidateTag of each field
+in the given definition and returns the resulting Validators
+keyed by field index.
+
+Only fields of string kind may carry validation tags.
+
+NewEntity (and therefore multiplexer.Create) uses CompileValidators
+to populate the Validators of an Entity; it can also be used to
+populate the Validators of an Entity constructed by hand.
+*/
+func CompileValidators(definition reflect.Type) (map[int]Validator, error) {
+	validators := make(map[int]Validator)
+
+	for i := 0; i < definition.NumField(); i++ {
+		field := definition.Field(i)
+
+		tag := field.Tag.Get(eField.ValidateTag)
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		if field.Type.Kind() != reflect.String {
+			return nil, entityErrors.TagUndefined(eField.ValidateTag, tag)
+		}
+
+		validator, err := StringValidator(tag)
+		if err != nil {
+			return nil, err
+		}
+		validators[i] = validator
+	}
+
+	return validators, nil
+}
+
+/*
+validateStruct runs the given Validators against the fields of the
+given struct value and recurses into its nested structs, returning a
+validation failure for each failing field. Field names are prefixed
+by the given path.
+*/
+func validateStruct(v reflect.Value, validators map[int]Validator, path string) []error {
+	errs := make([]error, 0)
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := path + eField.NameByPriority(field, eField.PriorityJsonBson)
+
+		if validator := validators[i]; validator != nil && !validator(v.Field(i)) {
+			errs = append(errs, entityErrors.ValidationFail(name))
+		}
+		errs = append(errs, validateNested(v.Field(i), name)...)
+	}
+
+	return errs
+}
+
+/*
+validateNested validates the structs stored in the given field value,
+which is named by the given path, using the Validators compiled from
+their types.
+*/
+func validateNested(v reflect.Value, path string) []error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return validateNested(v.Elem(), path)
+	case reflect.Struct:
+		validators, err := nestedValidators(v.Type())
+		if err != nil {
+			return []error{err}
+		}
+		return validateStruct(v, validators, path+".")
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Ptr, reflect.Slice, reflect.Array:
+		default:
+			return nil
+		}
+
+		errs := make([]error, 0)
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, validateNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	}
+	return nil
+}
+
+/*
+validatorCache stores the Validators compiled for nested struct
+types, keyed by type.
+*/
+var validatorCache = struct {
+	sync.Mutex
+	validators map[reflect.Type]map[int]Validator
+}{validators: make(map[reflect.Type]map[int]Validator)}
+
+/*
+nestedValidators returns the Validators for the given nested struct
+type, using the validatorCache where possible.
+*/
+func nestedValidators(t reflect.Type) (map[int]Validator, error) {
+	validatorCache.Lock()
+	defer validatorCache.Unlock()
+
+	if validators, ok := validatorCache.validators[t]; ok {
+		return validators, nil
+	}
+
+	validators, err := CompileValidators(t)
+	if err != nil {
+		return nil, err
+	}
+	validatorCache.validators[t] = validators
+	return validators, nil
+}
+
+/*
+structValidator stores the external validator set using
+UseStructValidator.
+*/
+var structValidator = struct {
+	sync.RWMutex
+	validate func(interface{}) error
+}{}
+
+/*
+UseStructValidator sets an external validator, such as an adapter
+for a struct validation library, which Validate and ValidateFields
+run against entities after their built-in Validators. For example,
+using github.com/go-playground/validator:
+
+	v := validator.New()
+	entity.UseStructValidator(v.Struct)
+
+The validator applies to all Entities; a nil validator removes it.
+*/
+func UseStructValidator(validate func(interface{}) error) {
+	structValidator.Lock()
+	defer structValidator.Unlock()
+	structValidator.validate = validate
+}
+
+/*
+Validate runs the Validators of the Entity e against the
+given entity, which is expected to be of the Entity's
+SchemaDefinition type.
+
+Fields are checked in declaration order and the first
+failing field is reported. If all fields are valid, the
+error of the external validator set using UseStructValidator
+is returned.
+*/
+func (e *Entity) Validate(entity interface{}) error {
+	if errs := e.ValidateFields(entity); len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+/*
+ValidateFields runs the Validators of the Entity e against
+the given entity, like Validate, but reports every failing
+field, in declaration order, followed by the error of the
+external validator (see UseStructValidator). The returned
+slice is empty if the entity is valid.
+
+Validation recurses into embedded structs (and pointers to
+structs) as well as slices and arrays of structs, using the
+ValidateTags of their types. Failing nested fields are named
+by their path, for example "tasks[0].name".
+*/
+func (e *Entity) ValidateFields(entity interface{}) []error {
+	if !e.typeCheck(entity) {
+		return []error{entityErrors.IncompatibleEntityType}
+	}
+
+	errs := validateStruct(reflect.ValueOf(entity), e.Validators, "")
+
+	structValidator.RLock()
+	validate := structValidator.validate
+	structValidator.RUnlock()
+	if validate != nil {
+		if err := validate(entity); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
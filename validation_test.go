@@ -0,0 +1,191 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/navaz-alani/entity/entityErrors"
+)
+
+type validationTest struct {
+	Tag   string
+	Value string
+	Valid bool
+}
+
+var validationTests = [...]validationTest{
+	{"rep/email/", "jane.doe@example.com", true},
+	{"rep/email/", "jane.doe", false},
+	{"rep/numeric/", "0123", true},
+	{"rep/numeric/", "12a", false},
+	{"re/^[a-z]+-[0-9]+$/", "task-1", true},
+	{"re/^[a-z]+-[0-9]+$/", "Task-1", false},
+}
+
+func TestStringValidator(t *testing.T) {
+	for _, vt := range validationTests {
+		validator, err := StringValidator(vt.Tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res := validator(reflect.ValueOf(vt.Value)); res != vt.Valid {
+			t.Errorf("%s: '%s' expected %v, got %v", vt.Tag, vt.Value, vt.Valid, res)
+		}
+	}
+}
+
+func TestStringValidatorUndefined(t *testing.T) {
+	for _, tag := range []string{"rep/unknown/", "re/[a-/", "email"} {
+		if _, err := StringValidator(tag); err == nil {
+			t.Errorf("%s: expected error", tag)
+		}
+	}
+}
+
+type ValidatedUser struct {
+	Name  string `json:"name" _va_:"rep/alpha/"`
+	Email string `json:"email" _va_:"rep/email/"`
+	Age   int64  `json:"age"`
+}
+
+type InvalidValidationKind struct {
+	Age int64 `json:"age" _va_:"rep/numeric/"`
+}
+
+func TestNewEntityValidators(t *testing.T) {
+	ety, err := NewEntity(TypeOf(ValidatedUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ety.Validators) != 2 {
+		t.Fatal("expected 2 validators")
+	}
+
+	if err := ety.Validate(ValidatedUser{Name: "Jane", Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ety.Validate(ValidatedUser{Name: "Jane", Email: "jane"}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestNewEntityInvalidValidationKind(t *testing.T) {
+	if _, err := NewEntity(TypeOf(InvalidValidationKind{}), nil); err == nil {
+		t.Fail()
+	}
+}
+
+func TestCompileValidators(t *testing.T) {
+	validators, err := CompileValidators(TypeOf(ValidatedUser{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ety := &Entity{SchemaDefinition: TypeOf(ValidatedUser{}), Validators: validators}
+	if err := ety.Validate(ValidatedUser{Name: "Jane", Email: "jane"}); err == nil {
+		t.Errorf("hand-built entity did not validate email")
+	}
+}
+
+func TestUseStructValidator(t *testing.T) {
+	ety, err := NewEntity(TypeOf(ValidatedUser{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tooYoung := errors.New("age: must be at least 18")
+	UseStructValidator(func(v interface{}) error {
+		if v.(ValidatedUser).Age < 18 {
+			return tooYoung
+		}
+		return nil
+	})
+	defer UseStructValidator(nil)
+
+	if err := ety.Validate(ValidatedUser{Name: "Jane", Email: "jane@example.com", Age: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ety.Validate(ValidatedUser{Name: "Jane", Email: "jane@example.com", Age: 12}); err != tooYoung {
+		t.Errorf("expected external validator error, got %v", err)
+	}
+
+	// built-in validators run first
+	errs := ety.ValidateFields(ValidatedUser{Name: "Jane", Email: "jane", Age: 12})
+	if len(errs) != 2 || errs[1] != tooYoung {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+type ValidatedTask struct {
+	Name string `json:"name" _va_:"rep/alphanumeric/"`
+}
+
+type ValidatedProject struct {
+	Owner ValidatedUser    `json:"owner"`
+	Lead  *ValidatedUser   `json:"lead"`
+	Tasks []ValidatedTask  `json:"tasks"`
+	Refs  []*ValidatedTask `json:"refs"`
+}
+
+func TestValidateNested(t *testing.T) {
+	ety, err := NewEntity(TypeOf(ValidatedProject{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project := ValidatedProject{
+		Owner: ValidatedUser{Name: "Jane", Email: "jane@example.com"},
+		Tasks: []ValidatedTask{{Name: "task1"}, {Name: "task 2"}},
+		Refs:  []*ValidatedTask{nil, {Name: "ok"}},
+	}
+	if errs := ety.ValidateFields(project); len(errs) != 1 || errs[0].Error() != entityErrors.ValidationFail("tasks[1].name").Error() {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	project.Tasks[1].Name = "task2"
+	project.Lead = &ValidatedUser{Name: "Jane", Email: "jane"}
+	if err := ety.Validate(project); err == nil || err.Error() != entityErrors.ValidationFail("lead.email").Error() {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type BenchmarkedUser struct {
+	Name     string `json:"name" _va_:"rep/alpha/"`
+	Email    string `json:"email" _va_:"rep/email/"`
+	Username string `json:"username" _va_:"re/^[a-z0-9_]{3,16}$/"`
+	Handle   string `json:"handle" _va_:"re/^@[a-z0-9_]+$/"`
+	Code     string `json:"code" _va_:"rep/hex/"`
+}
+
+func BenchmarkCompileValidators(b *testing.B) {
+	definition := TypeOf(BenchmarkedUser{})
+	for i := 0; i < b.N; i++ {
+		if _, err := CompileValidators(definition); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	ety, err := NewEntity(TypeOf(BenchmarkedUser{}), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	user := BenchmarkedUser{
+		Name:     "Jane",
+		Email:    "jane@example.com",
+		Username: "jane_doe",
+		Handle:   "@jane",
+		Code:     "c0ffee",
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ety.Validate(user); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
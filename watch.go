@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"context"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+/*
+ChangeEvent is a change stream event for an Entity, as decoded by
+DecodeChange. Its JSON encoding is used for server-sent events (see
+multiplexer.EMux.SSEHandler).
+*/
+type ChangeEvent struct {
+	// OperationType is the type of change, such as "insert" or "delete".
+	OperationType string `json:"operationType"`
+	// DocumentKey is the _id of the changed document.
+	DocumentKey interface{} `json:"documentKey"`
+	/*
+		Entity is the changed document as an instance of the Entity's
+		SchemaDefinition. It is nil for events without a full document,
+		such as deletions.
+	*/
+	Entity interface{} `json:"entity"`
+}
+
+/*
+Watch opens a change stream on the underlying database collection
+pointed at by e, whose events are filtered by the given (possibly
+empty) Pipeline. The events of update operations include the current
+version of the changed document. Events can be decoded using
+DecodeChange, for example:
+
+	stream, err := userEntity.Watch(ctx, spec.Pipeline{
+		{Field: "operationType", Target: "insert"},
+	})
+	...
+	for stream.Next(ctx) {
+		event, err := userEntity.DecodeChange(stream.Current)
+		...
+	}
+
+Change streams are only available on replica sets and sharded
+clusters; on a standalone server, Watch returns an error.
+*/
+func (e *Entity) Watch(ctx context.Context, pipeline spec.Pipeline) (*mongo.ChangeStream, error) {
+	return e.watch(pipeline, func(stages []bson.M, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return e.PStorage.Watch(ctx, stages, opts)
+	})
+}
+
+/*
+watch checks the given Pipeline and uses the given run function to
+open a change stream with its stages.
+*/
+func (e *Entity) watch(pipeline spec.Pipeline,
+	run func(stages []bson.M, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error)) (*mongo.ChangeStream, error) {
+	if err := pipeline.Check(); err != nil {
+		return nil, err
+	}
+	return run(pipeline.Stages(), options.ChangeStream().SetFullDocument(options.UpdateLookup))
+}
+
+/*
+DecodeChange decodes the given change stream event, such as the
+Current event of a stream opened using Watch, into a ChangeEvent.
+An error wrapping entityErrors.DBDecodeFail is returned if the
+event's document cannot be decoded into the Entity's
+SchemaDefinition.
+*/
+func (e *Entity) DecodeChange(event bson.Raw) (*ChangeEvent, error) {
+	var envelope struct {
+		OperationType string `bson:"operationType"`
+		DocumentKey   struct {
+			ID interface{} `bson:"_id"`
+		} `bson:"documentKey"`
+		FullDocument bson.RawValue `bson:"fullDocument"`
+	}
+	if err := bson.Unmarshal(event, &envelope); err != nil {
+		return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+	}
+
+	change := &ChangeEvent{
+		OperationType: envelope.OperationType,
+		DocumentKey:   envelope.DocumentKey.ID,
+	}
+	if envelope.FullDocument.Type == bson.TypeEmbeddedDocument {
+		entity := reflect.New(e.SchemaDefinition)
+		if err := envelope.FullDocument.Unmarshal(entity.Interface()); err != nil {
+			return nil, entityErrors.Wrap(entityErrors.DBDecodeFail, err)
+		}
+		if err := e.decrypt(entity.Elem()); err != nil {
+			return nil, err
+		}
+		change.Entity = entity.Elem().Interface()
+	}
+	return change, nil
+}
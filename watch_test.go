@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/navaz-alani/entity/entityErrors"
+	"github.com/navaz-alani/entity/spec"
+)
+
+type Listing struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Title string             `bson:"title"`
+}
+
+func TestEntity_Watch(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(Listing{})}
+	pipeline := spec.Pipeline{{Field: "operationType", Target: "insert"}}
+
+	var passed []bson.M
+	var fullDocument *options.FullDocument
+	_, err := ety.watch(pipeline, func(stages []bson.M, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		passed = stages
+		fullDocument = opts.FullDocument
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(passed, []bson.M{{"$match": bson.M{"operationType": "insert"}}}) {
+		t.Errorf("unexpected pipeline: %v", passed)
+	}
+	if fullDocument == nil || *fullDocument != options.UpdateLookup {
+		t.Errorf("expected full documents to be looked up")
+	}
+}
+
+func TestEntity_DecodeChange(t *testing.T) {
+	ety := &Entity{SchemaDefinition: TypeOf(Listing{})}
+	id := primitive.NewObjectID()
+
+	raw, _ := bson.Marshal(bson.M{
+		"operationType": "insert",
+		"documentKey":   bson.M{"_id": id},
+		"fullDocument":  bson.M{"_id": id, "title": "loft"},
+	})
+	change, err := ety.DecodeChange(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ChangeEvent{OperationType: "insert", DocumentKey: id, Entity: Listing{ID: id, Title: "loft"}}
+	if !reflect.DeepEqual(change, expected) {
+		t.Errorf("expected %+v, got %+v", expected, change)
+	}
+
+	raw, _ = bson.Marshal(bson.M{"operationType": "delete", "documentKey": bson.M{"_id": id}})
+	if change, err = ety.DecodeChange(raw); err != nil || change.Entity != nil {
+		t.Errorf("unexpected delete event %+v: %v", change, err)
+	}
+
+	raw, _ = bson.Marshal(bson.M{"operationType": "insert", "fullDocument": bson.M{"title": 5}})
+	if _, err = ety.DecodeChange(raw); !errors.Is(err, entityErrors.DBDecodeFail) {
+		t.Errorf("expected DBDecodeFail, got %v", err)
+	}
+}